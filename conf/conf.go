@@ -2,7 +2,9 @@ package conf
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io/fs"
 	"log"
 	"os"
 	"path"
@@ -13,37 +15,75 @@ import (
 
 var (
 	loaders = map[string]func([]byte, any) error{
+		".json": LoadFromJsonBytes,
+		".toml": LoadFromTomlBytes,
 		".yaml": LoadFromYamlBytes,
 		".yml":  LoadFromYamlBytes,
 	}
 )
 
-// Load loads config into v from file, .json, .yaml and .yml are acceptable.
+// Load loads config into v from file, .json, .toml, .yaml and .yml are
+// acceptable. Config is layered, later layers override earlier ones:
+//   - the defaults of the json tags, e.g. `json:",default=8080"`
+//   - file
+//   - the file of the environment set by UseEnvironment, e.g. etc/app.production.yaml
+//   - the environment variables with the prefix set by UseEnvPrefix
+//
+// Fields tagged `json:",required"` must be set by one of the layers.
 func Load(file string, v any, opts ...Option) error {
-	var err error
 	var opt options
 	for _, o := range opts {
 		o(&opt)
 	}
 
-	var content []byte
-	if opt.hasFS {
-		content, err = opt.fs.ReadFile(file)
-		if err != nil {
-			panic(err)
+	if err := applyDefaults(v); err != nil {
+		return err
+	}
+
+	if err := loadFile(file, v, opt); err != nil {
+		return err
+	}
+
+	if opt.environment != "" {
+		envFile := environmentFile(file, opt.environment)
+		if err := loadFile(envFile, v, opt); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
 		}
-	} else {
-		content, err = os.ReadFile(file)
-		if err != nil {
+	}
+
+	if opt.envPrefix != "" {
+		if err := applyEnv(v, opt.envPrefix, os.LookupEnv); err != nil {
 			return err
 		}
 	}
 
+	return checkRequired(v)
+}
+
+// environmentFile returns the overlay of file for environment, e.g. etc/app.production.yaml
+func environmentFile(file, environment string) string {
+	ext := path.Ext(file)
+	return strings.TrimSuffix(file, ext) + "." + environment + ext
+}
+
+// loadFile decodes file into v with the loader of its extension
+func loadFile(file string, v any, opt options) error {
 	loader, ok := loaders[strings.ToLower(path.Ext(file))]
 	if !ok {
 		return fmt.Errorf("unrecognized file type: %s", file)
 	}
 
+	var content []byte
+	var err error
+	if opt.hasFS {
+		content, err = opt.fs.ReadFile(file)
+	} else {
+		content, err = os.ReadFile(file)
+	}
+	if err != nil {
+		return err
+	}
+
 	if opt.env {
 		return loader([]byte(os.ExpandEnv(string(content))), v)
 	}
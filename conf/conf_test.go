@@ -0,0 +1,129 @@
+package conf
+
+import (
+	"embed"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/webserver"
+)
+
+//go:embed conf.go
+var testFS embed.FS
+
+type testConfig struct {
+	Name    string
+	Port    int           `json:",default=8080"`
+	Timeout time.Duration `json:",default=5s"`
+	Hosts   []string      `json:",default=[a,b]"`
+	Redis   struct {
+		URL string `json:",required"`
+	}
+	GPT struct {
+		APIKey string
+		Models map[string]int `json:",optional"`
+	}
+	Debug bool `json:"debug,optional"`
+}
+
+// writeFile replaces a file atomically, so watchers never read it half written
+func writeFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(file+".tmp", []byte(content), 0o644))
+	require.NoError(t, os.Rename(file+".tmp", file))
+	return file
+}
+
+// TestLoadFormats tests loading every supported file type.
+func TestLoadFormats(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		writeFile(t, dir, "app.json", `{"Name": "app", "Redis": {"URL": "redis://json"}}`),
+		writeFile(t, dir, "app.toml", "Name = \"app\"\n[Redis]\nURL = \"redis://toml\"\n"),
+		writeFile(t, dir, "app.yaml", "Name: app\nRedis:\n  URL: redis://yaml\n"),
+	}
+	for _, file := range files {
+		var c testConfig
+		require.NoError(t, Load(file, &c), file)
+		assert.Equal(t, "app", c.Name, file)
+		assert.Equal(t, "redis://"+filepath.Ext(file)[1:], c.Redis.URL, file)
+	}
+
+	var c testConfig
+	assert.ErrorContains(t, Load(writeFile(t, dir, "app.ini", ""), &c), "unrecognized file type")
+}
+
+// TestLoadLayers tests defaults, environment files and env vars overriding each other.
+func TestLoadLayers(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.yaml", "Name: app\nPort: 9000\nRedis:\n  URL: redis://base\nGPT:\n  APIKey: base\n")
+	writeFile(t, dir, "app.production.yaml", "Redis:\n  URL: redis://production\n")
+
+	t.Setenv("APP_GPT_API_KEY", "secret")
+	t.Setenv("APP_HOSTS", "x, y")
+	t.Setenv("APP_DEBUG", "true")
+	t.Setenv("APP_GPT_MODELS", `{"gpt": 3}`)
+
+	var c testConfig
+	require.NoError(t, Load(file, &c, UseEnvironment("production"), UseEnvPrefix("APP_")))
+	assert.Equal(t, "app", c.Name)
+	assert.Equal(t, 9000, c.Port)
+	assert.Equal(t, 5*time.Second, c.Timeout)
+	assert.Equal(t, "redis://production", c.Redis.URL)
+	assert.Equal(t, "secret", c.GPT.APIKey)
+	assert.Equal(t, map[string]int{"gpt": 3}, c.GPT.Models)
+	assert.Equal(t, []string{"x", "y"}, c.Hosts)
+	assert.True(t, c.Debug)
+
+	// The environment file is optional
+	c = testConfig{}
+	require.NoError(t, Load(file, &c, UseEnvironment("staging")))
+	assert.Equal(t, "redis://base", c.Redis.URL)
+	assert.Equal(t, []string{"a", "b"}, c.Hosts)
+
+	t.Setenv("APP_PORT", "many")
+	assert.ErrorContains(t, Load(file, &c, UseEnvPrefix("APP")), "APP_PORT")
+}
+
+// TestLoadRequired tests that required fields must be set.
+func TestLoadRequired(t *testing.T) {
+	file := writeFile(t, t.TempDir(), "app.yaml", "Name: app\n")
+
+	var c testConfig
+	assert.EqualError(t, Load(file, &c), "missing required config: Redis.URL")
+
+	t.Setenv("APP_REDIS_URL", "redis://env")
+	require.NoError(t, Load(file, &c, UseEnvPrefix("APP")))
+	assert.Equal(t, "redis://env", c.Redis.URL)
+}
+
+// TestLoadFSMissing tests that a missing embedded file is an error.
+func TestLoadFSMissing(t *testing.T) {
+	var c testConfig
+	assert.NotPanics(t, func() {
+		assert.Error(t, Load("etc/missing.yaml", &c, UseFS(testFS)))
+	})
+}
+
+// TestLoadServerDefaults tests the defaults of go-zero config structs.
+func TestLoadServerDefaults(t *testing.T) {
+	file := writeFile(t, t.TempDir(), "app.yaml", "Name: app\nPort: 8888\n")
+
+	var c webserver.WebServerConf
+	require.NoError(t, Load(file, &c))
+	assert.Equal(t, "0.0.0.0", c.Host)
+	assert.Equal(t, 8888, c.Port)
+	assert.Equal(t, 10000, c.MaxConns)
+}
+
+// TestToSnake tests splitting field names into env var words.
+func TestToSnake(t *testing.T) {
+	for in, want := range map[string]string{"APIKey": "API_KEY", "URL": "URL", "AccessKeyID": "ACCESS_KEY_ID", "DallEModel": "DALL_E_MODEL", "Port": "PORT"} {
+		assert.Equal(t, want, toSnake(in), in)
+	}
+}
@@ -0,0 +1,271 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// fieldTag is the parsed json tag of a config field, in the format of
+// go-zero: `json:"Name,default=8080,optional"`
+type fieldTag struct {
+	key        string
+	defaultVal string
+	hasDefault bool
+	required   bool
+}
+
+func parseFieldTag(sf reflect.StructField) fieldTag {
+	tag := sf.Tag.Get("json")
+	name, opts, _ := strings.Cut(tag, ",")
+	ft := fieldTag{key: name}
+	if ft.key == "" {
+		ft.key = sf.Name
+	}
+
+	for _, opt := range splitOptions(opts) {
+		switch {
+		case strings.HasPrefix(opt, "default="):
+			ft.defaultVal = strings.TrimPrefix(opt, "default=")
+			ft.hasDefault = true
+		case opt == "required":
+			ft.required = true
+		}
+	}
+	return ft
+}
+
+// splitOptions splits tag options by commas outside of brackets, so
+// default=[a,b] stays a single option
+func splitOptions(opts string) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range opts {
+		switch r {
+		case '[', '(':
+			depth++
+		case ']', ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				parts = append(parts, strings.TrimSpace(opts[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if rest := strings.TrimSpace(opts[start:]); rest != "" {
+		parts = append(parts, rest)
+	}
+	return parts
+}
+
+// visitFunc is called for every settable config field with its dotted path,
+// its env var segments and its tag. Returning true descends into structs.
+type visitFunc func(field reflect.Value, path string, env []string, tag fieldTag) (bool, error)
+
+// walk visits the exported fields of the struct val, flattening embedded structs
+func walk(val reflect.Value, path string, env []string, visit visitFunc) error {
+	t := val.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() || sf.Tag.Get("json") == "-" {
+			continue
+		}
+
+		field := val.Field(i)
+		tag := parseFieldTag(sf)
+		if sf.Anonymous && sf.Tag.Get("json") == "" && field.Kind() == reflect.Struct {
+			if err := walk(field, path, env, visit); err != nil {
+				return err
+			}
+			continue
+		}
+
+		fieldPath := tag.key
+		if path != "" {
+			fieldPath = path + "." + tag.key
+		}
+		fieldEnv := append(env[:len(env):len(env)], tag.key)
+
+		descend, err := visit(field, fieldPath, fieldEnv, tag)
+		if err != nil {
+			return err
+		}
+		if descend && field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(time.Time{}) {
+			if err := walk(field, fieldPath, fieldEnv, visit); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// structValue returns the struct v points to
+func structValue(v any) (reflect.Value, bool) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return reflect.Value{}, false
+	}
+	val = val.Elem()
+	return val, val.Kind() == reflect.Struct
+}
+
+// applyDefaults sets the zero fields of v with a default option to their default
+func applyDefaults(v any) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	return walk(val, "", nil, func(field reflect.Value, path string, _ []string, tag fieldTag) (bool, error) {
+		if tag.hasDefault && field.IsZero() {
+			if err := setString(field, tag.defaultVal); err != nil {
+				return false, fmt.Errorf("invalid default of %s: %w", path, err)
+			}
+		}
+		return true, nil
+	})
+}
+
+// applyEnv sets the fields of v from the environment variables named by
+// prefix and their path, e.g. APP_REDIS_URL or APP_GPT_API_KEY for the
+// field APIKey of GPT. lookup returns the value of a variable.
+func applyEnv(v any, prefix string, lookup func(string) (string, bool)) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	return walk(val, "", []string{prefix}, func(field reflect.Value, path string, env []string, _ fieldTag) (bool, error) {
+		for _, name := range envNames(env) {
+			value, ok := lookup(name)
+			if !ok {
+				continue
+			}
+			if err := setString(field, value); err != nil {
+				return false, fmt.Errorf("invalid value of %s for %s: %w", name, path, err)
+			}
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+// envNames returns the names of the env var of a field path, with segments
+// upper cased as is and split into words, e.g. APP_GPT_APIKEY and APP_GPT_API_KEY
+func envNames(segments []string) []string {
+	upper := make([]string, len(segments))
+	snake := make([]string, len(segments))
+	for i, s := range segments {
+		upper[i] = strings.ToUpper(s)
+		snake[i] = toSnake(s)
+	}
+
+	names := []string{strings.Join(upper, "_")}
+	if name := strings.Join(snake, "_"); name != names[0] {
+		names = append(names, name)
+	}
+	return names
+}
+
+// toSnake converts a field name to upper snake case, e.g. APIKey to API_KEY
+func toSnake(s string) string {
+	runes := []rune(s)
+	var b strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			prevLower := unicode.IsLower(runes[i-1]) || unicode.IsDigit(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if prevLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				b.WriteByte('_')
+			}
+		}
+		b.WriteRune(unicode.ToUpper(r))
+	}
+	return b.String()
+}
+
+// checkRequired returns an error listing the zero fields of v marked required
+func checkRequired(v any) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+
+	var missing []string
+	_ = walk(val, "", nil, func(field reflect.Value, path string, _ []string, tag fieldTag) (bool, error) {
+		if tag.required && field.IsZero() {
+			missing = append(missing, path)
+		}
+		return true, nil
+	})
+	if len(missing) > 0 {
+		return fmt.Errorf("missing required config: %s", strings.Join(missing, ", "))
+	}
+	return nil
+}
+
+// setString sets field from its string representation. Slices take comma
+// separated values, optionally in brackets, and other composite types JSON.
+func setString(field reflect.Value, value string) error {
+	if field.Type() == durationType {
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+		return nil
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(f)
+	case reflect.Slice:
+		if strings.HasPrefix(strings.TrimSpace(value), "[\"") {
+			return json.Unmarshal([]byte(value), field.Addr().Interface())
+		}
+		value = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(value), "["), "]")
+		var items []string
+		if value != "" {
+			items = strings.Split(value, ",")
+		}
+		slice := reflect.MakeSlice(field.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := setString(slice.Index(i), strings.TrimSpace(item)); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+	default:
+		return json.Unmarshal([]byte(value), field.Addr().Interface())
+	}
+	return nil
+}
@@ -1,15 +1,24 @@
 package conf
 
-import "embed"
+import (
+	"embed"
+	"strings"
+	"time"
+)
 
 type (
 	// Option defines the method to customize the config options.
 	Option func(opt *options)
 
 	options struct {
-		env   bool
-		hasFS bool
-		fs    embed.FS
+		env         bool
+		hasFS       bool
+		fs          embed.FS
+		environment string
+		envPrefix   string
+
+		watchInterval     time.Duration
+		watchErrorHandler func(error)
 	}
 )
 
@@ -20,9 +29,43 @@ func UseEnv() Option {
 	}
 }
 
+// UseFS customizes the config to read files from fs rather than the disk.
 func UseFS(fs embed.FS) Option {
 	return func(opt *options) {
 		opt.hasFS = true
 		opt.fs = fs
 	}
 }
+
+// UseEnvironment overlays the file of environment on the config file, e.g.
+// etc/app.production.yaml on etc/app.yaml. The overlay file is optional.
+func UseEnvironment(environment string) Option {
+	return func(opt *options) {
+		opt.environment = environment
+	}
+}
+
+// UseEnvPrefix overlays the environment variables starting with prefix on
+// the config. Variables are named by the path of their field, e.g.
+// APP_REDIS_URL sets Redis.URL, and APP_GPT_APIKEY or APP_GPT_API_KEY set
+// GPT.APIKey. Slices take comma separated values, maps and structs JSON.
+func UseEnvPrefix(prefix string) Option {
+	return func(opt *options) {
+		opt.envPrefix = strings.TrimSuffix(prefix, "_")
+	}
+}
+
+// UseWatchInterval sets how often a Watcher polls the config files, 2s by default.
+func UseWatchInterval(interval time.Duration) Option {
+	return func(opt *options) {
+		opt.watchInterval = interval
+	}
+}
+
+// UseWatchErrorHandler sets the handler of rejected config updates of a
+// Watcher, they are logged by default.
+func UseWatchErrorHandler(handler func(error)) Option {
+	return func(opt *options) {
+		opt.watchErrorHandler = handler
+	}
+}
@@ -0,0 +1,252 @@
+package conf
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const defaultWatchInterval = 2 * time.Second
+
+// Validator is implemented by configs checking themselves after loading
+type Validator interface {
+	Validate() error
+}
+
+// Watcher keeps a config up to date with its files. Every change is loaded
+// with the options of the watcher, validated, and swapped in atomically, so
+// readers always see a complete config:
+//
+//	w := conf.MustNewWatcher[config.Config](*configFile, conf.UseEnvPrefix("APP"))
+//	w.Subscribe(func(old, new *config.Config) {
+//	    limiter.SetRate(new.RateLimit)
+//	})
+//	w.Start()
+//	defer w.Stop()
+//
+// Updates failing to load or validate are rejected, the last good config
+// stays in use. Files are polled, which also notices the symlink swaps of
+// Kubernetes ConfigMap volumes.
+type Watcher[T any] struct {
+	file    string
+	opts    []Option
+	opt     options
+	current atomic.Pointer[T]
+	// reloadMu serializes reloads so configs are swapped in the order they are read
+	reloadMu sync.Mutex
+
+	mu          sync.Mutex
+	stamps      []fileStamp
+	subscribers map[int]func(old, new *T)
+	nextID      int
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// fileStamp identifies a version of a watched file
+type fileStamp struct {
+	// target is the file behind any symlinks
+	target  string
+	modTime time.Time
+	size    int64
+	exists  bool
+}
+
+// NewWatcher loads the config of file with opts and returns a Watcher for
+// it. Call Start to watch for changes.
+func NewWatcher[T any](file string, opts ...Option) (*Watcher[T], error) {
+	w := &Watcher[T]{
+		file:        file,
+		opts:        opts,
+		subscribers: map[int]func(old, new *T){},
+	}
+	for _, o := range opts {
+		o(&w.opt)
+	}
+	if w.opt.watchInterval <= 0 {
+		w.opt.watchInterval = defaultWatchInterval
+	}
+
+	w.stamps = w.stampFiles()
+	v, err := w.load()
+	if err != nil {
+		return nil, err
+	}
+	w.current.Store(v)
+	return w, nil
+}
+
+// MustNewWatcher is NewWatcher, exiting on error.
+func MustNewWatcher[T any](file string, opts ...Option) *Watcher[T] {
+	w, err := NewWatcher[T](file, opts...)
+	if err != nil {
+		log.Fatalf("error: config file %s, %s", file, err.Error())
+	}
+	return w
+}
+
+// Load returns the current config, it must not be modified
+func (w *Watcher[T]) Load() *T {
+	return w.current.Load()
+}
+
+// Subscribe calls fn with the old and new config after every accepted
+// change. It returns a function removing the subscription.
+func (w *Watcher[T]) Subscribe(fn func(old, new *T)) func() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	id := w.nextID
+	w.nextID++
+	w.subscribers[id] = fn
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		delete(w.subscribers, id)
+	}
+}
+
+// Start polls the config files for changes until Stop is called. Configs
+// read from an embedded FS never change and are not watched.
+func (w *Watcher[T]) Start() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.stop != nil || w.opt.hasFS {
+		return
+	}
+
+	w.stop = make(chan struct{})
+	w.done = make(chan struct{})
+	go w.watch(w.stop, w.done)
+}
+
+// Stop stops watching and waits for a pending reload to finish
+func (w *Watcher[T]) Stop() {
+	w.mu.Lock()
+	stop, done := w.stop, w.done
+	w.stop, w.done = nil, nil
+	w.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+// Reload loads the config files now. The new config is swapped in and
+// subscribers are notified if it differs from the current one. An invalid
+// config is returned as an error and the current one is kept.
+func (w *Watcher[T]) Reload() error {
+	w.reloadMu.Lock()
+	defer w.reloadMu.Unlock()
+
+	w.mu.Lock()
+	w.stamps = w.stampFiles()
+	w.mu.Unlock()
+
+	v, err := w.load()
+	if err != nil {
+		return err
+	}
+
+	old := w.current.Swap(v)
+	if reflect.DeepEqual(old, v) {
+		return nil
+	}
+
+	w.mu.Lock()
+	subscribers := make([]func(old, new *T), 0, len(w.subscribers))
+	for id := 0; id < w.nextID; id++ {
+		if fn, ok := w.subscribers[id]; ok {
+			subscribers = append(subscribers, fn)
+		}
+	}
+	w.mu.Unlock()
+
+	for _, fn := range subscribers {
+		fn(old, v)
+	}
+	return nil
+}
+
+func (w *Watcher[T]) watch(stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(w.opt.watchInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if !w.changed() {
+				continue
+			}
+			if err := w.Reload(); err != nil {
+				w.reportError(err)
+			}
+		}
+	}
+}
+
+// changed reports whether a config file changed since it was last loaded
+func (w *Watcher[T]) changed() bool {
+	stamps := w.stampFiles()
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return !reflect.DeepEqual(stamps, w.stamps)
+}
+
+// load loads and validates a new config
+func (w *Watcher[T]) load() (*T, error) {
+	v := new(T)
+	if err := Load(w.file, v, w.opts...); err != nil {
+		return nil, err
+	}
+	if validator, ok := any(v).(Validator); ok {
+		if err := validator.Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+func (w *Watcher[T]) reportError(err error) {
+	if w.opt.watchErrorHandler != nil {
+		w.opt.watchErrorHandler(err)
+		return
+	}
+	log.Printf("error: config file %s not reloaded, %s", w.file, err.Error())
+}
+
+// stampFiles returns the stamps of the config file and its environment overlay
+func (w *Watcher[T]) stampFiles() []fileStamp {
+	files := []string{w.file}
+	if w.opt.environment != "" {
+		files = append(files, environmentFile(w.file, w.opt.environment))
+	}
+
+	stamps := make([]fileStamp, len(files))
+	for i, file := range files {
+		stamps[i] = stampFile(file)
+	}
+	return stamps
+}
+
+func stampFile(file string) fileStamp {
+	target, err := filepath.EvalSymlinks(file)
+	if err != nil {
+		return fileStamp{}
+	}
+	info, err := os.Stat(target)
+	if err != nil {
+		return fileStamp{}
+	}
+	return fileStamp{target: target, modTime: info.ModTime(), size: info.Size(), exists: true}
+}
@@ -0,0 +1,135 @@
+package conf
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type flagsConfig struct {
+	RateLimit int  `json:",required"`
+	Beta      bool `json:",optional"`
+}
+
+func (c *flagsConfig) Validate() error {
+	if c.RateLimit < 0 {
+		return errors.New("negative rate limit")
+	}
+	return nil
+}
+
+type change struct {
+	old, new *flagsConfig
+}
+
+func waitChange(t *testing.T, changes chan change) change {
+	t.Helper()
+	select {
+	case c := <-changes:
+		return c
+	case <-time.After(2 * time.Second):
+		t.Fatal("config not reloaded")
+		return change{}
+	}
+}
+
+// TestWatcherReload tests reloading a changed file and rejecting invalid updates.
+func TestWatcherReload(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.yaml", "RateLimit: 10\n")
+
+	rejected := make(chan error, 1)
+	w, err := NewWatcher[flagsConfig](file, UseWatchInterval(10*time.Millisecond), UseWatchErrorHandler(func(err error) {
+		rejected <- err
+	}))
+	require.NoError(t, err)
+	assert.Equal(t, 10, w.Load().RateLimit)
+
+	changes := make(chan change, 1)
+	w.Subscribe(func(old, new *flagsConfig) {
+		changes <- change{old, new}
+	})
+	w.Start()
+	defer w.Stop()
+
+	writeFile(t, dir, "app.yaml", "RateLimit: 20\nBeta: true\n")
+	c := waitChange(t, changes)
+	assert.Equal(t, 10, c.old.RateLimit)
+	assert.Equal(t, 20, c.new.RateLimit)
+	assert.True(t, w.Load().Beta)
+
+	writeFile(t, dir, "app.yaml", "RateLimit: -1\n")
+	select {
+	case err := <-rejected:
+		assert.EqualError(t, err, "negative rate limit")
+	case <-time.After(2 * time.Second):
+		t.Fatal("invalid config not rejected")
+	}
+	assert.Equal(t, 20, w.Load().RateLimit)
+
+	writeFile(t, dir, "app.yaml", "Beta: true\n")
+	assert.ErrorContains(t, <-rejected, "RateLimit")
+	assert.Equal(t, 20, w.Load().RateLimit)
+}
+
+// TestWatcherSymlinkSwap tests noticing the symlink swaps of ConfigMap volumes.
+func TestWatcherSymlinkSwap(t *testing.T) {
+	dir := t.TempDir()
+	for _, version := range []string{"v1", "v2"} {
+		require.NoError(t, os.Mkdir(filepath.Join(dir, version), 0o755))
+	}
+	writeFile(t, filepath.Join(dir, "v1"), "app.yaml", "RateLimit: 1\n")
+	writeFile(t, filepath.Join(dir, "v2"), "app.yaml", "RateLimit: 2\n")
+
+	// ConfigMaps link the file to ..data/app.yaml and swap ..data
+	require.NoError(t, os.Symlink("v1", filepath.Join(dir, "..data")))
+	require.NoError(t, os.Symlink(filepath.Join("..data", "app.yaml"), filepath.Join(dir, "app.yaml")))
+
+	w, err := NewWatcher[flagsConfig](filepath.Join(dir, "app.yaml"), UseWatchInterval(10*time.Millisecond))
+	require.NoError(t, err)
+	changes := make(chan change, 1)
+	w.Subscribe(func(old, new *flagsConfig) {
+		changes <- change{old, new}
+	})
+	w.Start()
+	defer w.Stop()
+
+	require.NoError(t, os.Symlink("v2", filepath.Join(dir, "..data_tmp")))
+	require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+	assert.Equal(t, 2, waitChange(t, changes).new.RateLimit)
+}
+
+// TestWatcherSubscribe tests unsubscribing and skipping unchanged configs.
+func TestWatcherSubscribe(t *testing.T) {
+	dir := t.TempDir()
+	file := writeFile(t, dir, "app.yaml", "RateLimit: 1\n")
+
+	w, err := NewWatcher[flagsConfig](file)
+	require.NoError(t, err)
+
+	calls := 0
+	unsubscribe := w.Subscribe(func(old, new *flagsConfig) {
+		calls++
+	})
+
+	require.NoError(t, w.Reload())
+	assert.Equal(t, 0, calls)
+
+	writeFile(t, dir, "app.yaml", "RateLimit: 2\n")
+	require.NoError(t, w.Reload())
+	assert.Equal(t, 1, calls)
+
+	unsubscribe()
+	writeFile(t, dir, "app.yaml", "RateLimit: 3\n")
+	require.NoError(t, w.Reload())
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, 3, w.Load().RateLimit)
+
+	_, err = NewWatcher[flagsConfig](filepath.Join(dir, "missing.yaml"))
+	assert.Error(t, err)
+}
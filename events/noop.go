@@ -1,24 +1,54 @@
 package events
 
 import (
+	"context"
 	"log"
 	"time"
+
+	"github.com/templwind/soul/pubsub"
 )
 
 // NoOpBroker is a no-op implementation of the pubsub.Broker interface for when NATS is not available
 type NoOpBroker struct{}
 
-func (b *NoOpBroker) Publish(subject string, message []byte, msgID ...string) error {
+func (b *NoOpBroker) Publish(ctx context.Context, subject string, message []byte, msgID ...string) error {
 	log.Printf("NoOpBroker: Would publish to subject %s", subject)
 	return nil
 }
 
-func (b *NoOpBroker) Subscribe(subject string, group string, handler func([]byte) ([]byte, error)) error {
-	log.Printf("NoOpBroker: Would subscribe to subject %s with group %s", subject, group)
+func (b *NoOpBroker) PublishMsg(ctx context.Context, msg *pubsub.Message) error {
+	log.Printf("NoOpBroker: Would publish to subject %s", msg.Subject)
 	return nil
 }
 
+func (b *NoOpBroker) Subscribe(ctx context.Context, subject string, group string, handler pubsub.Handler) (pubsub.Subscription, error) {
+	log.Printf("NoOpBroker: Would subscribe to subject %s with group %s", subject, group)
+	return noOpSubscription(subject), nil
+}
+
+func (b *NoOpBroker) Request(ctx context.Context, subject string, message []byte, timeout time.Duration) (*pubsub.Message, error) {
+	log.Printf("NoOpBroker: Would request on subject %s", subject)
+	return nil, pubsub.ErrNoResponder
+}
+
 func (b *NoOpBroker) CreateStream(streamName, subject string, dedupWindow time.Duration) error {
 	log.Printf("NoOpBroker: Would create stream %s for subject %s with dedup window %v", streamName, subject, dedupWindow)
 	return nil
 }
+
+func (b *NoOpBroker) Close() error {
+	return nil
+}
+
+// noOpSubscription is the subscription handle returned by NoOpBroker
+type noOpSubscription string
+
+func (s noOpSubscription) Subject() string {
+	return string(s)
+}
+
+func (s noOpSubscription) Unsubscribe() error {
+	return nil
+}
+
+var _ pubsub.Broker = (*NoOpBroker)(nil)
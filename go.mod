@@ -3,8 +3,11 @@ module github.com/templwind/soul
 go 1.22.5
 
 require (
+	github.com/DATA-DOG/go-sqlmock v1.5.2
 	github.com/a-h/templ v0.2.793
+	github.com/alicebob/miniredis/v2 v2.39.0
 	github.com/denisenkom/go-mssqldb v0.12.3
+	github.com/fxamacker/cbor/v2 v2.7.0
 	github.com/gobwas/ws v1.4.0
 	github.com/google/uuid v1.6.0
 	github.com/jackc/pgx/v5 v5.6.0
@@ -17,6 +20,7 @@ require (
 	github.com/redis/go-redis/v9 v9.7.0
 	github.com/sijms/go-ora v1.3.2
 	github.com/stretchr/testify v1.9.0
+	github.com/vmihailenco/msgpack/v5 v5.4.1
 	github.com/xo/dburl v0.23.3
 	github.com/yuin/goldmark v1.7.8
 	github.com/zeromicro/go-zero v1.7.3
@@ -80,6 +84,9 @@ require (
 	github.com/spaolacci/murmur3 v1.1.0 // indirect
 	github.com/valyala/bytebufferpool v1.0.0 // indirect
 	github.com/valyala/fasttemplate v1.2.2 // indirect
+	github.com/vmihailenco/tagparser/v2 v2.0.0 // indirect
+	github.com/x448/float16 v0.8.4 // indirect
+	github.com/yuin/gopher-lua v1.1.1 // indirect
 	go.opentelemetry.io/otel v1.24.0 // indirect
 	go.opentelemetry.io/otel/exporters/jaeger v1.17.0 // indirect
 	go.opentelemetry.io/otel/exporters/otlp/otlptrace v1.24.0 // indirect
@@ -4,19 +4,20 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"sort"
 	"strings"
 )
 
 func IsHtmxRequest(r *http.Request) bool {
-	return r.Header.Get("HX-Request") == "true"
+	return r.Header.Get(HeaderRequest) == "true"
 }
 
 func IsHtmxBoosted(r *http.Request) bool {
-	return r.Header.Get("HX-Boosted") == "true"
+	return r.Header.Get(HeaderBoosted) == "true"
 }
 
 func IsHtmxHistoryRestoreRequest(r *http.Request) bool {
-	return r.Header.Get("HX-History-Restore-Request") == "true"
+	return r.Header.Get(HeaderHistoryRestoreRequest) == "true"
 }
 
 func Redirect(w http.ResponseWriter, r *http.Request, path string) error {
@@ -34,9 +35,32 @@ func Redirect(w http.ResponseWriter, r *http.Request, path string) error {
 	return nil
 }
 
+// LocationMap is the value of an HX-Location header, the path to load and
+// the options of the ajax request loading it
 type LocationMap struct {
-	Path   string `json:"path"`
-	Target string `json:"target"`
+	Path string `json:"path"`
+	// Source is the selector of the source element of the request
+	Source string `json:"source,omitempty"`
+	// Event is the name of the event that triggered the request
+	Event string `json:"event,omitempty"`
+	// Handler is the name of a javascript callback handling the response
+	Handler string `json:"handler,omitempty"`
+	// Target is the selector of the element to swap the response into
+	Target string `json:"target,omitempty"`
+	// Swap is how the response is swapped in
+	Swap Swap `json:"swap,omitempty"`
+	// Values are submitted with the request
+	Values map[string]any `json:"values,omitempty"`
+	// Headers are sent with the request
+	Headers map[string]string `json:"headers,omitempty"`
+	// Select is the selector of the part of the response to swap
+	Select string `json:"select,omitempty"`
+}
+
+// isPathOnly reports whether the location has no option besides its path
+func (l LocationMap) isPathOnly() bool {
+	return l.Source == "" && l.Event == "" && l.Handler == "" && l.Target == "" &&
+		l.Swap == "" && len(l.Values) == 0 && len(l.Headers) == 0 && l.Select == ""
 }
 
 func Location(w http.ResponseWriter, r *http.Request, target LocationMap) error {
@@ -54,55 +78,30 @@ func Location(w http.ResponseWriter, r *http.Request, target LocationMap) error
 	return nil
 }
 
+// Trigger adds events to the HX-Trigger header, either a comma separated list
+// of event names or a map of event names to their detail. See Response for
+// triggering events at the other phases.
 func Trigger(w http.ResponseWriter, r *http.Request, newTrigger interface{}) error {
-	existing := w.Header().Get("HX-Trigger")
-	var payloadTriggers map[string]string
-	var eventTriggers []string
-
-	if existing != "" {
-		if strings.HasPrefix(existing, "{") {
-			err := json.Unmarshal([]byte(existing), &payloadTriggers)
-			if err != nil {
-				return fmt.Errorf("failed to unmarshal existing HX-Trigger header: %v", err)
-			}
-		} else {
-			eventTriggers = append(eventTriggers, splitAndTrim(existing, ",")...)
-		}
-	} else {
-		payloadTriggers = make(map[string]string)
-	}
-
+	var events []event
 	switch v := newTrigger.(type) {
 	case string:
-		triggers := splitAndTrim(v, ",")
-		eventTriggers = append(eventTriggers, triggers...)
+		for _, name := range splitAndTrim(v, ",") {
+			if name != "" {
+				events = append(events, event{name: name})
+			}
+		}
 	case map[string]string:
-		for key, value := range v {
-			payloadTriggers[key] = value
+		for _, name := range sortedKeys(v) {
+			events = append(events, event{name: name, detail: v[name]})
+		}
+	case map[string]any:
+		for _, name := range sortedKeys(v) {
+			events = append(events, event{name: name, detail: v[name]})
 		}
 	default:
 		return fmt.Errorf("unsupported trigger type: %T", newTrigger)
 	}
-
-	var combinedTrigger string
-	if len(payloadTriggers) > 0 {
-		combinedJSON, err := json.Marshal(payloadTriggers)
-		if err != nil {
-			return fmt.Errorf("failed to marshal combined HX-Trigger header: %v", err)
-		}
-		combinedTrigger = string(combinedJSON)
-	}
-
-	if len(eventTriggers) > 0 {
-		if combinedTrigger != "" {
-			combinedTrigger += "," + strings.Join(eventTriggers, ",")
-		} else {
-			combinedTrigger = strings.Join(eventTriggers, ",")
-		}
-	}
-
-	w.Header().Set("HX-Trigger", combinedTrigger)
-	return nil
+	return mergeTriggers(w.Header(), HeaderTrigger, events)
 }
 
 func PushUrl(w http.ResponseWriter, url string) {
@@ -130,19 +129,11 @@ func Reselect(w http.ResponseWriter, selector string) {
 }
 
 func TriggerAfterSettle(w http.ResponseWriter, event string) {
-	existing := w.Header().Get("HX-Trigger-After-Settle")
-	if existing != "" {
-		event = existing + "," + event
-	}
-	w.Header().Set("HX-Trigger-After-Settle", event)
+	_ = NewResponse().TriggerAfterSettle(event, nil).Write(w)
 }
 
 func TriggerAfterSwap(w http.ResponseWriter, event string) {
-	existing := w.Header().Get("HX-Trigger-After-Swap")
-	if existing != "" {
-		event = existing + "," + event
-	}
-	w.Header().Set("HX-Trigger-After-Swap", event)
+	_ = NewResponse().TriggerAfterSwap(event, nil).Write(w)
 }
 
 func splitAndTrim(s, sep string) []string {
@@ -152,3 +143,12 @@ func splitAndTrim(s, sep string) []string {
 	}
 	return parts
 }
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,172 @@
+package htmx
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/templwind/soul"
+)
+
+// OOB composes a response of a main component swapped into the target of
+// the request and out-of-band components swapped into elements by id:
+//
+//	return htmx.NewOOB(view.Item(item)).
+//	    Add("toasts", htmx.SwapBeforeEnd, view.Toast("Item added")).
+//	    Add("item-count", htmx.SwapInnerHTML, view.Count(count)).
+//	    Add("sidebar", htmx.SwapOuterHTML, view.Sidebar(items)).
+//	    Render(c, http.StatusOK)
+//
+// Out-of-band swaps use only the style of their Swap, htmx ignores modifiers.
+// sse.SendComponent sends the Component of an OOB as a single event.
+type OOB struct {
+	main  templ.Component
+	swaps []oobSwap
+}
+
+type oobSwap struct {
+	// target is the CSS selector of the elements swapped, empty for a
+	// component setting hx-swap-oob itself
+	target    string
+	style     string
+	component templ.Component
+}
+
+// NewOOB returns an OOB with main as the main content, nil for none
+func NewOOB(main templ.Component) *OOB {
+	return &OOB{main: main}
+}
+
+// Add swaps component into the element with id, an empty swap defaults to
+// outerHTML. Deletes take a nil component. An empty id adds a component that
+// sets hx-swap-oob on its own root as is.
+func (o *OOB) Add(id string, swap Swap, component templ.Component) *OOB {
+	if id == "" {
+		return o.AddTarget("", swap, component)
+	}
+	return o.AddTarget("#"+id, swap, component)
+}
+
+// AddTarget swaps component into the elements matching the CSS selector
+// target, e.g. ul[data-list="todo"]
+func (o *OOB) AddTarget(target string, swap Swap, component templ.Component) *OOB {
+	style := string(SwapOuterHTML)
+	if fields := strings.Fields(string(swap)); len(fields) > 0 {
+		style = fields[0]
+	}
+	o.swaps = append(o.swaps, oobSwap{target: target, style: style, component: component})
+	return o
+}
+
+// Component returns the main component followed by the out-of-band ones.
+// outerHTML swaps mark the root element of their component, so it replaces
+// the target, other swaps wrap their component in a div whose children are
+// swapped.
+func (o *OOB) Component() templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		if o.main != nil {
+			if err := o.main.Render(ctx, w); err != nil {
+				return err
+			}
+		}
+		for _, s := range o.swaps {
+			if err := s.render(ctx, w); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Render renders the composed response with soul.Render, use Response.Render
+// to set htmx headers as well
+func (o *OOB) Render(c echo.Context, status int) error {
+	return soul.Render(c, status, o.Component())
+}
+
+func (s oobSwap) render(ctx context.Context, w io.Writer) error {
+	if s.target == "" {
+		if s.component == nil {
+			return nil
+		}
+		return s.component.Render(ctx, w)
+	}
+
+	if s.style != string(SwapOuterHTML) {
+		attr := html.EscapeString(s.style + ":" + s.target)
+		if _, err := io.WriteString(w, `<div hx-swap-oob="`+attr+`">`); err != nil {
+			return err
+		}
+		if s.component != nil {
+			if err := s.component.Render(ctx, w); err != nil {
+				return err
+			}
+		}
+		_, err := io.WriteString(w, "</div>")
+		return err
+	}
+
+	if s.component == nil {
+		return fmt.Errorf("out-of-band swap of %s: no component to swap", s.target)
+	}
+	var buf bytes.Buffer
+	if err := s.component.Render(ctx, &buf); err != nil {
+		return err
+	}
+	marked, err := markRoot(buf.Bytes(), ` hx-swap-oob="`+html.EscapeString(s.style+":"+s.target)+`"`)
+	if err != nil {
+		return fmt.Errorf("out-of-band swap of %s: %w", s.target, err)
+	}
+	_, err = w.Write(marked)
+	return err
+}
+
+// markRoot adds attr to the first element of fragment, skipping comments
+func markRoot(fragment []byte, attr string) ([]byte, error) {
+	rest := fragment
+	offset := 0
+	for {
+		i := bytes.IndexByte(rest, '<')
+		if i < 0 || i+1 >= len(rest) {
+			return nil, fmt.Errorf("no element to swap")
+		}
+		if bytes.HasPrefix(rest[i:], []byte("<!--")) {
+			end := bytes.Index(rest[i:], []byte("-->"))
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			offset += i + end + 3
+			rest = rest[i+end+3:]
+			continue
+		}
+		if !isLetter(rest[i+1]) {
+			offset += i + 1
+			rest = rest[i+1:]
+			continue
+		}
+
+		// Insert the attribute after the tag name
+		end := i + 1
+		for end < len(rest) && !isTagNameEnd(rest[end]) {
+			end++
+		}
+		at := offset + end
+		marked := make([]byte, 0, len(fragment)+len(attr))
+		marked = append(marked, fragment[:at]...)
+		marked = append(marked, attr...)
+		return append(marked, fragment[at:]...), nil
+	}
+}
+
+func isLetter(b byte) bool {
+	return b >= 'a' && b <= 'z' || b >= 'A' && b <= 'Z'
+}
+
+func isTagNameEnd(b byte) bool {
+	return b == ' ' || b == '>' || b == '/' || b == '\t' || b == '\n' || b == '\r'
+}
@@ -0,0 +1,76 @@
+package htmx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func raw(s string) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, s)
+		return err
+	})
+}
+
+// TestOOBRender tests composing a main component with out-of-band swaps.
+func TestOOBRender(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/items", nil), rec)
+
+	setsCookie := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		c.SetCookie(&http.Cookie{Name: "seen", Value: "1"})
+		_, err := io.WriteString(w, "<li>item</li>")
+		return err
+	})
+
+	err := NewOOB(setsCookie).
+		Add("toasts", SwapBeforeEnd.Transition(), raw("<p>Added</p>")).
+		Add("count", SwapInnerHTML, raw("3")).
+		Add("sidebar", SwapOuterHTML, raw("<!-- sidebar -->\n<aside class=\"side\">menu</aside>")).
+		Add("empty", SwapDelete, nil).
+		Render(c, http.StatusCreated)
+	require.NoError(t, err)
+
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Header().Get("Set-Cookie"), "seen=1")
+	assert.Equal(t, `<li>item</li>`+
+		`<div hx-swap-oob="beforeend:#toasts"><p>Added</p></div>`+
+		`<div hx-swap-oob="innerHTML:#count">3</div>`+
+		"<!-- sidebar -->\n"+`<aside hx-swap-oob="outerHTML:#sidebar" class="side">menu</aside>`+
+		`<div hx-swap-oob="delete:#empty"></div>`, rec.Body.String())
+}
+
+// TestOOBWithoutElement tests that outerHTML swaps need an element.
+func TestOOBWithoutElement(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := NewOOB(nil).Add("count", SwapOuterHTML, raw("3 < 4")).Component().Render(context.Background(), rec)
+	assert.ErrorContains(t, err, "#count")
+
+	for _, swap := range []Swap{SwapOuterHTML, ""} {
+		err = NewOOB(nil).Add("count", swap, nil).Component().Render(context.Background(), rec)
+		assert.EqualError(t, err, "out-of-band swap of #count: no component to swap")
+	}
+}
+
+// TestOOBTargets tests swaps into CSS selectors and components marking themselves.
+func TestOOBTargets(t *testing.T) {
+	var buf bytes.Buffer
+	err := NewOOB(nil).
+		AddTarget(`ul[data-id="7"]`, SwapBeforeEnd, raw("<li>7</li>")).
+		Add("", "", raw(`<p id="status" hx-swap-oob="true">ok</p>`)).
+		Component().Render(context.Background(), &buf)
+	require.NoError(t, err)
+
+	assert.Equal(t, `<div hx-swap-oob="beforeend:ul[data-id=&#34;7&#34;]"><li>7</li></div>`+
+		`<p id="status" hx-swap-oob="true">ok</p>`, buf.String())
+}
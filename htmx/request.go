@@ -0,0 +1,149 @@
+package htmx
+
+import (
+	"net/http"
+	"net/url"
+
+	"github.com/labstack/echo/v4"
+)
+
+// The request headers of htmx, HX-Trigger is shared with the response
+const (
+	HeaderRequest               = "HX-Request"
+	HeaderBoosted               = "HX-Boosted"
+	HeaderCurrentURL            = "HX-Current-URL"
+	HeaderHistoryRestoreRequest = "HX-History-Restore-Request"
+	HeaderPrompt                = "HX-Prompt"
+	HeaderTarget                = "HX-Target"
+	HeaderTriggerName           = "HX-Trigger-Name"
+)
+
+// requestKey is the key of the Request in the echo context
+const requestKey = "htmx.request"
+
+// Request describes the htmx headers of a request
+type Request struct {
+	// Enabled is true for requests made by htmx
+	Enabled bool
+	// Boosted is true for requests of boosted links and forms
+	Boosted bool
+	// HistoryRestore is true when htmx restores a page missing from its history cache
+	HistoryRestore bool
+	// CurrentURL is the url of the page making the request
+	CurrentURL string
+	// Prompt is the answer of the user to hx-prompt
+	Prompt string
+	// Target is the id of the target element
+	Target string
+	// Trigger is the id of the element that triggered the request
+	Trigger string
+	// TriggerName is the name of the element that triggered the request
+	TriggerName string
+}
+
+// ParseRequest returns the htmx headers of r. Prompts are decoded if htmx
+// sent them URI encoded.
+func ParseRequest(r *http.Request) *Request {
+	prompt := r.Header.Get(HeaderPrompt)
+	if decoded, err := url.QueryUnescape(prompt); err == nil && r.Header.Get(HeaderPrompt+"-URI-AutoEncoded") == "true" {
+		prompt = decoded
+	}
+	return &Request{
+		Enabled:        r.Header.Get(HeaderRequest) == "true",
+		Boosted:        r.Header.Get(HeaderBoosted) == "true",
+		HistoryRestore: r.Header.Get(HeaderHistoryRestoreRequest) == "true",
+		CurrentURL:     r.Header.Get(HeaderCurrentURL),
+		Prompt:         prompt,
+		Target:         r.Header.Get(HeaderTarget),
+		Trigger:        r.Header.Get(HeaderTrigger),
+		TriggerName:    r.Header.Get(HeaderTriggerName),
+	}
+}
+
+// GetRequest returns the htmx headers of the request of c, as attached by
+// Middleware or parsed if the middleware is not in use
+func GetRequest(c echo.Context) *Request {
+	if req, ok := c.Get(requestKey).(*Request); ok {
+		return req
+	}
+	return ParseRequest(c.Request())
+}
+
+// MiddlewareOpt configures the Middleware
+type MiddlewareOpt func(*middleware)
+
+type middleware struct {
+	redirects   bool
+	errorTarget string
+	errorSwap   Swap
+}
+
+// KeepRedirects leaves 3xx redirects of htmx requests to the browser,
+// which follows them and swaps the page redirected to
+func KeepRedirects() MiddlewareOpt {
+	return func(m *middleware) {
+		m.redirects = false
+	}
+}
+
+// WithErrorTarget swaps the error responses of htmx requests into the
+// element matching selector, e.g. #errors
+func WithErrorTarget(selector string, swap Swap) MiddlewareOpt {
+	return func(m *middleware) {
+		m.errorTarget = selector
+		m.errorSwap = swap
+	}
+}
+
+// Middleware attaches the htmx headers of a request to the echo context,
+// see GetRequest, and adds Vary: HX-Request so caches keep full pages and
+// fragments apart. For htmx requests it
+//   - turns 3xx redirects into HX-Redirect, so the browser loads the page
+//     rather than htmx swapping it into the target
+//   - responds to errors with 200, HX-Retarget and HX-Reswap when an error
+//     target is set, as htmx does not swap error responses by default.
+//     Responses that already retarget are left alone.
+func Middleware(opts ...MiddlewareOpt) echo.MiddlewareFunc {
+	m := &middleware{redirects: true}
+	for _, opt := range opts {
+		opt(m)
+	}
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			req := ParseRequest(c.Request())
+			c.Set(requestKey, req)
+
+			res := c.Response()
+			res.Header().Add(echo.HeaderVary, HeaderRequest)
+			if req.Enabled {
+				res.Before(func() {
+					m.rewrite(res)
+				})
+			}
+			return next(c)
+		}
+	}
+}
+
+// rewrite adjusts the status and headers of a response to an htmx request
+// before they are written
+func (m *middleware) rewrite(res *echo.Response) {
+	h := res.Header()
+	switch {
+	case m.redirects && isRedirect(res.Status) && h.Get(echo.HeaderLocation) != "":
+		h.Set(HeaderRedirect, h.Get(echo.HeaderLocation))
+		h.Del(echo.HeaderLocation)
+		res.Status = http.StatusOK
+	case m.errorTarget != "" && res.Status >= http.StatusBadRequest && h.Get(HeaderRetarget) == "":
+		h.Set(HeaderRetarget, m.errorTarget)
+		if m.errorSwap != "" {
+			h.Set(HeaderReswap, string(m.errorSwap))
+		}
+		res.Status = http.StatusOK
+	}
+}
+
+func isRedirect(status int) bool {
+	return status >= http.StatusMultipleChoices && status < http.StatusBadRequest && status != http.StatusNotModified
+}
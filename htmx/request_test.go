@@ -0,0 +1,109 @@
+package htmx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseRequest tests reading the htmx request headers.
+func TestParseRequest(t *testing.T) {
+	r := httptest.NewRequest(http.MethodPost, "/items", nil)
+	r.Header.Set(HeaderRequest, "true")
+	r.Header.Set(HeaderBoosted, "true")
+	r.Header.Set(HeaderCurrentURL, "http://localhost/items")
+	r.Header.Set(HeaderPrompt, "Caf%C3%A9")
+	r.Header.Set(HeaderPrompt+"-URI-AutoEncoded", "true")
+	r.Header.Set(HeaderTarget, "list")
+	r.Header.Set(HeaderTrigger, "add")
+	r.Header.Set(HeaderTriggerName, "name")
+
+	assert.Equal(t, &Request{
+		Enabled:     true,
+		Boosted:     true,
+		CurrentURL:  "http://localhost/items",
+		Prompt:      "Café",
+		Target:      "list",
+		Trigger:     "add",
+		TriggerName: "name",
+	}, ParseRequest(r))
+
+	assert.False(t, ParseRequest(httptest.NewRequest(http.MethodGet, "/", nil)).Enabled)
+}
+
+func serve(mw echo.MiddlewareFunc, htmx bool, handler echo.HandlerFunc) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.Use(mw)
+	e.GET("/", handler)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	if htmx {
+		req.Header.Set(HeaderRequest, "true")
+		req.Header.Set(HeaderTarget, "main")
+	}
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestMiddleware tests attaching the request and adding Vary.
+func TestMiddleware(t *testing.T) {
+	var req *Request
+	rec := serve(Middleware(), true, func(c echo.Context) error {
+		req = GetRequest(c)
+		return c.String(http.StatusOK, "ok")
+	})
+	assert.Equal(t, "main", req.Target)
+	assert.Equal(t, HeaderRequest, rec.Header().Get(echo.HeaderVary))
+}
+
+// TestMiddlewareRedirects tests turning redirects into HX-Redirect.
+func TestMiddlewareRedirects(t *testing.T) {
+	redirect := func(c echo.Context) error {
+		return c.Redirect(http.StatusSeeOther, "/login")
+	}
+
+	rec := serve(Middleware(), true, redirect)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "/login", rec.Header().Get(HeaderRedirect))
+	assert.Empty(t, rec.Header().Get(echo.HeaderLocation))
+
+	rec = serve(Middleware(), false, redirect)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Equal(t, "/login", rec.Header().Get(echo.HeaderLocation))
+
+	rec = serve(Middleware(KeepRedirects()), true, redirect)
+	assert.Equal(t, http.StatusSeeOther, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderRedirect))
+}
+
+// TestMiddlewareErrors tests swapping error responses into the error target.
+func TestMiddlewareErrors(t *testing.T) {
+	mw := Middleware(WithErrorTarget("#errors", SwapInnerHTML))
+	failing := func(c echo.Context) error {
+		return c.String(http.StatusBadRequest, "invalid")
+	}
+
+	rec := serve(mw, true, failing)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "#errors", rec.Header().Get(HeaderRetarget))
+	assert.Equal(t, "innerHTML", rec.Header().Get(HeaderReswap))
+	assert.Equal(t, "invalid", rec.Body.String())
+
+	rec = serve(mw, true, func(c echo.Context) error {
+		_ = NewResponse().Retarget("#toasts").Apply(c)
+		return c.String(http.StatusConflict, "taken")
+	})
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "#toasts", rec.Header().Get(HeaderRetarget))
+
+	rec = serve(mw, false, failing)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	rec = serve(Middleware(), true, failing)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Empty(t, rec.Header().Get(HeaderRetarget))
+}
@@ -0,0 +1,181 @@
+package htmx
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/templwind/soul"
+)
+
+// The response headers of htmx
+const (
+	HeaderLocation           = "HX-Location"
+	HeaderPushURL            = "HX-Push-Url"
+	HeaderRedirect           = "HX-Redirect"
+	HeaderRefresh            = "HX-Refresh"
+	HeaderReplaceURL         = "HX-Replace-Url"
+	HeaderReswap             = "HX-Reswap"
+	HeaderRetarget           = "HX-Retarget"
+	HeaderReselect           = "HX-Reselect"
+	HeaderTrigger            = "HX-Trigger"
+	HeaderTriggerAfterSettle = "HX-Trigger-After-Settle"
+	HeaderTriggerAfterSwap   = "HX-Trigger-After-Swap"
+)
+
+// Response builds the htmx headers of a response:
+//
+//	return htmx.NewResponse().
+//	    Retarget("#list").
+//	    Reswap(htmx.SwapBeforeEnd.Transition()).
+//	    Trigger("item-added", map[string]any{"id": item.ID}).
+//	    Render(c, http.StatusOK, view.Item(item))
+//
+// Triggers are merged with the events already set on the response, the
+// other headers replace their previous value.
+type Response struct {
+	headers             map[string]string
+	triggers            []event
+	triggersAfterSwap   []event
+	triggersAfterSettle []event
+	err                 error
+}
+
+// NewResponse returns an empty Response
+func NewResponse() *Response {
+	return &Response{headers: map[string]string{}}
+}
+
+// Location makes htmx load path with an ajax request, as if a boosted link was followed
+func (r *Response) Location(path string) *Response {
+	return r.LocationWith(LocationMap{Path: path})
+}
+
+// LocationWith is Location with the options of the request, such as its target and swap
+func (r *Response) LocationWith(location LocationMap) *Response {
+	if location.Path == "" {
+		r.err = errors.New("location path cannot be empty")
+		return r
+	}
+	if location.isPathOnly() {
+		r.headers[HeaderLocation] = location.Path
+		return r
+	}
+
+	data, err := json.Marshal(location)
+	if err != nil {
+		r.err = fmt.Errorf("failed to marshal HX-Location header: %w", err)
+		return r
+	}
+	r.headers[HeaderLocation] = string(data)
+	return r
+}
+
+// Redirect makes the browser load url with a full page load
+func (r *Response) Redirect(url string) *Response {
+	r.headers[HeaderRedirect] = url
+	return r
+}
+
+// Refresh makes the browser refresh the page
+func (r *Response) Refresh() *Response {
+	r.headers[HeaderRefresh] = "true"
+	return r
+}
+
+// PushURL pushes url into the history of the browser, "false" prevents the push
+func (r *Response) PushURL(url string) *Response {
+	r.headers[HeaderPushURL] = url
+	return r
+}
+
+// ReplaceURL replaces the current url in the location bar, "false" prevents the replacement
+func (r *Response) ReplaceURL(url string) *Response {
+	r.headers[HeaderReplaceURL] = url
+	return r
+}
+
+// Reswap overrides how the response is swapped
+func (r *Response) Reswap(swap Swap) *Response {
+	r.headers[HeaderReswap] = string(swap)
+	return r
+}
+
+// Retarget swaps the response into the element matching selector
+func (r *Response) Retarget(selector string) *Response {
+	r.headers[HeaderRetarget] = selector
+	return r
+}
+
+// Reselect swaps the part of the response matching selector
+func (r *Response) Reselect(selector string) *Response {
+	r.headers[HeaderReselect] = selector
+	return r
+}
+
+// Trigger triggers event on the target as soon as the response is received.
+// detail is encoded as JSON and becomes the detail of the event, nil for none.
+func (r *Response) Trigger(event string, detail any) *Response {
+	r.triggers = appendEvent(r.triggers, event, detail)
+	return r
+}
+
+// TriggerAfterSwap triggers event after the swap step
+func (r *Response) TriggerAfterSwap(event string, detail any) *Response {
+	r.triggersAfterSwap = appendEvent(r.triggersAfterSwap, event, detail)
+	return r
+}
+
+// TriggerAfterSettle triggers event after the settle step
+func (r *Response) TriggerAfterSettle(event string, detail any) *Response {
+	r.triggersAfterSettle = appendEvent(r.triggersAfterSettle, event, detail)
+	return r
+}
+
+// Write sets the headers on w. It fails if an option was invalid or an
+// existing trigger header cannot be parsed.
+func (r *Response) Write(w http.ResponseWriter) error {
+	if r.err != nil {
+		return r.err
+	}
+
+	h := w.Header()
+	for key, value := range r.headers {
+		h.Set(key, value)
+	}
+	if err := mergeTriggers(h, HeaderTrigger, r.triggers); err != nil {
+		return err
+	}
+	if err := mergeTriggers(h, HeaderTriggerAfterSwap, r.triggersAfterSwap); err != nil {
+		return err
+	}
+	return mergeTriggers(h, HeaderTriggerAfterSettle, r.triggersAfterSettle)
+}
+
+// Apply sets the headers on the response of c
+func (r *Response) Apply(c echo.Context) error {
+	return r.Write(c.Response())
+}
+
+// NoContent sets the headers and responds 204 No Content
+func (r *Response) NoContent(c echo.Context) error {
+	if err := r.Apply(c); err != nil {
+		return err
+	}
+	return c.NoContent(http.StatusNoContent)
+}
+
+// Render sets the headers and renders component with soul.Render
+func (r *Response) Render(c echo.Context, status int, component templ.Component) error {
+	if err := r.Apply(c); err != nil {
+		return err
+	}
+	return soul.Render(c, status, component)
+}
+
+func appendEvent(events []event, name string, detail any) []event {
+	return append(events, event{name: name, detail: detail})
+}
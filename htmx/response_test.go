@@ -0,0 +1,118 @@
+package htmx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestResponseHeaders tests setting every response header.
+func TestResponseHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := NewResponse().
+		Redirect("/login").
+		Refresh().
+		PushURL("/items").
+		ReplaceURL("false").
+		Reswap(SwapOuterHTML.Transition().Swap(100*time.Millisecond).Settle(time.Second).Scroll("#list", "bottom")).
+		Retarget("#list").
+		Reselect(".item").
+		Location("/items/1").
+		Write(rec)
+	require.NoError(t, err)
+
+	h := rec.Header()
+	assert.Equal(t, "/login", h.Get(HeaderRedirect))
+	assert.Equal(t, "true", h.Get(HeaderRefresh))
+	assert.Equal(t, "/items", h.Get(HeaderPushURL))
+	assert.Equal(t, "false", h.Get(HeaderReplaceURL))
+	assert.Equal(t, "outerHTML transition:true swap:100ms settle:1000ms scroll:#list:bottom", h.Get(HeaderReswap))
+	assert.Equal(t, "#list", h.Get(HeaderRetarget))
+	assert.Equal(t, ".item", h.Get(HeaderReselect))
+	assert.Equal(t, "/items/1", h.Get(HeaderLocation))
+}
+
+// TestResponseLocation tests HX-Location with options.
+func TestResponseLocation(t *testing.T) {
+	rec := httptest.NewRecorder()
+	err := NewResponse().LocationWith(LocationMap{
+		Path:    "/items",
+		Target:  "#main",
+		Swap:    SwapInnerHTML.Show("", "top"),
+		Values:  map[string]any{"page": 2},
+		Headers: map[string]string{"X-Source": "menu"},
+	}).Write(rec)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"path":"/items","target":"#main","swap":"innerHTML show:top","values":{"page":2},"headers":{"X-Source":"menu"}}`, rec.Header().Get(HeaderLocation))
+
+	assert.Error(t, NewResponse().Location("").Write(httptest.NewRecorder()))
+}
+
+// TestResponseTriggers tests merging triggers with details at every phase.
+func TestResponseTriggers(t *testing.T) {
+	rec := httptest.NewRecorder()
+	rec.Header().Set(HeaderTrigger, "first, second")
+	rec.Header().Set(HeaderTriggerAfterSwap, `{"swapped":{"id":1}}`)
+
+	err := NewResponse().
+		Trigger("second", map[string]any{"count": 2}).
+		Trigger("third", nil).
+		TriggerAfterSwap("done", "yes").
+		TriggerAfterSettle("settled", nil).
+		TriggerAfterSettle("focus", nil).
+		Write(rec)
+	require.NoError(t, err)
+
+	h := rec.Header()
+	assert.Equal(t, `{"first":null,"second":{"count":2},"third":null}`, h.Get(HeaderTrigger))
+	assert.Equal(t, `{"swapped":{"id":1},"done":"yes"}`, h.Get(HeaderTriggerAfterSwap))
+	assert.Equal(t, "settled,focus", h.Get(HeaderTriggerAfterSettle))
+
+	rec.Header().Set(HeaderTrigger, "{broken")
+	assert.Error(t, NewResponse().Trigger("x", nil).Write(rec))
+}
+
+// TestTriggerCompatibility tests the header helpers on top of the merging.
+func TestTriggerCompatibility(t *testing.T) {
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	require.NoError(t, Trigger(rec, req, "a, b"))
+	require.NoError(t, Trigger(rec, req, map[string]string{"c": "d"}))
+	assert.Equal(t, `{"a":null,"b":null,"c":"d"}`, rec.Header().Get(HeaderTrigger))
+	assert.Error(t, Trigger(rec, req, 42))
+
+	TriggerAfterSwap(rec, "x")
+	TriggerAfterSwap(rec, "y")
+	assert.Equal(t, "x,y", rec.Header().Get(HeaderTriggerAfterSwap))
+}
+
+// TestResponseRender tests the echo convenience methods.
+func TestResponseRender(t *testing.T) {
+	e := echo.New()
+	rec := httptest.NewRecorder()
+	c := e.NewContext(httptest.NewRequest(http.MethodPost, "/", nil), rec)
+
+	component := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, "<li>item</li>")
+		return err
+	})
+	require.NoError(t, NewResponse().Trigger("added", nil).Render(c, http.StatusCreated, component))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "added", rec.Header().Get(HeaderTrigger))
+	assert.Equal(t, "<li>item</li>", rec.Body.String())
+
+	rec = httptest.NewRecorder()
+	c = e.NewContext(httptest.NewRequest(http.MethodDelete, "/", nil), rec)
+	require.NoError(t, NewResponse().Refresh().NoContent(c))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get(HeaderRefresh))
+}
@@ -0,0 +1,75 @@
+package htmx
+
+import (
+	"fmt"
+	"time"
+)
+
+// Swap is an hx-swap value, a swap style followed by its modifiers:
+//
+//	htmx.SwapOuterHTML.Transition().Settle(100 * time.Millisecond)
+type Swap string
+
+// The swap styles of htmx
+const (
+	SwapInnerHTML   Swap = "innerHTML"
+	SwapOuterHTML   Swap = "outerHTML"
+	SwapTextContent Swap = "textContent"
+	SwapBeforeBegin Swap = "beforebegin"
+	SwapAfterBegin  Swap = "afterbegin"
+	SwapBeforeEnd   Swap = "beforeend"
+	SwapAfterEnd    Swap = "afterend"
+	SwapDelete      Swap = "delete"
+	SwapNone        Swap = "none"
+)
+
+// Transition uses the View Transitions API for the swap
+func (s Swap) Transition() Swap {
+	return s.with("transition:true")
+}
+
+// Swap delays the swap by d
+func (s Swap) Swap(d time.Duration) Swap {
+	return s.with("swap:" + duration(d))
+}
+
+// Settle delays the settle step by d
+func (s Swap) Settle(d time.Duration) Swap {
+	return s.with("settle:" + duration(d))
+}
+
+// IgnoreTitle keeps the title of the page even if the response has one
+func (s Swap) IgnoreTitle() Swap {
+	return s.with("ignoreTitle:true")
+}
+
+// Scroll scrolls the target, or the element matching selector, to position top or bottom
+func (s Swap) Scroll(selector, position string) Swap {
+	return s.with("scroll:" + scrollTarget(selector, position))
+}
+
+// Show scrolls the target, or the element matching selector, into view at position top or bottom
+func (s Swap) Show(selector, position string) Swap {
+	return s.with("show:" + scrollTarget(selector, position))
+}
+
+// FocusScroll sets whether focused inputs are scrolled into view
+func (s Swap) FocusScroll(scroll bool) Swap {
+	return s.with(fmt.Sprintf("focus-scroll:%t", scroll))
+}
+
+func (s Swap) with(modifier string) Swap {
+	return s + " " + Swap(modifier)
+}
+
+// duration formats d as htmx time, e.g. 250ms
+func duration(d time.Duration) string {
+	return fmt.Sprintf("%dms", d.Milliseconds())
+}
+
+func scrollTarget(selector, position string) string {
+	if selector == "" {
+		return position
+	}
+	return selector + ":" + position
+}
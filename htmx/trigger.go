@@ -0,0 +1,118 @@
+package htmx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// event is an event triggered by an HX-Trigger header with its detail
+type event struct {
+	name   string
+	detail any
+}
+
+// parseTriggers parses an HX-Trigger header, either a comma separated list
+// of events or a JSON object mapping events to their detail
+func parseTriggers(header string) ([]event, error) {
+	header = strings.TrimSpace(header)
+	if header == "" {
+		return nil, nil
+	}
+
+	if !strings.HasPrefix(header, "{") {
+		var events []event
+		for _, name := range splitAndTrim(header, ",") {
+			if name != "" {
+				events = append(events, event{name: name})
+			}
+		}
+		return events, nil
+	}
+
+	var details map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(header), &details); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal existing HX-Trigger header: %v", err)
+	}
+	names := make([]string, 0, len(details))
+	for name := range details {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	events := make([]event, len(names))
+	for i, name := range names {
+		events[i] = event{name: name, detail: details[name]}
+		if string(details[name]) == "null" {
+			events[i].detail = nil
+		}
+	}
+	return events, nil
+}
+
+// mergeTriggers adds events to the trigger header key of h. Events already
+// in the header keep their position, their detail is replaced.
+func mergeTriggers(h http.Header, key string, events []event) error {
+	if len(events) == 0 {
+		return nil
+	}
+
+	merged, err := parseTriggers(h.Get(key))
+	if err != nil {
+		return err
+	}
+	for _, e := range events {
+		replaced := false
+		for i := range merged {
+			if merged[i].name == e.name {
+				merged[i].detail = e.detail
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, e)
+		}
+	}
+
+	value, err := formatTriggers(merged)
+	if err != nil {
+		return err
+	}
+	h.Set(key, value)
+	return nil
+}
+
+// formatTriggers formats events as a list if none has a detail, as a JSON
+// object in the order of the events otherwise
+func formatTriggers(events []event) (string, error) {
+	withDetail := false
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = e.name
+		withDetail = withDetail || e.detail != nil
+	}
+	if !withDetail {
+		return strings.Join(names, ","), nil
+	}
+
+	var buf bytes.Buffer
+	buf.WriteByte('{')
+	for i, e := range events {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		name, _ := json.Marshal(e.name)
+		detail, err := json.Marshal(e.detail)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal detail of event %s: %w", e.name, err)
+		}
+		buf.Write(name)
+		buf.WriteByte(':')
+		buf.Write(detail)
+	}
+	buf.WriteByte('}')
+	return buf.String(), nil
+}
@@ -0,0 +1,95 @@
+// Package outbox implements the transactional outbox pattern on Postgres.
+//
+// Messages are written with Enqueue in the same transaction as the business
+// data, so they are stored if and only if the transaction commits. A Relay
+// then publishes stored messages to a pubsub.Broker with at-least-once
+// delivery; consumers deduplicate by message ID.
+//
+// Ordering is best effort. A relay publishes each batch in enqueue order and
+// holds back the rest of the batch when a message fails, but later batches
+// and concurrent relays may overtake a message waiting for its retry.
+//
+// A message that still fails after the attempts set with WithMaxAttempts is
+// parked: failed_at is set and the relay moves on. Parked messages keep their
+// last error for inspection, clear failed_at to retry them.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/templwind/soul/pubsub"
+)
+
+// Channel is the Postgres notification channel signalled on enqueue
+const Channel = "soul_outbox"
+
+// Schema creates the outbox table and the index used by the relay
+const Schema = `
+CREATE TABLE IF NOT EXISTS soul_outbox (
+	id           BIGSERIAL PRIMARY KEY,
+	message_id   TEXT NOT NULL,
+	subject      TEXT NOT NULL,
+	payload      BYTEA NOT NULL,
+	headers      JSONB NOT NULL DEFAULT '{}',
+	attempts     INTEGER NOT NULL DEFAULT 0,
+	last_error   TEXT,
+	locked_by    TEXT,
+	locked_until TIMESTAMPTZ,
+	created_at   TIMESTAMPTZ NOT NULL DEFAULT now(),
+	delivered_at TIMESTAMPTZ,
+	failed_at    TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS soul_outbox_pending_idx ON soul_outbox (id) WHERE delivered_at IS NULL AND failed_at IS NULL;
+`
+
+// CreateTable creates the outbox table if it does not exist
+func CreateTable(ctx context.Context, db *sqlx.DB) error {
+	if _, err := db.ExecContext(ctx, Schema); err != nil {
+		return fmt.Errorf("failed to create outbox table: %w", err)
+	}
+	return nil
+}
+
+// Enqueue writes a message for subject to the outbox in tx using the provided msgID for deduplication
+func Enqueue(ctx context.Context, tx *sqlx.Tx, subject string, payload []byte, msgID ...string) error {
+	msg := pubsub.NewMessage(subject, payload)
+	if len(msgID) > 0 {
+		msg.ID = msgID[0]
+	}
+	return EnqueueMsg(ctx, tx, msg)
+}
+
+// EnqueueMsg writes msg including its headers to the outbox in tx. Use
+// pubsub.Encode to enqueue typed messages.
+func EnqueueMsg(ctx context.Context, tx *sqlx.Tx, msg *pubsub.Message) error {
+	if msg.ID == "" {
+		// Generate a unique message ID if none is provided
+		msg.ID = uuid.New().String()
+	}
+	if msg.Header == nil {
+		msg.Header = pubsub.Header{}
+	}
+
+	headers, err := json.Marshal(msg.Header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message headers: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx,
+		`INSERT INTO soul_outbox (message_id, subject, payload, headers) VALUES ($1, $2, $3, $4)`,
+		msg.ID, msg.Subject, msg.Data, headers,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue message: %w", err)
+	}
+
+	// Wake up listening relays once the transaction commits
+	if _, err := tx.ExecContext(ctx, `SELECT pg_notify($1, '')`, Channel); err != nil {
+		return fmt.Errorf("failed to notify outbox relay: %w", err)
+	}
+	return nil
+}
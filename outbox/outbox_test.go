@@ -0,0 +1,67 @@
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/pubsub"
+)
+
+func newMockDB(t *testing.T) (*sqlx.DB, sqlmock.Sqlmock) {
+	t.Helper()
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, mock.ExpectationsWereMet())
+		db.Close()
+	})
+	return sqlx.NewDb(db, "postgres"), mock
+}
+
+// TestEnqueue tests that messages are inserted and the relay notified in the transaction.
+func TestEnqueue(t *testing.T) {
+	db, mock := newMockDB(t)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO soul_outbox`).
+		WithArgs("order-1", "orders.created", []byte(`{"id":1}`), []byte(`{}`)).
+		WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(`SELECT pg_notify`).WithArgs(Channel).WillReturnResult(driver.ResultNoRows)
+	mock.ExpectExec(`INSERT INTO soul_outbox`).
+		WithArgs(sqlmock.AnyArg(), "orders.updated", []byte(`{}`), []byte(`{"Trace":["abc"]}`)).
+		WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectExec(`SELECT pg_notify`).WithArgs(Channel).WillReturnResult(driver.ResultNoRows)
+	mock.ExpectCommit()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	require.NoError(t, Enqueue(ctx, tx, "orders.created", []byte(`{"id":1}`), "order-1"))
+
+	msg := pubsub.NewMessage("orders.updated", []byte(`{}`))
+	msg.Header.Set("Trace", "abc")
+	require.NoError(t, EnqueueMsg(ctx, tx, msg))
+	assert.NotEmpty(t, msg.ID, "a message ID is generated")
+	require.NoError(t, tx.Commit())
+}
+
+// TestEnqueueError tests that a failed insert is returned without notifying.
+func TestEnqueueError(t *testing.T) {
+	db, mock := newMockDB(t)
+	ctx := context.Background()
+
+	mock.ExpectBegin()
+	mock.ExpectExec(`INSERT INTO soul_outbox`).WillReturnError(assert.AnError)
+	mock.ExpectRollback()
+
+	tx, err := db.BeginTxx(ctx, nil)
+	require.NoError(t, err)
+	err = Enqueue(ctx, tx, "orders.created", nil)
+	assert.ErrorIs(t, err, assert.AnError)
+	require.NoError(t, tx.Rollback())
+}
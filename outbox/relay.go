@@ -0,0 +1,311 @@
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/templwind/soul/pubsub"
+)
+
+type (
+	// Option defines the method to customize a Relay.
+	Option func(opt *options)
+
+	options struct {
+		pollInterval    time.Duration
+		batchSize       int
+		lease           time.Duration
+		retryDelay      time.Duration
+		maxAttempts     int
+		retention       time.Duration
+		cleanupInterval time.Duration
+		listenDSN       string
+	}
+)
+
+// WithPollInterval sets how often the outbox is polled for new messages.
+func WithPollInterval(interval time.Duration) Option {
+	return func(opt *options) {
+		opt.pollInterval = interval
+	}
+}
+
+// WithBatchSize sets the number of messages leased per round.
+func WithBatchSize(size int) Option {
+	return func(opt *options) {
+		opt.batchSize = size
+	}
+}
+
+// WithLease sets how long leased messages are reserved for a relay before another may take them.
+func WithLease(lease time.Duration) Option {
+	return func(opt *options) {
+		opt.lease = lease
+	}
+}
+
+// WithRetryDelay sets the delay before a message that failed to publish is retried.
+func WithRetryDelay(delay time.Duration) Option {
+	return func(opt *options) {
+		opt.retryDelay = delay
+	}
+}
+
+// WithMaxAttempts sets how many times a message is published before it is
+// parked, zero retries it until it is delivered.
+func WithMaxAttempts(attempts int) Option {
+	return func(opt *options) {
+		opt.maxAttempts = attempts
+	}
+}
+
+// WithRetention sets how long delivered messages are kept before they are deleted.
+func WithRetention(retention time.Duration) Option {
+	return func(opt *options) {
+		opt.retention = retention
+	}
+}
+
+// WithListen makes the relay LISTEN for enqueue notifications on the Postgres
+// database at dsn instead of waiting for the next poll.
+func WithListen(dsn string) Option {
+	return func(opt *options) {
+		opt.listenDSN = dsn
+	}
+}
+
+// Relay publishes outbox messages to a broker
+type Relay struct {
+	db     *sqlx.DB
+	broker pubsub.Broker
+	id     string
+	opts   options
+}
+
+// row is a leased outbox message
+type row struct {
+	ID        int64  `db:"id"`
+	MessageID string `db:"message_id"`
+	Subject   string `db:"subject"`
+	Payload   []byte `db:"payload"`
+	Headers   []byte `db:"headers"`
+	Attempts  int    `db:"attempts"`
+}
+
+// NewRelay creates a relay publishing the outbox of db to broker
+func NewRelay(db *sqlx.DB, broker pubsub.Broker, opts ...Option) *Relay {
+	o := options{
+		pollInterval:    time.Second,
+		batchSize:       100,
+		lease:           30 * time.Second,
+		retryDelay:      5 * time.Second,
+		retention:       24 * time.Hour,
+		cleanupInterval: time.Minute,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	hostname, _ := os.Hostname()
+	return &Relay{
+		db:     db,
+		broker: broker,
+		id:     hostname + "-" + uuid.New().String()[:8],
+		opts:   o,
+	}
+}
+
+// Run relays messages until ctx is done
+func (r *Relay) Run(ctx context.Context) error {
+	var notify <-chan *pq.Notification
+	if r.opts.listenDSN != "" {
+		listener := pq.NewListener(r.opts.listenDSN, time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+			if err != nil {
+				log.Printf("outbox: listener error: %v", err)
+			}
+		})
+		defer listener.Close()
+
+		if err := listener.Listen(Channel); err != nil {
+			return fmt.Errorf("failed to listen on %s: %w", Channel, err)
+		}
+		notify = listener.Notify
+	}
+
+	poll := time.NewTicker(r.opts.pollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(r.opts.cleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		// Keep relaying while full batches come back
+		for {
+			n, err := r.relayBatch(ctx)
+			if err != nil && ctx.Err() == nil {
+				log.Printf("outbox: failed to relay messages: %v", err)
+			}
+			if err != nil || n < r.opts.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-poll.C:
+		case <-notify:
+		case <-cleanup.C:
+			if err := r.Cleanup(ctx); err != nil && ctx.Err() == nil {
+				log.Printf("outbox: failed to clean up delivered messages: %v", err)
+			}
+		}
+	}
+}
+
+// Start runs the relay in a goroutine until ctx is done
+func (r *Relay) Start(ctx context.Context) {
+	go func() {
+		if err := r.Run(ctx); err != nil {
+			log.Printf("outbox: relay stopped: %v", err)
+		}
+	}()
+}
+
+// relayBatch leases a batch of pending messages and publishes them in order,
+// returning the number of messages leased. Publishing stops at the first
+// failure, the rest of the batch is held back with the failed message so
+// a relay never publishes a message before an earlier one of its batch. A
+// message out of attempts is parked instead and the batch goes on.
+func (r *Relay) relayBatch(ctx context.Context) (int, error) {
+	recs, err := r.lease(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, rec := range recs {
+		msg := pubsub.NewMessage(rec.Subject, rec.Payload)
+		msg.ID = rec.MessageID
+		if err := json.Unmarshal(rec.Headers, &msg.Header); err != nil || msg.Header == nil {
+			msg.Header = pubsub.Header{}
+		}
+
+		if err := r.broker.PublishMsg(ctx, msg); err != nil {
+			if r.opts.maxAttempts > 0 && rec.Attempts+1 >= r.opts.maxAttempts {
+				if parkErr := r.markParked(ctx, rec, err); parkErr != nil {
+					return len(recs), parkErr
+				}
+				log.Printf("outbox: parked message %d after %d attempts: %v", rec.ID, rec.Attempts+1, err)
+				continue
+			}
+			if markErr := r.markFailed(ctx, rec, err); markErr != nil {
+				return len(recs), markErr
+			}
+			if holdErr := r.holdBack(ctx, recs[i+1:]); holdErr != nil {
+				return len(recs), holdErr
+			}
+			return len(recs), fmt.Errorf("failed to publish outbox message %d: %w", rec.ID, err)
+		}
+		if err := r.markDelivered(ctx, rec); err != nil {
+			return len(recs), err
+		}
+	}
+	return len(recs), nil
+}
+
+// lease reserves up to a batch of pending messages for this relay. Rows
+// locked by a concurrent relay are skipped, expired leases are taken over.
+func (r *Relay) lease(ctx context.Context) ([]row, error) {
+	var recs []row
+	err := r.db.SelectContext(ctx, &recs, `
+		UPDATE soul_outbox SET locked_by = $1, locked_until = now() + $2 * interval '1 millisecond'
+		WHERE id IN (
+			SELECT id FROM soul_outbox
+			WHERE delivered_at IS NULL AND failed_at IS NULL AND (locked_until IS NULL OR locked_until < now())
+			ORDER BY id
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, message_id, subject, payload, headers, attempts`,
+		r.id, r.opts.lease.Milliseconds(), r.opts.batchSize,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to lease outbox messages: %w", err)
+	}
+
+	// RETURNING does not preserve the order of the subquery
+	sort.Slice(recs, func(i, j int) bool { return recs[i].ID < recs[j].ID })
+	return recs, nil
+}
+
+func (r *Relay) markDelivered(ctx context.Context, rec row) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE soul_outbox SET delivered_at = now(), locked_by = NULL, locked_until = NULL WHERE id = $1 AND locked_by = $2`,
+		rec.ID, r.id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to mark outbox message %d as delivered: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// markFailed records the publish error and keeps the message leased until the retry delay elapsed
+func (r *Relay) markFailed(ctx context.Context, rec row, cause error) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE soul_outbox SET attempts = attempts + 1, last_error = $1, locked_until = now() + $2 * interval '1 millisecond' WHERE id = $3 AND locked_by = $4`,
+		cause.Error(), r.opts.retryDelay.Milliseconds(), rec.ID, r.id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record failure of outbox message %d: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// markParked records the publish error and stops relaying the message
+func (r *Relay) markParked(ctx context.Context, rec row, cause error) error {
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE soul_outbox SET attempts = attempts + 1, last_error = $1, failed_at = now(), locked_by = NULL, locked_until = NULL WHERE id = $2 AND locked_by = $3`,
+		cause.Error(), rec.ID, r.id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to park outbox message %d: %w", rec.ID, err)
+	}
+	return nil
+}
+
+// holdBack extends the lease of recs until the retry delay elapsed, so they
+// are leased again together with the failed message before them
+func (r *Relay) holdBack(ctx context.Context, recs []row) error {
+	if len(recs) == 0 {
+		return nil
+	}
+	ids := make([]int64, len(recs))
+	for i, rec := range recs {
+		ids[i] = rec.ID
+	}
+
+	_, err := r.db.ExecContext(ctx,
+		`UPDATE soul_outbox SET locked_until = now() + $1 * interval '1 millisecond' WHERE id = ANY($2) AND locked_by = $3`,
+		r.opts.retryDelay.Milliseconds(), pq.Array(ids), r.id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to hold back outbox messages: %w", err)
+	}
+	return nil
+}
+
+// Cleanup deletes messages delivered longer ago than the retention
+func (r *Relay) Cleanup(ctx context.Context) error {
+	_, err := r.db.ExecContext(ctx,
+		`DELETE FROM soul_outbox WHERE delivered_at < now() - $1 * interval '1 millisecond'`,
+		r.opts.retention.Milliseconds(),
+	)
+	return err
+}
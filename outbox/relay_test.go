@@ -0,0 +1,178 @@
+package outbox
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/pubsub"
+)
+
+// fakeBroker records published messages, failing those whose ID is in fail
+type fakeBroker struct {
+	pubsub.Broker
+	mu        sync.Mutex
+	published []*pubsub.Message
+	fail      map[string]bool
+}
+
+func (b *fakeBroker) PublishMsg(ctx context.Context, msg *pubsub.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.fail[msg.ID] {
+		return errors.New("broker unavailable")
+	}
+	b.published = append(b.published, msg)
+	return nil
+}
+
+func (b *fakeBroker) ids() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	ids := make([]string, len(b.published))
+	for i, msg := range b.published {
+		ids[i] = msg.ID
+	}
+	return ids
+}
+
+func leaseRows(ids ...int64) *sqlmock.Rows {
+	rows := sqlmock.NewRows([]string{"id", "message_id", "subject", "payload", "headers", "attempts"})
+	// Rows come back out of order, as RETURNING does not keep the order of the subquery
+	for i := len(ids) - 1; i >= 0; i-- {
+		rows.AddRow(ids[i], "msg-"+strconv.FormatInt(ids[i], 10), "orders", []byte("{}"), []byte(`{"Trace":["abc"]}`), 0)
+	}
+	return rows
+}
+
+// TestRelayBatch tests that leased messages are published in order and marked delivered.
+func TestRelayBatch(t *testing.T) {
+	db, mock := newMockDB(t)
+	broker := &fakeBroker{}
+	r := NewRelay(db, broker, WithBatchSize(10), WithLease(time.Minute))
+
+	mock.ExpectQuery(`UPDATE soul_outbox SET locked_by = \$1`).
+		WithArgs(r.id, int64(60000), 10).
+		WillReturnRows(leaseRows(1, 2))
+	mock.ExpectExec(`UPDATE soul_outbox SET delivered_at = now\(\)`).
+		WithArgs(int64(1), r.id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE soul_outbox SET delivered_at = now\(\)`).
+		WithArgs(int64(2), r.id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := r.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"msg-1", "msg-2"}, broker.ids())
+	assert.Equal(t, "abc", broker.published[0].Header.Get("Trace"))
+}
+
+// TestRelayBatchFailure tests that publishing stops at the first failure and
+// the rest of the batch is held back with it.
+func TestRelayBatchFailure(t *testing.T) {
+	db, mock := newMockDB(t)
+	broker := &fakeBroker{fail: map[string]bool{"msg-2": true}}
+	r := NewRelay(db, broker, WithBatchSize(10), WithRetryDelay(time.Second))
+
+	mock.ExpectQuery(`UPDATE soul_outbox SET locked_by = \$1`).WillReturnRows(leaseRows(1, 2, 3, 4))
+	mock.ExpectExec(`UPDATE soul_outbox SET delivered_at = now\(\)`).
+		WithArgs(int64(1), r.id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE soul_outbox SET attempts = attempts \+ 1`).
+		WithArgs("broker unavailable", int64(1000), int64(2), r.id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE soul_outbox SET locked_until = .* WHERE id = ANY\(\$2\)`).
+		WithArgs(int64(1000), "{3,4}", r.id).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	n, err := r.relayBatch(context.Background())
+	assert.ErrorContains(t, err, "failed to publish outbox message 2: broker unavailable")
+	assert.Equal(t, 4, n)
+	assert.Equal(t, []string{"msg-1"}, broker.ids())
+}
+
+// TestRelayBatchMaxAttempts tests that a message out of attempts is parked and the batch goes on.
+func TestRelayBatchMaxAttempts(t *testing.T) {
+	db, mock := newMockDB(t)
+	broker := &fakeBroker{fail: map[string]bool{"msg-1": true}}
+	r := NewRelay(db, broker, WithBatchSize(10), WithMaxAttempts(3))
+
+	rows := sqlmock.NewRows([]string{"id", "message_id", "subject", "payload", "headers", "attempts"}).
+		AddRow(int64(1), "msg-1", "orders", []byte("{}"), []byte("{}"), 2).
+		AddRow(int64(2), "msg-2", "orders", []byte("{}"), []byte("{}"), 0)
+	mock.ExpectQuery(`UPDATE soul_outbox SET locked_by = \$1.* failed_at IS NULL`).WillReturnRows(rows)
+	mock.ExpectExec(`UPDATE soul_outbox SET attempts = attempts \+ 1, last_error = \$1, failed_at = now\(\)`).
+		WithArgs("broker unavailable", int64(1), r.id).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(`UPDATE soul_outbox SET delivered_at = now\(\)`).
+		WithArgs(int64(2), r.id).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	n, err := r.relayBatch(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, n)
+	assert.Equal(t, []string{"msg-2"}, broker.ids())
+}
+
+// TestRelayBatchLeaseError tests that a failed lease is returned.
+func TestRelayBatchLeaseError(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := NewRelay(db, &fakeBroker{})
+
+	mock.ExpectQuery(`UPDATE soul_outbox SET locked_by = \$1`).WillReturnError(assert.AnError)
+
+	n, err := r.relayBatch(context.Background())
+	assert.ErrorIs(t, err, assert.AnError)
+	assert.Zero(t, n)
+}
+
+// TestRelayCleanup tests that delivered messages older than the retention are deleted.
+func TestRelayCleanup(t *testing.T) {
+	db, mock := newMockDB(t)
+	r := NewRelay(db, &fakeBroker{}, WithRetention(time.Hour))
+
+	mock.ExpectExec(`DELETE FROM soul_outbox WHERE delivered_at < now\(\)`).
+		WithArgs(int64(3600000)).WillReturnResult(driver.RowsAffected(3))
+
+	assert.NoError(t, r.Cleanup(context.Background()))
+}
+
+// TestRelayListen tests that a listening relay publishes enqueued messages
+// without waiting for the next poll. It needs a Postgres database, set
+// SOUL_TEST_POSTGRES_DSN to run it.
+func TestRelayListen(t *testing.T) {
+	dsn := os.Getenv("SOUL_TEST_POSTGRES_DSN")
+	if dsn == "" {
+		t.Skip("SOUL_TEST_POSTGRES_DSN is not set")
+	}
+
+	db, err := sqlx.Open("postgres", dsn)
+	require.NoError(t, err)
+	defer db.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, CreateTable(ctx, db))
+	_, err = db.ExecContext(ctx, `DELETE FROM soul_outbox`)
+	require.NoError(t, err)
+
+	enqueue := func(id string) {
+		tx, err := db.BeginTxx(ctx, nil)
+		require.NoError(t, err)
+		require.NoError(t, Enqueue(ctx, tx, "orders", []byte("{}"), id))
+		require.NoError(t, tx.Commit())
+	}
+
+	broker := &fakeBroker{}
+	NewRelay(db, broker, WithPollInterval(time.Hour), WithListen(dsn)).Start(ctx)
+
+	// Polling is out of the way, messages arrive on start or by notification
+	enqueue("order-1")
+	assert.Eventually(t, func() bool { return len(broker.ids()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	enqueue("order-2")
+	assert.Eventually(t, func() bool { return len(broker.ids()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{"order-1", "order-2"}, broker.ids())
+}
@@ -0,0 +1,63 @@
+package pubsub
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+)
+
+// ContentTypeJSON is the content type of the default codec
+const ContentTypeJSON = "application/json"
+
+// Codec encodes and decodes message payloads of a content type
+type Codec interface {
+	ContentType() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+var (
+	codecsMu sync.RWMutex
+	codecs   = map[string]Codec{
+		ContentTypeJSON: JSONCodec{},
+	}
+)
+
+// RegisterCodec makes codec available for its content type, replacing any codec registered before
+func RegisterCodec(codec Codec) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+
+	codecs[codec.ContentType()] = codec
+}
+
+// CodecFor returns the codec registered for contentType, an empty content type selects JSON
+func CodecFor(contentType string) (Codec, error) {
+	if contentType == "" {
+		contentType = ContentTypeJSON
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+
+	codec, ok := codecs[contentType]
+	if !ok {
+		return nil, fmt.Errorf("no codec registered for content type %s", contentType)
+	}
+	return codec, nil
+}
+
+// JSONCodec encodes payloads as JSON
+type JSONCodec struct{}
+
+func (JSONCodec) ContentType() string {
+	return ContentTypeJSON
+}
+
+func (JSONCodec) Marshal(v any) ([]byte, error) {
+	return Marshal(v)
+}
+
+func (JSONCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
@@ -0,0 +1,33 @@
+package pubsub
+
+import "fmt"
+
+// BrokerConfig selects the Broker created by NewBroker
+type BrokerConfig struct {
+	Driver   string // nats (default), redis or memory
+	NatsURL  string
+	RedisURL string
+}
+
+// NewBroker creates the broker selected by c.Driver
+func NewBroker(c BrokerConfig, opts ...Option) (Broker, error) {
+	switch c.Driver {
+	case "", "nats":
+		return NewNATSBroker(c.NatsURL, c.RedisURL, opts...)
+	case "redis":
+		return NewRedisStreamsBroker(c.RedisURL, opts...)
+	case "memory":
+		return NewMemoryBroker(opts...), nil
+	default:
+		return nil, fmt.Errorf("unknown pubsub driver: %s", c.Driver)
+	}
+}
+
+// MustNewBroker creates the broker selected by c.Driver and panics if there's an error
+func MustNewBroker(c BrokerConfig, opts ...Option) Broker {
+	broker, err := NewBroker(c, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create %s broker: %v", c.Driver, err))
+	}
+	return broker
+}
@@ -0,0 +1,133 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/redis/go-redis/v9"
+)
+
+// DedupStore tracks the IDs of processed messages so redeliveries are not handled twice
+type DedupStore interface {
+	// Seen reports whether id was marked as processed and has not expired
+	Seen(ctx context.Context, id string) (bool, error)
+	// Mark records id as processed for ttl
+	Mark(ctx context.Context, id string, ttl time.Duration) error
+}
+
+// RedisDedupStore stores processed message IDs in Redis
+type RedisDedupStore struct {
+	client *redis.Client
+	prefix string
+}
+
+// NewRedisDedupStore creates a dedup store on client, keys are prefixed with prefix
+func NewRedisDedupStore(client *redis.Client, prefix string) *RedisDedupStore {
+	return &RedisDedupStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+func (s *RedisDedupStore) Seen(ctx context.Context, id string) (bool, error) {
+	n, err := s.client.Exists(ctx, s.prefix+id).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check message ID in Redis: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (s *RedisDedupStore) Mark(ctx context.Context, id string, ttl time.Duration) error {
+	return s.client.Set(ctx, s.prefix+id, "processed", ttl).Err()
+}
+
+// MemoryDedupStore stores processed message IDs in process memory
+type MemoryDedupStore struct {
+	mu        sync.Mutex
+	ids       map[string]time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryDedupStore creates an in-memory dedup store
+func NewMemoryDedupStore() *MemoryDedupStore {
+	return &MemoryDedupStore{
+		ids:       make(map[string]time.Time),
+		lastSweep: time.Now(),
+	}
+}
+
+func (s *MemoryDedupStore) Seen(ctx context.Context, id string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	expires, ok := s.ids[id]
+	return ok && time.Now().Before(expires), nil
+}
+
+func (s *MemoryDedupStore) Mark(ctx context.Context, id string, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	s.ids[id] = now.Add(ttl)
+
+	// Drop expired IDs at most once per ttl
+	if now.Sub(s.lastSweep) > ttl {
+		for k, expires := range s.ids {
+			if now.After(expires) {
+				delete(s.ids, k)
+			}
+		}
+		s.lastSweep = now
+	}
+	return nil
+}
+
+// KVDedupStore stores processed message IDs in a JetStream key-value bucket.
+// Expiry is governed by the TTL of the bucket, the ttl passed to Mark is ignored.
+type KVDedupStore struct {
+	kv nats.KeyValue
+}
+
+// NewKVDedupStore creates a dedup store on bucket, creating the bucket with ttl if it does not exist
+func NewKVDedupStore(js nats.JetStreamContext, bucket string, ttl time.Duration) (*KVDedupStore, error) {
+	kv, err := js.KeyValue(bucket)
+	if errors.Is(err, nats.ErrBucketNotFound) {
+		kv, err = js.CreateKeyValue(&nats.KeyValueConfig{
+			Bucket: bucket,
+			TTL:    ttl,
+		})
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key-value bucket %s: %w", bucket, err)
+	}
+	return &KVDedupStore{kv: kv}, nil
+}
+
+func (s *KVDedupStore) Seen(ctx context.Context, id string) (bool, error) {
+	_, err := s.kv.Get(kvKey(id))
+	if errors.Is(err, nats.ErrKeyNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to check message ID in key-value bucket: %w", err)
+	}
+	return true, nil
+}
+
+// Mark records id as processed. The ttl is ignored, entries expire with the
+// TTL of the bucket set by NewKVDedupStore.
+func (s *KVDedupStore) Mark(ctx context.Context, id string, ttl time.Duration) error {
+	_, err := s.kv.Put(kvKey(id), []byte("processed"))
+	return err
+}
+
+// kvKey encodes id into the character set allowed for key-value keys
+func kvKey(id string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(id))
+}
@@ -0,0 +1,50 @@
+package pubsub
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testDedupStore(t *testing.T, store DedupStore, expire func(time.Duration)) {
+	ctx := context.Background()
+
+	seen, err := store.Seen(ctx, "order-1")
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	require.NoError(t, store.Mark(ctx, "order-1", 50*time.Millisecond))
+	seen, err = store.Seen(ctx, "order-1")
+	require.NoError(t, err)
+	assert.True(t, seen)
+
+	seen, err = store.Seen(ctx, "order-2")
+	require.NoError(t, err)
+	assert.False(t, seen)
+
+	expire(100 * time.Millisecond)
+	seen, err = store.Seen(ctx, "order-1")
+	require.NoError(t, err)
+	assert.False(t, seen, "marks expire after their ttl")
+}
+
+// TestMemoryDedupStore tests remembering processed IDs in memory.
+func TestMemoryDedupStore(t *testing.T) {
+	testDedupStore(t, NewMemoryDedupStore(), time.Sleep)
+}
+
+// TestRedisDedupStore tests remembering processed IDs in Redis.
+func TestRedisDedupStore(t *testing.T) {
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+
+	store := NewRedisDedupStore(client, "dedup:")
+	testDedupStore(t, store, mr.FastForward)
+	assert.False(t, mr.Exists("order-1"))
+}
@@ -0,0 +1,306 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Envelope headers describing the payload of a message
+const (
+	TypeHeader          = "Soul-Type"
+	VersionHeader       = "Soul-Version"
+	ContentTypeHeader   = "Content-Type"
+	TimestampHeader     = "Soul-Timestamp"
+	CorrelationIDHeader = "Soul-Correlation-Id"
+	CausationIDHeader   = "Soul-Causation-Id"
+	TraceParentHeader   = "traceparent"
+	TraceStateHeader    = "tracestate"
+)
+
+// Envelope is the metadata that travels with a typed message
+type Envelope struct {
+	ID            string
+	Type          string
+	Version       int
+	ContentType   string
+	Timestamp     time.Time
+	CorrelationID string
+	CausationID   string
+	TraceParent   string
+	TraceState    string
+}
+
+// EnvelopeFromMessage reads the envelope from the headers of msg
+func EnvelopeFromMessage(msg *Message) *Envelope {
+	env := &Envelope{
+		ID:            msg.ID,
+		Type:          msg.Header.Get(TypeHeader),
+		Version:       1,
+		ContentType:   msg.Header.Get(ContentTypeHeader),
+		CorrelationID: msg.Header.Get(CorrelationIDHeader),
+		CausationID:   msg.Header.Get(CausationIDHeader),
+		TraceParent:   msg.Header.Get(TraceParentHeader),
+		TraceState:    msg.Header.Get(TraceStateHeader),
+	}
+	if v, err := strconv.Atoi(msg.Header.Get(VersionHeader)); err == nil {
+		env.Version = v
+	}
+	if ts, err := time.Parse(time.RFC3339Nano, msg.Header.Get(TimestampHeader)); err == nil {
+		env.Timestamp = ts
+	}
+	return env
+}
+
+// apply writes the envelope to the headers of msg
+func (env *Envelope) apply(msg *Message) {
+	msg.ID = env.ID
+	msg.Header.Set(TypeHeader, env.Type)
+	msg.Header.Set(VersionHeader, strconv.Itoa(env.Version))
+	msg.Header.Set(ContentTypeHeader, env.ContentType)
+	msg.Header.Set(TimestampHeader, env.Timestamp.Format(time.RFC3339Nano))
+	for key, value := range map[string]string{
+		CorrelationIDHeader: env.CorrelationID,
+		CausationIDHeader:   env.CausationID,
+		TraceParentHeader:   env.TraceParent,
+		TraceStateHeader:    env.TraceState,
+	} {
+		if value != "" {
+			msg.Header.Set(key, value)
+		}
+	}
+}
+
+type envelopeKey struct{}
+
+// ContextWithEnvelope returns a copy of ctx carrying env. Messages published
+// with Publish under that context are correlated with env.
+func ContextWithEnvelope(ctx context.Context, env *Envelope) context.Context {
+	return context.WithValue(ctx, envelopeKey{}, env)
+}
+
+// EnvelopeFromContext returns the envelope of the message being handled
+func EnvelopeFromContext(ctx context.Context) (*Envelope, bool) {
+	env, ok := ctx.Value(envelopeKey{}).(*Envelope)
+	return env, ok
+}
+
+// Upcaster converts a payload from one schema version to the next
+type Upcaster func(data []byte) ([]byte, error)
+
+// messageType is the registration of a payload type
+type messageType struct {
+	name      string
+	version   int
+	upcasters map[int]Upcaster
+}
+
+var (
+	typesMu     sync.RWMutex
+	typesByGo   = map[reflect.Type]*messageType{}
+	typesByName = map[string]*messageType{}
+)
+
+// RegisterType registers T under name at the current schema version.
+// Unregistered types are published under their Go type name at version 1.
+func RegisterType[T any](name string, version int) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	mt, ok := typesByName[name]
+	if !ok {
+		mt = &messageType{name: name, upcasters: map[int]Upcaster{}}
+		typesByName[name] = mt
+	}
+	mt.version = version
+	typesByGo[reflect.TypeFor[T]()] = mt
+}
+
+// RegisterUpcaster registers fn to convert payloads of the type registered as
+// name from schema version fromVersion to fromVersion+1
+func RegisterUpcaster(name string, fromVersion int, fn Upcaster) {
+	typesMu.Lock()
+	defer typesMu.Unlock()
+
+	mt, ok := typesByName[name]
+	if !ok {
+		mt = &messageType{name: name, version: fromVersion + 1, upcasters: map[int]Upcaster{}}
+		typesByName[name] = mt
+	}
+	mt.upcasters[fromVersion] = fn
+}
+
+// typeOf returns the registration of T
+func typeOf[T any]() *messageType {
+	t := reflect.TypeFor[T]()
+
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	if mt, ok := typesByGo[t]; ok {
+		return mt
+	}
+	return &messageType{name: t.String(), version: 1}
+}
+
+// upcast converts data from version to the registered version of mt
+func (mt *messageType) upcast(data []byte, version int) ([]byte, error) {
+	typesMu.RLock()
+	defer typesMu.RUnlock()
+
+	if version > mt.version {
+		return nil, fmt.Errorf("message type %s has unknown schema version %d, latest is %d", mt.name, version, mt.version)
+	}
+
+	for v := version; v < mt.version; v++ {
+		fn, ok := mt.upcasters[v]
+		if !ok {
+			return nil, fmt.Errorf("no upcaster registered for message type %s from version %d", mt.name, v)
+		}
+
+		var err error
+		if data, err = fn(data); err != nil {
+			return nil, fmt.Errorf("failed to upcast message type %s from version %d: %w", mt.name, v, err)
+		}
+	}
+	return data, nil
+}
+
+// PublishOption customizes the envelope of a message published with Publish
+type PublishOption func(env *Envelope)
+
+// WithMessageID sets the message ID used for deduplication
+func WithMessageID(id string) PublishOption {
+	return func(env *Envelope) {
+		env.ID = id
+	}
+}
+
+// WithCorrelationID sets the correlation ID of the message
+func WithCorrelationID(id string) PublishOption {
+	return func(env *Envelope) {
+		env.CorrelationID = id
+	}
+}
+
+// WithCausationID sets the ID of the message that caused this one
+func WithCausationID(id string) PublishOption {
+	return func(env *Envelope) {
+		env.CausationID = id
+	}
+}
+
+// WithContentType selects the codec used to encode the payload
+func WithContentType(contentType string) PublishOption {
+	return func(env *Envelope) {
+		env.ContentType = contentType
+	}
+}
+
+// WithTraceContext sets the W3C trace context of the message
+func WithTraceContext(traceParent, traceState string) PublishOption {
+	return func(env *Envelope) {
+		env.TraceParent = traceParent
+		env.TraceState = traceState
+	}
+}
+
+// Publish encodes v in an envelope and publishes it to subject. When ctx
+// carries the envelope of a message being handled, the new message inherits
+// its correlation ID and trace context and records it as its cause.
+func Publish[T any](ctx context.Context, b Broker, subject string, v T, opts ...PublishOption) error {
+	msg, err := Encode(ctx, subject, v, opts...)
+	if err != nil {
+		return err
+	}
+	return b.PublishMsg(ctx, msg)
+}
+
+// Encode encodes v in an envelope addressed to subject without publishing it
+func Encode[T any](ctx context.Context, subject string, v T, opts ...PublishOption) (*Message, error) {
+	mt := typeOf[T]()
+
+	typesMu.RLock()
+	env := &Envelope{
+		Type:        mt.name,
+		Version:     mt.version,
+		ContentType: ContentTypeJSON,
+		Timestamp:   time.Now().UTC(),
+	}
+	typesMu.RUnlock()
+
+	if parent, ok := EnvelopeFromContext(ctx); ok {
+		env.CorrelationID = parent.CorrelationID
+		if env.CorrelationID == "" {
+			env.CorrelationID = parent.ID
+		}
+		env.CausationID = parent.ID
+		env.TraceParent = parent.TraceParent
+		env.TraceState = parent.TraceState
+	}
+	for _, opt := range opts {
+		opt(env)
+	}
+	if env.ID == "" {
+		env.ID = uuid.New().String()
+	}
+	if env.CorrelationID == "" {
+		env.CorrelationID = env.ID
+	}
+
+	codec, err := CodecFor(env.ContentType)
+	if err != nil {
+		return nil, err
+	}
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := NewMessage(subject, data)
+	env.apply(msg)
+	return msg, nil
+}
+
+// Subscribe subscribes handler to subject, decoding each payload into T after
+// upcasting it to the registered schema version. The envelope is passed to
+// handler and attached to its context.
+func Subscribe[T any](ctx context.Context, b Broker, subject, group string, handler func(ctx context.Context, v T, env *Envelope) error) (Subscription, error) {
+	return b.Subscribe(ctx, subject, group, func(ctx context.Context, msg *Message) error {
+		v, env, err := Decode[T](msg)
+		if err != nil {
+			return err
+		}
+		return handler(ContextWithEnvelope(ctx, env), v, env)
+	})
+}
+
+// Decode decodes the payload of msg into T
+func Decode[T any](msg *Message) (T, *Envelope, error) {
+	var v T
+	env := EnvelopeFromMessage(msg)
+
+	mt := typeOf[T]()
+	if env.Type != "" && env.Type != mt.name {
+		return v, env, fmt.Errorf("message type %s does not match %s", env.Type, mt.name)
+	}
+
+	data, err := mt.upcast(msg.Data, env.Version)
+	if err != nil {
+		return v, env, err
+	}
+
+	codec, err := CodecFor(env.ContentType)
+	if err != nil {
+		return v, env, err
+	}
+	if err := codec.Unmarshal(data, &v); err != nil {
+		return v, env, fmt.Errorf("failed to decode message type %s: %w", mt.name, err)
+	}
+	return v, env, nil
+}
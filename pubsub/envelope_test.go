@@ -0,0 +1,81 @@
+package pubsub
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type orderPlaced struct {
+	OrderID string `json:"orderId"`
+	Total   int    `json:"total"`
+}
+
+type invoiceRequested struct {
+	OrderID string `json:"orderId"`
+}
+
+// TestTypedPublishSubscribe tests envelopes, correlation and upcasting of old schema versions.
+func TestTypedPublishSubscribe(t *testing.T) {
+	RegisterType[orderPlaced]("orders.placed", 2)
+	RegisterUpcaster("orders.placed", 1, func(data []byte) ([]byte, error) {
+		// Version 1 named the total "amount"
+		return bytes.Replace(data, []byte(`"amount"`), []byte(`"total"`), 1), nil
+	})
+
+	b := NewMemoryBroker()
+	defer b.Close()
+	ctx := context.Background()
+
+	invoices := make(chan *Envelope, 2)
+	_, err := Subscribe(ctx, b, "invoices", "", func(ctx context.Context, v invoiceRequested, env *Envelope) error {
+		invoices <- env
+		return nil
+	})
+	assert.NoError(t, err)
+
+	orders := make(chan orderPlaced, 2)
+	_, err = Subscribe(ctx, b, "orders", "", func(ctx context.Context, v orderPlaced, env *Envelope) error {
+		orders <- v
+		return Publish(ctx, b, "invoices", invoiceRequested{OrderID: v.OrderID})
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, Publish(ctx, b, "orders", orderPlaced{OrderID: "o-1", Total: 42}, WithMessageID("msg-1"), WithCorrelationID("checkout-1")))
+
+	legacy := NewMessage("orders", []byte(`{"orderId":"o-2","amount":7}`))
+	legacy.Header.Set(TypeHeader, "orders.placed")
+	legacy.Header.Set(VersionHeader, "1")
+	assert.NoError(t, b.PublishMsg(ctx, legacy))
+
+	for _, want := range []orderPlaced{{OrderID: "o-1", Total: 42}, {OrderID: "o-2", Total: 7}} {
+		select {
+		case got := <-orders:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatalf("order %s was not delivered", want.OrderID)
+		}
+	}
+
+	select {
+	case env := <-invoices:
+		assert.Equal(t, "pubsub.invoiceRequested", env.Type)
+		assert.Equal(t, 1, env.Version)
+		assert.Equal(t, "checkout-1", env.CorrelationID)
+		assert.Equal(t, "msg-1", env.CausationID)
+	case <-time.After(time.Second):
+		t.Fatal("invoice was not requested")
+	}
+}
+
+// TestDecodeRejectsUnknownVersion tests that newer schema versions are not decoded.
+func TestDecodeRejectsUnknownVersion(t *testing.T) {
+	msg := NewMessage("invoices", []byte(`{}`))
+	msg.Header.Set(VersionHeader, "3")
+
+	_, _, err := Decode[invoiceRequested](msg)
+	assert.Error(t, err)
+}
@@ -0,0 +1,449 @@
+package pubsub
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MemoryBroker is an in-process implementation of Broker for tests and
+// single-node deployments. Messages are delivered to the subscriptions present
+// at publish time; they are not persisted.
+type MemoryBroker struct {
+	mu      sync.RWMutex
+	streams map[string]*memoryStream
+	groups  map[string]*memoryGroup
+	inboxes map[string]chan *Message
+	closed  bool
+	wg      sync.WaitGroup
+	opts    options
+}
+
+// memoryStream tracks the message IDs seen within the deduplication window of a stream
+type memoryStream struct {
+	subject     string
+	dedupWindow time.Duration
+	seen        map[string]time.Time
+	order       []string
+}
+
+// memoryGroup is a set of subscriptions sharing a queue group, each message goes to one member
+type memoryGroup struct {
+	subject string
+	members []*memorySubscription
+	next    int
+}
+
+// NewMemoryBroker creates a new in-memory broker
+func NewMemoryBroker(opts ...Option) *MemoryBroker {
+	b := &MemoryBroker{
+		streams: make(map[string]*memoryStream),
+		groups:  make(map[string]*memoryGroup),
+		inboxes: make(map[string]chan *Message),
+		opts:    defaultOptions(),
+	}
+	for _, opt := range opts {
+		opt(&b.opts)
+	}
+	return b
+}
+
+// CreateStream registers a stream for subject, messages published to it are deduplicated by ID within dedupWindow
+func (b *MemoryBroker) CreateStream(streamName, subject string, dedupWindow time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	if s, ok := b.streams[streamName]; ok {
+		if s.subject != subject {
+			return fmt.Errorf("stream %s already exists for subject %s", streamName, s.subject)
+		}
+		s.dedupWindow = dedupWindow
+		return nil
+	}
+
+	b.streams[streamName] = &memoryStream{
+		subject:     subject,
+		dedupWindow: dedupWindow,
+		seen:        make(map[string]time.Time),
+	}
+	return nil
+}
+
+// Publish publishes a message to subject using the provided msgID for deduplication
+func (b *MemoryBroker) Publish(ctx context.Context, subject string, message []byte, msgID ...string) error {
+	msg := NewMessage(subject, message)
+	if len(msgID) > 0 {
+		msg.ID = msgID[0]
+	}
+	return b.PublishMsg(ctx, msg)
+}
+
+// PublishMsg publishes a message with its headers, delivering one copy to every queue group on its subject
+func (b *MemoryBroker) PublishMsg(ctx context.Context, msg *Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if msg.ID == "" {
+		// Generate a unique message ID if none is provided
+		msg.ID = uuid.New().String()
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return ErrClosed
+	}
+
+	if b.isDuplicate(msg.Subject, msg.ID, time.Now()) {
+		return nil
+	}
+
+	for _, g := range b.groups {
+		if subjectMatches(g.subject, msg.Subject) {
+			b.dispatch(g, b.copyMessage(msg, 1))
+		}
+	}
+	return nil
+}
+
+// Request publishes a message to subject and waits up to timeout for the first reply
+func (b *MemoryBroker) Request(ctx context.Context, subject string, message []byte, timeout time.Duration) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	inbox := "_INBOX." + uuid.New().String()
+	replies := make(chan *Message, 1)
+
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, ErrClosed
+	}
+	hasResponder := false
+	for _, g := range b.groups {
+		if subjectMatches(g.subject, subject) {
+			hasResponder = true
+			break
+		}
+	}
+	if !hasResponder {
+		b.mu.Unlock()
+		return nil, ErrNoResponder
+	}
+	b.inboxes[inbox] = replies
+	b.mu.Unlock()
+
+	defer func() {
+		b.mu.Lock()
+		delete(b.inboxes, inbox)
+		b.mu.Unlock()
+	}()
+
+	msg := NewMessage(subject, message)
+	msg.Reply = inbox
+	if err := b.PublishMsg(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		if ctx.Err() == context.DeadlineExceeded {
+			return nil, ErrNoResponder
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Subscribe subscribes handler to subject. Subscriptions sharing a group receive each message once,
+// an empty group receives every message.
+func (b *MemoryBroker) Subscribe(ctx context.Context, subject, group string, handler Handler) (Subscription, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return nil, ErrClosed
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &memorySubscription{
+		broker:  b,
+		subject: subject,
+		handler: handler,
+		ctx:     ctx,
+		cancel:  cancel,
+		signal:  make(chan struct{}, 1),
+	}
+
+	key := subject + "\x00" + group
+	if group == "" {
+		key = subject + "\x00" + uuid.New().String()
+	}
+	sub.key = key
+
+	g, ok := b.groups[key]
+	if !ok {
+		g = &memoryGroup{subject: subject}
+		b.groups[key] = g
+	}
+	g.members = append(g.members, sub)
+
+	b.wg.Add(1)
+	go sub.run()
+
+	return sub, nil
+}
+
+// Close stops all subscriptions and waits for in-flight handlers to return
+func (b *MemoryBroker) Close() error {
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil
+	}
+	b.closed = true
+	for _, g := range b.groups {
+		for _, sub := range g.members {
+			sub.cancel()
+		}
+	}
+	b.groups = make(map[string]*memoryGroup)
+	b.mu.Unlock()
+
+	b.wg.Wait()
+	return nil
+}
+
+// isDuplicate reports whether id was already published on a stream capturing subject within its
+// deduplication window and records it otherwise. b.mu must be held.
+func (b *MemoryBroker) isDuplicate(subject, id string, now time.Time) bool {
+	duplicate := false
+	for _, s := range b.streams {
+		if s.dedupWindow <= 0 || !subjectMatches(s.subject, subject) {
+			continue
+		}
+
+		// Expire IDs that fell out of the window
+		for len(s.order) > 0 && now.Sub(s.seen[s.order[0]]) > s.dedupWindow {
+			delete(s.seen, s.order[0])
+			s.order = s.order[1:]
+		}
+
+		if _, ok := s.seen[id]; ok {
+			duplicate = true
+			continue
+		}
+		s.seen[id] = now
+		s.order = append(s.order, id)
+	}
+	return duplicate
+}
+
+// dispatch hands msg to the next member of g in round-robin order. b.mu must be held.
+func (b *MemoryBroker) dispatch(g *memoryGroup, msg *Message) bool {
+	if len(g.members) == 0 {
+		return false
+	}
+	g.next = (g.next + 1) % len(g.members)
+	g.members[g.next].enqueue(msg)
+	return true
+}
+
+// redeliver hands msg back to the queue group identified by key
+func (b *MemoryBroker) redeliver(key string, msg *Message) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.closed {
+		return
+	}
+	if g, ok := b.groups[key]; !ok || !b.dispatch(g, msg) {
+		log.Printf("MemoryBroker: dropping message %s on subject %s: no subscribers left", msg.ID, msg.Subject)
+	}
+}
+
+// copyMessage creates the delivery of msg for a single queue group. b.mu must be held.
+func (b *MemoryBroker) copyMessage(msg *Message, attempt int) *Message {
+	m := &Message{
+		ID:      msg.ID,
+		Subject: msg.Subject,
+		Reply:   msg.Reply,
+		Header:  make(Header, len(msg.Header)),
+		Data:    msg.Data,
+		Attempt: attempt,
+	}
+	for k, v := range msg.Header {
+		m.Header[k] = append([]string(nil), v...)
+	}
+
+	if m.Reply != "" {
+		if replies, ok := b.inboxes[m.Reply]; ok {
+			m.respond = func(data []byte) error {
+				select {
+				case replies <- NewMessage(m.Reply, data):
+				default: // The requester already has its reply
+				}
+				return nil
+			}
+		}
+	}
+	return m
+}
+
+// memorySubscription is a member of a queue group with its own ordered delivery queue
+type memorySubscription struct {
+	broker  *MemoryBroker
+	subject string
+	key     string
+	handler Handler
+	ctx     context.Context
+	cancel  context.CancelFunc
+
+	mu     sync.Mutex
+	queue  []*Message
+	signal chan struct{}
+}
+
+func (s *memorySubscription) Subject() string {
+	return s.subject
+}
+
+// Unsubscribe removes the subscription from its group and hands its pending messages to the remaining members
+func (s *memorySubscription) Unsubscribe() error {
+	s.cancel()
+
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	g, ok := b.groups[s.key]
+	if !ok {
+		return nil
+	}
+	for i, member := range g.members {
+		if member == s {
+			g.members = append(g.members[:i], g.members[i+1:]...)
+			break
+		}
+	}
+	if len(g.members) == 0 {
+		delete(b.groups, s.key)
+		return nil
+	}
+
+	s.mu.Lock()
+	pending := s.queue
+	s.queue = nil
+	s.mu.Unlock()
+	for _, msg := range pending {
+		b.dispatch(g, msg)
+	}
+	return nil
+}
+
+func (s *memorySubscription) enqueue(msg *Message) {
+	s.mu.Lock()
+	s.queue = append(s.queue, msg)
+	s.mu.Unlock()
+
+	select {
+	case s.signal <- struct{}{}:
+	default:
+	}
+}
+
+func (s *memorySubscription) dequeue() *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return nil
+	}
+	msg := s.queue[0]
+	s.queue = s.queue[1:]
+	return msg
+}
+
+// run delivers queued messages one at a time until the subscription ends
+func (s *memorySubscription) run() {
+	defer s.broker.wg.Done()
+
+	for {
+		select {
+		case <-s.ctx.Done():
+			s.Unsubscribe()
+			return
+		case <-s.signal:
+		}
+
+		// Check for cancellation before dequeuing so Unsubscribe hands every
+		// message left in the queue to the rest of the group
+		for s.ctx.Err() == nil {
+			msg := s.dequeue()
+			if msg == nil {
+				break
+			}
+			s.deliver(msg)
+		}
+	}
+}
+
+// deliver runs the handler and schedules a redelivery when it fails
+func (s *memorySubscription) deliver(msg *Message) {
+	err := s.handler(s.ctx, msg)
+	if err == nil {
+		return
+	}
+
+	b := s.broker
+	if b.opts.maxDeliver > 0 && msg.Attempt >= b.opts.maxDeliver {
+		if b.opts.deadLetterSubject == "" {
+			log.Printf("MemoryBroker: dropping message %s on subject %s after %d attempts: %v", msg.ID, msg.Subject, msg.Attempt, err)
+			return
+		}
+		if err := b.PublishMsg(context.Background(), deadLetter(b.opts.deadLetterSubject, msg, err)); err != nil {
+			log.Printf("MemoryBroker: failed to dead-letter message %s: %v", msg.ID, err)
+		}
+		return
+	}
+
+	next := *msg
+	next.Attempt++
+	time.AfterFunc(b.opts.redeliveryDelay, func() {
+		b.redeliver(s.key, &next)
+	})
+}
+
+// subjectMatches reports whether subject matches pattern, where "*" matches a
+// single token and a trailing ">" matches one or more tokens
+func subjectMatches(pattern, subject string) bool {
+	if pattern == subject {
+		return true
+	}
+
+	pt := strings.Split(pattern, ".")
+	st := strings.Split(subject, ".")
+	for i, p := range pt {
+		if p == ">" {
+			return i == len(pt)-1 && len(st) > i
+		}
+		if i >= len(st) || (p != "*" && p != st[i]) {
+			return false
+		}
+	}
+	return len(pt) == len(st)
+}
+
+var _ Broker = (*MemoryBroker)(nil)
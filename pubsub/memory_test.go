@@ -0,0 +1,207 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// TestMemoryBrokerQueueGroups tests that each queue group receives a message once.
+func TestMemoryBrokerQueueGroups(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	var workers, audit atomic.Int32
+	count := func(n *atomic.Int32) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			n.Add(1)
+			return nil
+		}
+	}
+
+	ctx := context.Background()
+	for i := 0; i < 3; i++ {
+		_, err := b.Subscribe(ctx, "orders.*", "workers", count(&workers))
+		assert.NoError(t, err)
+	}
+	_, err := b.Subscribe(ctx, "orders.>", "", count(&audit))
+	assert.NoError(t, err)
+
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, b.Publish(ctx, "orders.created", []byte("{}")))
+	}
+
+	assert.Eventually(t, func() bool {
+		return workers.Load() == 10 && audit.Load() == 10
+	}, time.Second, 5*time.Millisecond)
+}
+
+// TestMemoryBrokerDeduplication tests that message IDs are deduplicated within the stream window.
+func TestMemoryBrokerDeduplication(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, b.CreateStream("ORDERS", "orders.>", time.Minute))
+
+	var received atomic.Int32
+	_, err := b.Subscribe(ctx, "orders.created", "workers", func(ctx context.Context, msg *Message) error {
+		received.Add(1)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, b.Publish(ctx, "orders.created", []byte("a"), "order-1"))
+	assert.NoError(t, b.Publish(ctx, "orders.created", []byte("a"), "order-1"))
+	assert.NoError(t, b.Publish(ctx, "orders.created", []byte("b"), "order-2"))
+
+	assert.Eventually(t, func() bool { return received.Load() == 2 }, time.Second, 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	assert.Equal(t, int32(2), received.Load())
+}
+
+// TestMemoryBrokerRedelivery tests that a failed message is redelivered until the handler succeeds.
+func TestMemoryBrokerRedelivery(t *testing.T) {
+	b := NewMemoryBroker(WithRedeliveryDelay(time.Millisecond), WithMaxDeliver(3))
+	defer b.Close()
+
+	attempts := make(chan int, 3)
+	_, err := b.Subscribe(context.Background(), "jobs", "workers", func(ctx context.Context, msg *Message) error {
+		attempts <- msg.Attempt
+		if msg.Attempt < 3 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, b.Publish(context.Background(), "jobs", []byte("run")))
+
+	for want := 1; want <= 3; want++ {
+		select {
+		case got := <-attempts:
+			assert.Equal(t, want, got)
+		case <-time.After(time.Second):
+			t.Fatalf("attempt %d was not delivered", want)
+		}
+	}
+}
+
+// TestMemoryBrokerRequest tests request-reply and unsubscribing.
+func TestMemoryBrokerRequest(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	ctx := context.Background()
+	sub, err := b.Subscribe(ctx, "echo", "", func(ctx context.Context, msg *Message) error {
+		return msg.Respond(append([]byte("re: "), msg.Data...))
+	})
+	assert.NoError(t, err)
+
+	reply, err := b.Request(ctx, "echo", []byte("hello"), time.Second)
+	assert.NoError(t, err)
+	assert.Equal(t, "re: hello", string(reply.Data))
+
+	assert.NoError(t, sub.Unsubscribe())
+	_, err = b.Request(ctx, "echo", []byte("hello"), 10*time.Millisecond)
+	assert.ErrorIs(t, err, ErrNoResponder)
+}
+
+// TestSubjectMatches tests NATS-style subject wildcards.
+func TestSubjectMatches(t *testing.T) {
+	assert.True(t, subjectMatches("orders.created", "orders.created"))
+	assert.True(t, subjectMatches("orders.*", "orders.created"))
+	assert.True(t, subjectMatches("orders.>", "orders.eu.created"))
+	assert.False(t, subjectMatches("orders.*", "orders.eu.created"))
+	assert.False(t, subjectMatches("orders.>", "orders"))
+	assert.False(t, subjectMatches("orders.created", "orders.deleted"))
+}
+
+// TestMemoryBrokerUnsubscribeRedispatches tests that messages queued for a
+// member leaving its queue group are delivered by the remaining members.
+func TestMemoryBrokerUnsubscribeRedispatches(t *testing.T) {
+	b := NewMemoryBroker()
+	defer b.Close()
+
+	var mu sync.Mutex
+	delivered := map[string]int{}
+	record := func(msg *Message) {
+		mu.Lock()
+		delivered[string(msg.Data)]++
+		mu.Unlock()
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var once sync.Once
+	leaving, cancel := context.WithCancel(context.Background())
+	_, err := b.Subscribe(leaving, "jobs", "workers", func(ctx context.Context, msg *Message) error {
+		record(msg)
+		once.Do(func() { close(started) })
+		<-release
+		return nil
+	})
+	assert.NoError(t, err)
+	_, err = b.Subscribe(context.Background(), "jobs", "workers", func(ctx context.Context, msg *Message) error {
+		record(msg)
+		return nil
+	})
+	assert.NoError(t, err)
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		assert.NoError(t, b.Publish(context.Background(), "jobs", []byte(strconv.Itoa(i))))
+	}
+
+	// Leave the group while the first member is busy and has messages queued
+	<-started
+	cancel()
+	close(release)
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(delivered) == total
+	}, time.Second, 5*time.Millisecond)
+	for i := 0; i < total; i++ {
+		assert.Equal(t, 1, delivered[strconv.Itoa(i)], "message %d", i)
+	}
+}
+
+// TestMemoryBrokerDeadLetter tests that exhausted messages reach the
+// dead-letter subject even when a deduplicating stream captures it.
+func TestMemoryBrokerDeadLetter(t *testing.T) {
+	b := NewMemoryBroker(WithRedeliveryDelay(time.Millisecond), WithMaxDeliver(2), WithDeadLetter("orders.dead"))
+	defer b.Close()
+
+	ctx := context.Background()
+	assert.NoError(t, b.CreateStream("ORDERS", "orders.>", time.Minute))
+
+	dead := make(chan *Message, 1)
+	_, err := b.Subscribe(ctx, "orders.dead", "dlq", func(ctx context.Context, msg *Message) error {
+		dead <- msg
+		return nil
+	})
+	assert.NoError(t, err)
+	_, err = b.Subscribe(ctx, "orders.created", "workers", func(ctx context.Context, msg *Message) error {
+		return errors.New("boom")
+	})
+	assert.NoError(t, err)
+
+	assert.NoError(t, b.Publish(ctx, "orders.created", []byte("{}"), "order-1"))
+
+	select {
+	case msg := <-dead:
+		assert.Equal(t, "order-1:dlq", msg.ID)
+		assert.Equal(t, "order-1", msg.Header.Get(DeadLetterIDHeader))
+		assert.Equal(t, "orders.created", msg.Header.Get(DeadLetterSubjectHeader))
+		assert.Equal(t, "boom", msg.Header.Get(DeadLetterErrorHeader))
+	case <-time.After(time.Second):
+		t.Fatal("message was not dead-lettered")
+	}
+}
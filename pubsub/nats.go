@@ -2,7 +2,9 @@ package pubsub
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"log"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,25 +12,33 @@ import (
 	"github.com/redis/go-redis/v9"
 )
 
-// NATSBroker defines the structure of the NATS broker with Redis for deduplication
+// NATSBroker defines the structure of the NATS JetStream broker
 type NATSBroker struct {
 	conn  *nats.Conn
 	js    nats.JetStreamContext
 	redis *redis.Client
-	ctx   context.Context
+	dedup DedupStore
+	opts  options
 }
 
 // MustNewNATSBroker creates a new NATS broker and panics if there's an error
-func MustNewNATSBroker(url string, redisAddr string) *NATSBroker {
-	broker, err := NewNATSBroker(url, redisAddr)
+func MustNewNATSBroker(url string, redisAddr string, opts ...Option) *NATSBroker {
+	broker, err := NewNATSBroker(url, redisAddr, opts...)
 	if err != nil {
 		panic(fmt.Sprintf("Failed to create NATS broker: %v", err))
 	}
 	return broker
 }
 
-// NewNATSBroker initializes the NATS broker and the Redis client
-func NewNATSBroker(url string, redisAddr string) (*NATSBroker, error) {
+// NewNATSBroker initializes the NATS broker. Processed message IDs are tracked
+// in the store given by WithDedupStore, otherwise in Redis at redisAddr, or in
+// memory when redisAddr is empty.
+func NewNATSBroker(url string, redisAddr string, opts ...Option) (*NATSBroker, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
 	// Connect to NATS
 	nc, err := nats.Connect(url)
 	if err != nil {
@@ -38,29 +48,40 @@ func NewNATSBroker(url string, redisAddr string) (*NATSBroker, error) {
 	// Get JetStream context
 	js, err := nc.JetStream()
 	if err != nil {
+		nc.Close()
 		return nil, fmt.Errorf("failed to get JetStream context: %w", err)
 	}
 
-	// Initialize Redis client
-	rdb := redis.NewClient(&redis.Options{
-		Addr: redisAddr,
-	})
+	broker := &NATSBroker{
+		conn:  nc,
+		js:    js,
+		dedup: o.dedupStore,
+		opts:  o,
+	}
 
-	ctx := context.Background()
+	switch {
+	case broker.dedup != nil:
+	case redisAddr != "":
+		// Initialize Redis client
+		rdb := redis.NewClient(&redis.Options{
+			Addr: redisAddr,
+		})
 
-	// Test Redis connection with Ping
-	pong, err := rdb.Ping(ctx).Result()
-	if err != nil {
-		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		// Test Redis connection with Ping
+		pong, err := rdb.Ping(context.Background()).Result()
+		if err != nil {
+			nc.Close()
+			return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+		}
+		fmt.Printf("Redis connected: %v\n", pong)
+
+		broker.redis = rdb
+		broker.dedup = NewRedisDedupStore(rdb, "")
+	default:
+		broker.dedup = NewMemoryDedupStore()
 	}
-	fmt.Printf("Redis connected: %v\n", pong)
 
-	return &NATSBroker{
-		conn:  nc,
-		js:    js,
-		redis: rdb,
-		ctx:   ctx,
-	}, nil
+	return broker, nil
 }
 
 // CreateStream creates a new stream with a deduplication window
@@ -74,16 +95,22 @@ func (n *NATSBroker) CreateStream(streamName, subject string, dedupWindow time.D
 }
 
 // Publish publishes a message to a NATS JetStream subject using the provided msgID for deduplication
-func (n *NATSBroker) Publish(subject string, message []byte, msgID ...string) error {
-	var messageID string
-	if len(msgID) == 0 {
+func (n *NATSBroker) Publish(ctx context.Context, subject string, message []byte, msgID ...string) error {
+	msg := NewMessage(subject, message)
+	if len(msgID) > 0 {
+		msg.ID = msgID[0]
+	}
+	return n.PublishMsg(ctx, msg)
+}
+
+// PublishMsg publishes a message with its headers to a NATS JetStream subject
+func (n *NATSBroker) PublishMsg(ctx context.Context, msg *Message) error {
+	if msg.ID == "" {
 		// Generate a unique message ID if none is provided
-		messageID = uuid.New().String()
-	} else {
-		messageID = msgID[0]
+		msg.ID = uuid.New().String()
 	}
 
-	_, err := n.js.Publish(subject, message, nats.MsgId(messageID)) // Use message ID for deduplication
+	_, err := n.js.PublishMsg(toNATSMsg(msg), nats.MsgId(msg.ID), nats.Context(ctx)) // Use message ID for deduplication
 	if err != nil {
 		return fmt.Errorf("failed to publish message: %w", err)
 	}
@@ -91,50 +118,230 @@ func (n *NATSBroker) Publish(subject string, message []byte, msgID ...string) er
 	return nil
 }
 
+// Request publishes a message to a JetStream subject and waits for a reply.
+// The reply subject travels in the ReplyHeader since JetStream deliveries
+// replace the native reply subject with their ack subject.
+func (n *NATSBroker) Request(ctx context.Context, subject string, message []byte, timeout time.Duration) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	inbox := n.conn.NewRespInbox()
+	sub, err := n.conn.SubscribeSync(inbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe to reply inbox: %w", err)
+	}
+	defer sub.Unsubscribe()
+
+	msg := NewMessage(subject, message)
+	msg.Header.Set(ReplyHeader, inbox)
+	if err := n.PublishMsg(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	reply, err := sub.NextMsgWithContext(ctx)
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, ErrNoResponder
+		}
+		return nil, fmt.Errorf("failed to receive reply: %w", err)
+	}
+
+	return n.fromNATSMsg(reply), nil
+}
+
 // Subscribe subscribes to a NATS JetStream subject with a queue group and manual acknowledgment
-func (n *NATSBroker) Subscribe(subject, group string, handler func([]byte) ([]byte, error)) error {
-	_, err := n.js.QueueSubscribe(subject, group, func(msg *nats.Msg) {
-		n.processMessage(msg, handler)
-	}, nats.ManualAck()) // Enable manual acknowledgment
+func (n *NATSBroker) Subscribe(ctx context.Context, subject, group string, handler Handler) (Subscription, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	consumer := consumerKey(subject, group)
+	subOpts := []nats.SubOpt{nats.ManualAck(), nats.AckWait(n.opts.ackWait)} // Enable manual acknowledgment
+	if group == "" {
+		// An ephemeral consumer only receives what is published from now on
+		// instead of replaying the stream history
+		subOpts = append(subOpts, nats.DeliverNew())
+	}
+	sub, err := n.js.QueueSubscribe(subject, group, func(msg *nats.Msg) {
+		n.processMessage(ctx, consumer, msg, handler)
+	}, subOpts...)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+
+	s := &natsSubscription{sub: sub, cancel: cancel}
+	go func() {
+		<-ctx.Done()
+		s.Unsubscribe()
+	}()
+	return s, nil
+}
+
+// Close drains the NATS connection and closes the Redis client
+func (n *NATSBroker) Close() error {
+	err := n.conn.Drain()
+	if n.redis != nil {
+		err = errors.Join(err, n.redis.Close())
+	}
 	return err
 }
 
-// processMessage processes the message and checks Redis for deduplication
-func (n *NATSBroker) processMessage(msg *nats.Msg, handler func([]byte) ([]byte, error)) {
-	// Get the message ID from the message headers
-	msgID := msg.Header.Get("Nats-Msg-Id")
-	if msgID == "" {
-		// If no message ID, reject the message (this should never happen)
-		msg.Respond([]byte("No Message ID found"))
-		msg.Ack()
+// consumerKey scopes processed message IDs to the consumer that handled them,
+// so every queue group sees each message once. A subscription without a group
+// receives every message on its own and gets a key of its own.
+func consumerKey(subject, group string) string {
+	if group == "" {
+		group = "~" + uuid.New().String()
+	}
+	return subject + "|" + group
+}
+
+// processMessage runs handler unless consumer already processed the message.
+// A failed message is negatively acknowledged for redelivery after the
+// redelivery delay until it reaches max deliver, then it is dead-lettered.
+func (n *NATSBroker) processMessage(ctx context.Context, consumer string, msg *nats.Msg, handler Handler) {
+	n.handle(ctx, consumer, n.fromNATSMsg(msg), msg, handler)
+}
+
+// natsAcker is the acknowledgement API of a JetStream delivery
+type natsAcker interface {
+	Ack(opts ...nats.AckOpt) error
+	NakWithDelay(delay time.Duration, opts ...nats.AckOpt) error
+	Term(opts ...nats.AckOpt) error
+	InProgress(opts ...nats.AckOpt) error
+}
+
+// handle processes m for consumer and acknowledges its delivery through msg
+func (n *NATSBroker) handle(ctx context.Context, consumer string, m *Message, msg natsAcker, handler Handler) {
+	if m.ID == "" {
+		// Messages published through the broker always carry an ID, redelivering won't fix this
+		log.Printf("NATSBroker: terminating message without ID on subject %s", m.Subject)
+		msg.Term()
 		return
 	}
 
-	// Check Redis to see if this message ID has already been processed
-	exists, err := n.redis.Get(n.ctx, msgID).Result()
-	if err == redis.Nil {
-		// Message ID not found, process the message
-		response, err := handler(msg.Data)
-		if err != nil {
-			// Handle error and respond with failure message
-			msg.Respond([]byte("Error processing request"))
-		} else {
-			// Acknowledge the message
-			msg.Ack()
-
-			// Store the message ID in Redis with a TTL after it is acknowledged
-			ttl := 10 * time.Minute // Set the TTL for processed messages
-			n.redis.Set(n.ctx, msgID, "processed", ttl)
-
-			// Respond with success
-			msg.Respond(response)
-		}
-	} else if exists == "processed" {
+	// Check whether this consumer has already processed the message ID
+	key := consumer + "|" + m.ID
+	seen, err := n.dedup.Seen(ctx, key)
+	if err != nil {
+		log.Printf("NATSBroker: failed to check message %s: %v", m.ID, err)
+		msg.NakWithDelay(n.opts.redeliveryDelay)
+		return
+	}
+	if seen {
 		// Message ID has already been processed, acknowledge and ignore
 		msg.Ack()
-	} else if err != nil {
-		// Handle Redis error
-		msg.Respond([]byte("Error accessing Redis"))
-		msg.Ack()
+		return
+	}
+
+	stop := n.keepAlive(msg)
+	err = handler(ctx, m)
+	stop()
+
+	if err != nil {
+		if n.opts.maxDeliver > 0 && m.Attempt >= n.opts.maxDeliver {
+			n.deadLetter(m, err)
+			msg.Term()
+			return
+		}
+		msg.NakWithDelay(n.opts.redeliveryDelay)
+		return
+	}
+
+	// Record the message ID before acknowledging so a redelivery after a crash is skipped
+	if err := n.dedup.Mark(ctx, key, n.opts.dedupTTL); err != nil {
+		log.Printf("NATSBroker: failed to mark message %s as processed: %v", m.ID, err)
+	}
+	msg.Ack()
+}
+
+// keepAlive sends progress heartbeats for msg until the returned func is called
+func (n *NATSBroker) keepAlive(msg natsAcker) func() {
+	interval := n.opts.ackWait / 2
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				msg.InProgress()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// deadLetter publishes msg to the dead-letter subject, or drops it when none is configured
+func (n *NATSBroker) deadLetter(msg *Message, err error) {
+	if n.opts.deadLetterSubject == "" {
+		log.Printf("NATSBroker: dropping message %s on subject %s after %d attempts: %v", msg.ID, msg.Subject, msg.Attempt, err)
+		return
+	}
+
+	dl := deadLetter(n.opts.deadLetterSubject, msg, err)
+	// Drop the ID header copied from the original, the copy is published with its own
+	dl.Header.Del(nats.MsgIdHdr)
+	if _, pubErr := n.js.PublishMsg(toNATSMsg(dl), nats.MsgId(dl.ID)); pubErr != nil {
+		log.Printf("NATSBroker: failed to dead-letter message %s: %v", msg.ID, pubErr)
+	}
+}
+
+// natsSubscription wraps a JetStream subscription
+type natsSubscription struct {
+	sub    *nats.Subscription
+	cancel context.CancelFunc
+}
+
+func (s *natsSubscription) Subject() string {
+	return s.sub.Subject
+}
+
+func (s *natsSubscription) Unsubscribe() error {
+	s.cancel()
+	if err := s.sub.Unsubscribe(); err != nil && !errors.Is(err, nats.ErrBadSubscription) && !errors.Is(err, nats.ErrConnectionClosed) {
+		return err
+	}
+	return nil
+}
+
+// toNATSMsg converts a Message to a NATS message
+func toNATSMsg(msg *Message) *nats.Msg {
+	m := nats.NewMsg(msg.Subject)
+	m.Data = msg.Data
+	for k, v := range msg.Header {
+		m.Header[k] = v
+	}
+	if msg.Reply != "" && m.Header.Get(ReplyHeader) == "" {
+		m.Header.Set(ReplyHeader, msg.Reply)
+	}
+	return m
+}
+
+// fromNATSMsg converts a NATS message to a Message that replies over the broker connection
+func (n *NATSBroker) fromNATSMsg(msg *nats.Msg) *Message {
+	m := &Message{
+		ID:      msg.Header.Get(nats.MsgIdHdr),
+		Subject: msg.Subject,
+		Header:  Header(msg.Header),
+		Data:    msg.Data,
+	}
+	if m.Header == nil {
+		m.Header = Header{}
+	}
+	if meta, err := msg.Metadata(); err == nil {
+		m.Attempt = int(meta.NumDelivered)
+	}
+
+	m.Reply = m.Header.Get(ReplyHeader)
+	if m.Reply != "" {
+		m.respond = func(data []byte) error {
+			return n.conn.Publish(m.Reply, data)
+		}
 	}
+	return m
 }
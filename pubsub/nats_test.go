@@ -0,0 +1,138 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeAcker records the acknowledgement of a JetStream delivery
+type fakeAcker struct {
+	acks []string
+}
+
+func (a *fakeAcker) Ack(opts ...nats.AckOpt) error {
+	a.acks = append(a.acks, "ack")
+	return nil
+}
+
+func (a *fakeAcker) NakWithDelay(delay time.Duration, opts ...nats.AckOpt) error {
+	a.acks = append(a.acks, "nak "+delay.String())
+	return nil
+}
+
+func (a *fakeAcker) Term(opts ...nats.AckOpt) error {
+	a.acks = append(a.acks, "term")
+	return nil
+}
+
+func (a *fakeAcker) InProgress(opts ...nats.AckOpt) error {
+	return nil
+}
+
+// fakeJetStream records the messages published by the broker
+type fakeJetStream struct {
+	nats.JetStreamContext
+	published []*nats.Msg
+}
+
+func (js *fakeJetStream) PublishMsg(m *nats.Msg, opts ...nats.PubOpt) (*nats.PubAck, error) {
+	js.published = append(js.published, m)
+	return &nats.PubAck{}, nil
+}
+
+func newTestNATSBroker(opts ...Option) (*NATSBroker, *fakeJetStream) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+	js := &fakeJetStream{}
+	return &NATSBroker{js: js, dedup: NewMemoryDedupStore(), opts: o}, js
+}
+
+// TestNATSBrokerAcknowledgement tests how deliveries are acknowledged.
+func TestNATSBrokerAcknowledgement(t *testing.T) {
+	n, js := newTestNATSBroker(WithRedeliveryDelay(time.Second), WithMaxDeliver(3), WithAckWait(0), WithDeadLetter("orders.dead"))
+	ctx := context.Background()
+	failing := func(ctx context.Context, msg *Message) error {
+		return errors.New("boom")
+	}
+	succeeding := func(ctx context.Context, msg *Message) error {
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		msg     *Message
+		handler Handler
+		want    string
+	}{
+		{"without ID", &Message{Subject: "orders.created", Attempt: 1}, succeeding, "term"},
+		{"failed", &Message{ID: "order-1", Subject: "orders.created", Header: Header{}, Attempt: 1}, failing, "nak 1s"},
+		{"exhausted", &Message{ID: "order-1", Subject: "orders.created", Header: Header{nats.MsgIdHdr: {"order-1"}}, Attempt: 3}, failing, "term"},
+		{"processed", &Message{ID: "order-2", Subject: "orders.created", Header: Header{}, Attempt: 1}, succeeding, "ack"},
+		{"duplicate", &Message{ID: "order-2", Subject: "orders.created", Header: Header{}, Attempt: 2}, failing, "ack"},
+	}
+	for _, tt := range tests {
+		acker := &fakeAcker{}
+		n.handle(ctx, "orders.created|billing", tt.msg, acker, tt.handler)
+		assert.Equal(t, []string{tt.want}, acker.acks, tt.name)
+	}
+
+	// Only the exhausted message is dead-lettered
+	require.Len(t, js.published, 1)
+	dl := js.published[0]
+	assert.Equal(t, "orders.dead", dl.Subject)
+	assert.Empty(t, dl.Header.Get(nats.MsgIdHdr))
+	assert.Equal(t, "order-1", dl.Header.Get(DeadLetterIDHeader))
+	assert.Equal(t, "orders.created", dl.Header.Get(DeadLetterSubjectHeader))
+	assert.Equal(t, "boom", dl.Header.Get(DeadLetterErrorHeader))
+}
+
+// TestNATSBrokerConsumerDedup tests that each consumer processes a message once.
+func TestNATSBrokerConsumerDedup(t *testing.T) {
+	n, _ := newTestNATSBroker()
+	ctx := context.Background()
+	handled := map[string]int{}
+	handler := func(consumer string) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			handled[consumer]++
+			return nil
+		}
+	}
+
+	consumers := []string{
+		consumerKey("orders.created", "billing"),
+		consumerKey("orders.created", "email"),
+		consumerKey("orders.created", ""),
+		consumerKey("orders.created", ""),
+	}
+	for _, consumer := range consumers {
+		for attempt := 1; attempt <= 2; attempt++ {
+			msg := &Message{ID: "order-1", Subject: "orders.created", Header: Header{}, Attempt: attempt}
+			n.handle(ctx, consumer, msg, &fakeAcker{}, handler(consumer))
+		}
+	}
+
+	require.Len(t, handled, len(consumers), "group-less subscriptions get keys of their own")
+	for _, consumer := range consumers {
+		assert.Equal(t, 1, handled[consumer], consumer)
+	}
+}
+
+// TestDeadLetter tests that dead letters get an ID of their own.
+func TestDeadLetter(t *testing.T) {
+	msg := &Message{ID: "order-1", Subject: "orders.created", Header: Header{}, Data: []byte("{}")}
+	msg.Header.Set("Trace-Id", "abc")
+
+	dl := deadLetter("orders.dead", msg, errors.New("boom"))
+	assert.Equal(t, "order-1:dlq", dl.ID)
+	assert.Equal(t, "abc", dl.Header.Get("Trace-Id"))
+	assert.Equal(t, "order-1", dl.Header.Get(DeadLetterIDHeader))
+	assert.Empty(t, msg.Header.Get(DeadLetterIDHeader), "the original message is unchanged")
+}
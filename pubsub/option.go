@@ -0,0 +1,88 @@
+package pubsub
+
+import "time"
+
+type (
+	// Option defines the method to customize a broker.
+	Option func(opt *options)
+
+	options struct {
+		redeliveryDelay   time.Duration
+		maxDeliver        int
+		deadLetterSubject string
+		ackWait           time.Duration
+		dedupStore        DedupStore
+		dedupTTL          time.Duration
+		keyPrefix         string
+		streamMaxLen      int64
+	}
+)
+
+// defaultOptions returns the options shared by all brokers
+func defaultOptions() options {
+	return options{
+		redeliveryDelay: time.Second,
+		maxDeliver:      5,
+		ackWait:         30 * time.Second,
+		dedupTTL:        10 * time.Minute,
+		keyPrefix:       "soul:",
+		streamMaxLen:    100000,
+	}
+}
+
+// WithRedeliveryDelay sets the delay before a message whose handler failed is delivered again.
+func WithRedeliveryDelay(delay time.Duration) Option {
+	return func(opt *options) {
+		opt.redeliveryDelay = delay
+	}
+}
+
+// WithMaxDeliver sets the maximum number of delivery attempts per message, 0 means unlimited.
+func WithMaxDeliver(maxDeliver int) Option {
+	return func(opt *options) {
+		opt.maxDeliver = maxDeliver
+	}
+}
+
+// WithDeadLetter publishes messages that exhausted their delivery attempts to subject.
+func WithDeadLetter(subject string) Option {
+	return func(opt *options) {
+		opt.deadLetterSubject = subject
+	}
+}
+
+// WithAckWait sets how long the server waits for an acknowledgement before redelivering.
+// Long running handlers send progress heartbeats at half this interval.
+func WithAckWait(ackWait time.Duration) Option {
+	return func(opt *options) {
+		opt.ackWait = ackWait
+	}
+}
+
+// WithDedupStore sets the store tracking processed message IDs.
+func WithDedupStore(store DedupStore) Option {
+	return func(opt *options) {
+		opt.dedupStore = store
+	}
+}
+
+// WithDedupTTL sets how long processed message IDs are remembered.
+func WithDedupTTL(ttl time.Duration) Option {
+	return func(opt *options) {
+		opt.dedupTTL = ttl
+	}
+}
+
+// WithKeyPrefix sets the prefix of the keys used by the Redis Streams broker.
+func WithKeyPrefix(prefix string) Option {
+	return func(opt *options) {
+		opt.keyPrefix = prefix
+	}
+}
+
+// WithStreamMaxLen sets the approximate number of entries a Redis stream is trimmed to, 0 disables trimming.
+func WithStreamMaxLen(maxLen int64) Option {
+	return func(opt *options) {
+		opt.streamMaxLen = maxLen
+	}
+}
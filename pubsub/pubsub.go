@@ -1,23 +1,134 @@
 package pubsub
 
 import (
+	"context"
 	"encoding/json"
-	"log"
+	"errors"
+	"fmt"
 	"time"
 )
 
+var (
+	ErrNoReply     = errors.New("pubsub: message has no reply subject")
+	ErrNoResponder = errors.New("pubsub: no responders available for request")
+	ErrClosed      = errors.New("pubsub: broker is closed")
+)
+
+const (
+	// ReplyHeader carries the reply subject of a request through brokers that
+	// do not preserve a native reply subject (e.g. JetStream deliveries)
+	ReplyHeader = "Soul-Reply-To"
+	// DeadLetterIDHeader holds the ID of the original message of a dead letter
+	DeadLetterIDHeader = "Soul-Dead-Letter-ID"
+	// DeadLetterSubjectHeader holds the original subject of a dead-lettered message
+	DeadLetterSubjectHeader = "Soul-Dead-Letter-Subject"
+	// DeadLetterErrorHeader holds the last handler error of a dead-lettered message
+	DeadLetterErrorHeader = "Soul-Dead-Letter-Error"
+)
+
 // Broker is the interface that wraps the methods for a message broker
 type Broker interface {
-	Publish(subject string, message []byte, msgID ...string) error
-	Subscribe(subject string, group string, handler func([]byte) ([]byte, error)) error
+	// Publish publishes message to subject. The optional msgID is used for deduplication.
+	Publish(ctx context.Context, subject string, message []byte, msgID ...string) error
+	// PublishMsg publishes msg including its headers. An empty msg.ID is generated by the broker.
+	PublishMsg(ctx context.Context, msg *Message) error
+	// Subscribe registers handler on subject. Subscribers sharing a group receive each message once.
+	// The subscription ends when ctx is done or Unsubscribe is called.
+	Subscribe(ctx context.Context, subject, group string, handler Handler) (Subscription, error)
+	// Request publishes message to subject and waits up to timeout for the first reply.
+	Request(ctx context.Context, subject string, message []byte, timeout time.Duration) (*Message, error)
+	// CreateStream creates a stream for subject with the given deduplication window
 	CreateStream(streamName, subject string, dedupWindow time.Duration) error
+	// Close releases the connections held by the broker
+	Close() error
+}
+
+// Handler processes a message delivered to a subscription. Returning an
+// error signals that the message was not processed.
+type Handler func(ctx context.Context, msg *Message) error
+
+// Subscription is a handle to an active subscription
+type Subscription interface {
+	Subject() string
+	Unsubscribe() error
+}
+
+// Header holds message metadata as key/value pairs
+type Header map[string][]string
+
+// Get returns the first value associated with key
+func (h Header) Get(key string) string {
+	if v := h[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+// Set replaces any existing values of key with value
+func (h Header) Set(key, value string) {
+	h[key] = []string{value}
+}
+
+// Add appends value to the values of key
+func (h Header) Add(key, value string) {
+	h[key] = append(h[key], value)
+}
+
+// Del removes the values of key
+func (h Header) Del(key string) {
+	delete(h, key)
+}
+
+// Message is a message published to or delivered from a Broker
+type Message struct {
+	ID      string
+	Subject string
+	Reply   string
+	Header  Header
+	Data    []byte
+	// Attempt is the delivery attempt of the message, starting at 1
+	Attempt int
+
+	respond func([]byte) error
+}
+
+// NewMessage creates a message for subject with the given payload
+func NewMessage(subject string, data []byte) *Message {
+	return &Message{
+		Subject: subject,
+		Header:  Header{},
+		Data:    data,
+	}
+}
+
+// Respond sends data to the requester of the message
+func (m *Message) Respond(data []byte) error {
+	if m.Reply == "" || m.respond == nil {
+		return ErrNoReply
+	}
+	return m.respond(data)
 }
 
 // Marshal marshals the given value to a JSON byte slice
-func Marshal(v any) []byte {
+func Marshal(v any) ([]byte, error) {
 	b, err := json.Marshal(v)
 	if err != nil {
-		log.Println(err)
+		return nil, fmt.Errorf("failed to marshal message: %w", err)
+	}
+	return b, nil
+}
+
+// deadLetter builds the dead-letter copy of msg that failed with err. The copy
+// gets an ID of its own so a stream capturing both the original and the
+// dead-letter subject does not discard it as a duplicate.
+func deadLetter(subject string, msg *Message, err error) *Message {
+	dl := NewMessage(subject, msg.Data)
+	dl.ID = msg.ID + ":dlq"
+	for k, v := range msg.Header {
+		dl.Header[k] = v
 	}
-	return b
+	dl.Header.Set(DeadLetterIDHeader, msg.ID)
+	dl.Header.Set(DeadLetterSubjectHeader, msg.Subject)
+	dl.Header.Set(DeadLetterErrorHeader, err.Error())
+	return dl
 }
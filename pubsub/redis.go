@@ -0,0 +1,491 @@
+package pubsub
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// publishScript atomically checks the deduplication window configured for a
+// subject and appends the message to its stream.
+//
+// KEYS[1] stream, KEYS[2] dedup key, KEYS[3] stream config hash
+// ARGV[1] subject, ARGV[2] max length, ARGV[3...] field/value pairs
+var publishScript = redis.NewScript(`
+local window = tonumber(redis.call('HGET', KEYS[3], ARGV[1]) or '0')
+if window > 0 and not redis.call('SET', KEYS[2], '1', 'NX', 'PX', window) then
+	return false
+end
+local args = {'XADD', KEYS[1]}
+local maxlen = tonumber(ARGV[2])
+if maxlen > 0 then
+	table.insert(args, 'MAXLEN')
+	table.insert(args, '~')
+	table.insert(args, maxlen)
+end
+table.insert(args, '*')
+for i = 3, #ARGV do
+	table.insert(args, ARGV[i])
+end
+return redis.call(unpack(args))
+`)
+
+// RedisStreamsBroker implements Broker on Redis Streams. Each subject maps to
+// a stream and each group to a consumer group; messages left pending by a
+// crashed consumer are reclaimed by the others after the ack wait.
+type RedisStreamsBroker struct {
+	client   *redis.Client
+	consumer string
+	opts     options
+
+	mu     sync.Mutex
+	closed bool
+	subs   map[*redisSubscription]struct{}
+	wg     sync.WaitGroup
+}
+
+// MustNewRedisStreamsBroker creates a new Redis Streams broker and panics if there's an error
+func MustNewRedisStreamsBroker(redisAddr string, opts ...Option) *RedisStreamsBroker {
+	broker, err := NewRedisStreamsBroker(redisAddr, opts...)
+	if err != nil {
+		panic(fmt.Sprintf("Failed to create Redis Streams broker: %v", err))
+	}
+	return broker
+}
+
+// NewRedisStreamsBroker initializes the Redis Streams broker
+func NewRedisStreamsBroker(redisAddr string, opts ...Option) (*RedisStreamsBroker, error) {
+	o := defaultOptions()
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	rdb := redis.NewClient(&redis.Options{
+		Addr: redisAddr,
+	})
+	if err := rdb.Ping(context.Background()).Err(); err != nil {
+		rdb.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+
+	hostname, _ := os.Hostname()
+	return &RedisStreamsBroker{
+		client:   rdb,
+		consumer: hostname + "-" + uuid.New().String()[:8],
+		opts:     o,
+		subs:     make(map[*redisSubscription]struct{}),
+	}, nil
+}
+
+// CreateStream records the deduplication window of subject, streams themselves are created on first use
+func (r *RedisStreamsBroker) CreateStream(streamName, subject string, dedupWindow time.Duration) error {
+	return r.client.HSet(context.Background(), r.key("streams"), subject, dedupWindow.Milliseconds()).Err()
+}
+
+// Publish publishes a message to the stream of subject using the provided msgID for deduplication
+func (r *RedisStreamsBroker) Publish(ctx context.Context, subject string, message []byte, msgID ...string) error {
+	msg := NewMessage(subject, message)
+	if len(msgID) > 0 {
+		msg.ID = msgID[0]
+	}
+	return r.PublishMsg(ctx, msg)
+}
+
+// PublishMsg publishes a message with its headers to the stream of its subject
+func (r *RedisStreamsBroker) PublishMsg(ctx context.Context, msg *Message) error {
+	if msg.ID == "" {
+		// Generate a unique message ID if none is provided
+		msg.ID = uuid.New().String()
+	}
+
+	header, err := json.Marshal(msg.Header)
+	if err != nil {
+		return fmt.Errorf("failed to marshal message header: %w", err)
+	}
+
+	keys := []string{r.stream(msg.Subject), r.key("dedup:" + msg.Subject + ":" + msg.ID), r.key("streams")}
+	args := []any{msg.Subject, r.opts.streamMaxLen, "id", msg.ID, "data", msg.Data, "header", header, "reply", msg.Reply}
+	if err := publishScript.Run(ctx, r.client, keys, args...).Err(); err != nil && !errors.Is(err, redis.Nil) {
+		return fmt.Errorf("failed to publish message: %w", err)
+	}
+	return nil
+}
+
+// Request publishes a message and waits up to timeout for a reply on a Redis pub/sub inbox
+func (r *RedisStreamsBroker) Request(ctx context.Context, subject string, message []byte, timeout time.Duration) (*Message, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	inbox := r.key("inbox:" + uuid.New().String())
+	ps := r.client.Subscribe(ctx, inbox)
+	defer ps.Close()
+
+	// Wait for the subscription to be confirmed so the reply can't be missed
+	if _, err := ps.Receive(ctx); err != nil {
+		return nil, fmt.Errorf("failed to subscribe to reply inbox: %w", err)
+	}
+
+	msg := NewMessage(subject, message)
+	msg.Reply = inbox
+	if err := r.PublishMsg(ctx, msg); err != nil {
+		return nil, err
+	}
+
+	select {
+	case reply := <-ps.Channel():
+		return NewMessage(inbox, []byte(reply.Payload)), nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, ErrNoResponder
+		}
+		return nil, ctx.Err()
+	}
+}
+
+// Subscribe consumes the stream of subject with the consumer group named group.
+// An empty group creates a consumer group private to the subscription that only
+// receives new messages and is destroyed on unsubscribe. Wildcard subjects are
+// not supported.
+func (r *RedisStreamsBroker) Subscribe(ctx context.Context, subject, group string, handler Handler) (Subscription, error) {
+	if strings.ContainsAny(subject, "*>") {
+		return nil, fmt.Errorf("redis streams broker does not support wildcard subject %s", subject)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.closed {
+		return nil, ErrClosed
+	}
+
+	// Named groups start at the beginning of the stream like a durable consumer
+	start := "0"
+	ephemeral := group == ""
+	if ephemeral {
+		group = "soul-" + uuid.New().String()
+		start = "$"
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	sub := &redisSubscription{
+		broker:    r,
+		subject:   subject,
+		stream:    r.stream(subject),
+		group:     group,
+		start:     start,
+		ephemeral: ephemeral,
+		handler:   handler,
+		ctx:       ctx,
+		cancel:    cancel,
+	}
+
+	if err := sub.createGroup(); err != nil && !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		cancel()
+		return nil, fmt.Errorf("failed to create consumer group %s: %w", group, err)
+	}
+	r.subs[sub] = struct{}{}
+
+	r.wg.Add(2)
+	go sub.read()
+	go sub.reclaim()
+
+	return sub, nil
+}
+
+// Close stops all subscriptions, waits for in-flight handlers and closes the Redis client
+func (r *RedisStreamsBroker) Close() error {
+	r.mu.Lock()
+	if r.closed {
+		r.mu.Unlock()
+		return nil
+	}
+	r.closed = true
+	for sub := range r.subs {
+		sub.cancel()
+	}
+	r.mu.Unlock()
+
+	r.wg.Wait()
+	return r.client.Close()
+}
+
+func (r *RedisStreamsBroker) key(name string) string {
+	return r.opts.keyPrefix + name
+}
+
+func (r *RedisStreamsBroker) stream(subject string) string {
+	return r.key("stream:" + subject)
+}
+
+// toMessage converts a stream entry to a Message delivered for the attempt-th time
+func (r *RedisStreamsBroker) toMessage(subject string, xm redis.XMessage, attempt int) *Message {
+	field := func(name string) string {
+		v, _ := xm.Values[name].(string)
+		return v
+	}
+
+	msg := &Message{
+		ID:      field("id"),
+		Subject: subject,
+		Reply:   field("reply"),
+		Header:  Header{},
+		Data:    []byte(field("data")),
+		Attempt: attempt,
+	}
+	if h := field("header"); h != "" {
+		if err := json.Unmarshal([]byte(h), &msg.Header); err != nil {
+			log.Printf("RedisStreamsBroker: ignoring malformed header of message %s: %v", msg.ID, err)
+		}
+	}
+	if msg.Header == nil {
+		msg.Header = Header{}
+	}
+	if msg.Reply != "" {
+		msg.respond = func(data []byte) error {
+			return r.client.Publish(context.Background(), msg.Reply, data).Err()
+		}
+	}
+	return msg
+}
+
+// redisSubscription is a consumer of a consumer group
+type redisSubscription struct {
+	broker    *RedisStreamsBroker
+	subject   string
+	stream    string
+	group     string
+	start     string // position the group starts reading the stream at
+	ephemeral bool
+	handler   Handler
+	ctx       context.Context
+	cancel    context.CancelFunc
+}
+
+func (s *redisSubscription) Subject() string {
+	return s.subject
+}
+
+// Unsubscribe stops consuming. Pending messages of a named group are reclaimed by the remaining consumers.
+func (s *redisSubscription) Unsubscribe() error {
+	s.cancel()
+
+	r := s.broker
+	r.mu.Lock()
+	delete(r.subs, s)
+	r.mu.Unlock()
+	return nil
+}
+
+// createGroup creates the consumer group, and the stream if it is missing, at the start position of the subscription
+func (s *redisSubscription) createGroup() error {
+	return s.broker.client.XGroupCreateMkStream(s.ctx, s.stream, s.group, s.start).Err()
+}
+
+// read delivers new messages of the stream until the subscription ends
+func (s *redisSubscription) read() {
+	r := s.broker
+	defer r.wg.Done()
+	defer s.Unsubscribe()
+
+	if s.ephemeral {
+		defer r.client.XGroupDestroy(context.Background(), s.stream, s.group)
+	}
+
+	for s.ctx.Err() == nil {
+		streams, err := r.client.XReadGroup(s.ctx, &redis.XReadGroupArgs{
+			Group:    s.group,
+			Consumer: r.consumer,
+			Streams:  []string{s.stream, ">"},
+			Count:    10,
+			Block:    2 * time.Second,
+		}).Result()
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			if s.ctx.Err() != nil {
+				return
+			}
+			if strings.HasPrefix(err.Error(), "NOGROUP") {
+				// The stream was deleted, recreate it along with the group
+				s.createGroup()
+				continue
+			}
+			log.Printf("RedisStreamsBroker: failed to read stream %s: %v", s.stream, err)
+			s.sleep(r.opts.redeliveryDelay)
+			continue
+		}
+
+		for _, st := range streams {
+			for _, xm := range st.Messages {
+				s.process(xm, 1)
+			}
+		}
+	}
+}
+
+// reclaim claims messages that stayed pending longer than the ack wait, such as
+// those of crashed consumers, and delivers them again
+func (s *redisSubscription) reclaim() {
+	r := s.broker
+	defer r.wg.Done()
+
+	for s.sleep(r.opts.ackWait) {
+		start := "0-0"
+		for {
+			msgs, next, err := r.client.XAutoClaim(s.ctx, &redis.XAutoClaimArgs{
+				Stream:   s.stream,
+				Group:    s.group,
+				Consumer: r.consumer,
+				MinIdle:  r.opts.ackWait,
+				Start:    start,
+				Count:    10,
+			}).Result()
+			if err != nil {
+				if s.ctx.Err() == nil {
+					log.Printf("RedisStreamsBroker: failed to reclaim messages of stream %s: %v", s.stream, err)
+				}
+				break
+			}
+
+			for _, xm := range msgs {
+				s.process(xm, s.deliveryCount(xm.ID))
+			}
+			if next == "0-0" || s.ctx.Err() != nil {
+				break
+			}
+			start = next
+		}
+	}
+}
+
+// process runs the handler for xm. A failed message stays pending and is claimed
+// again after the redelivery delay until it reaches max deliver, then it is dead-lettered.
+func (s *redisSubscription) process(xm redis.XMessage, attempt int) {
+	r := s.broker
+	msg := r.toMessage(s.subject, xm, attempt)
+
+	stop := s.keepAlive(xm.ID)
+	err := s.handler(s.ctx, msg)
+	stop()
+
+	if err == nil {
+		s.ack(xm.ID)
+		return
+	}
+	if s.ctx.Err() != nil {
+		// Leave the message pending for another consumer
+		return
+	}
+
+	if r.opts.maxDeliver > 0 && attempt >= r.opts.maxDeliver {
+		if r.opts.deadLetterSubject == "" {
+			log.Printf("RedisStreamsBroker: dropping message %s on subject %s after %d attempts: %v", msg.ID, msg.Subject, attempt, err)
+		} else if dlErr := r.PublishMsg(context.Background(), deadLetter(r.opts.deadLetterSubject, msg, err)); dlErr != nil {
+			log.Printf("RedisStreamsBroker: failed to dead-letter message %s: %v", msg.ID, dlErr)
+			return
+		}
+		s.ack(xm.ID)
+		return
+	}
+
+	r.wg.Add(1)
+	time.AfterFunc(r.opts.redeliveryDelay, func() {
+		defer r.wg.Done()
+		s.retry(xm.ID, attempt+1)
+	})
+}
+
+// retry claims a failed message back to this consumer and processes it again
+func (s *redisSubscription) retry(id string, attempt int) {
+	if s.ctx.Err() != nil {
+		return
+	}
+
+	r := s.broker
+	msgs, err := r.client.XClaim(s.ctx, &redis.XClaimArgs{
+		Stream:   s.stream,
+		Group:    s.group,
+		Consumer: r.consumer,
+		Messages: []string{id},
+	}).Result()
+	if err != nil {
+		log.Printf("RedisStreamsBroker: failed to claim message %s for redelivery: %v", id, err)
+		return
+	}
+	for _, xm := range msgs {
+		s.process(xm, attempt)
+	}
+}
+
+// keepAlive resets the idle time of a pending message until the returned func is
+// called so long running handlers are not reclaimed by other consumers
+func (s *redisSubscription) keepAlive(id string) func() {
+	r := s.broker
+	interval := r.opts.ackWait / 2
+	if interval <= 0 {
+		return func() {}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				r.client.XClaimJustID(s.ctx, &redis.XClaimArgs{
+					Stream:   s.stream,
+					Group:    s.group,
+					Consumer: r.consumer,
+					Messages: []string{id},
+				})
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+func (s *redisSubscription) ack(id string) {
+	if err := s.broker.client.XAck(context.Background(), s.stream, s.group, id).Err(); err != nil {
+		log.Printf("RedisStreamsBroker: failed to ack message %s: %v", id, err)
+	}
+}
+
+// deliveryCount returns how many times the pending message id has been delivered
+func (s *redisSubscription) deliveryCount(id string) int {
+	pending, err := s.broker.client.XPendingExt(s.ctx, &redis.XPendingExtArgs{
+		Stream: s.stream,
+		Group:  s.group,
+		Start:  id,
+		End:    id,
+		Count:  1,
+	}).Result()
+	if err != nil || len(pending) == 0 {
+		return 1
+	}
+	return int(pending[0].RetryCount)
+}
+
+// sleep waits for d and reports whether the subscription is still active
+func (s *redisSubscription) sleep(d time.Duration) bool {
+	select {
+	case <-s.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+var _ Broker = (*RedisStreamsBroker)(nil)
@@ -0,0 +1,247 @@
+package pubsub
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestRedisBroker(t *testing.T, mr *miniredis.Miniredis, opts ...Option) *RedisStreamsBroker {
+	t.Helper()
+	b, err := NewRedisStreamsBroker(mr.Addr(), opts...)
+	require.NoError(t, err)
+	t.Cleanup(func() { b.Close() })
+	return b
+}
+
+// TestRedisStreamsBrokerDeduplication tests that message IDs are deduplicated within the stream window.
+func TestRedisStreamsBrokerDeduplication(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := newTestRedisBroker(t, mr)
+	ctx := context.Background()
+
+	require.NoError(t, b.CreateStream("ORDERS", "orders.created", time.Minute))
+	require.NoError(t, b.Publish(ctx, "orders.created", []byte("a"), "order-1"))
+	require.NoError(t, b.Publish(ctx, "orders.created", []byte("a"), "order-1"))
+	require.NoError(t, b.Publish(ctx, "orders.created", []byte("b"), "order-2"))
+
+	// Subjects without a stream window are not deduplicated
+	require.NoError(t, b.Publish(ctx, "orders.deleted", []byte("a"), "order-1"))
+	require.NoError(t, b.Publish(ctx, "orders.deleted", []byte("a"), "order-1"))
+
+	entries, err := mr.Stream("soul:stream:orders.created")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+	entries, err = mr.Stream("soul:stream:orders.deleted")
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	mr.FastForward(2 * time.Minute)
+	require.NoError(t, b.Publish(ctx, "orders.created", []byte("a"), "order-1"))
+	entries, err = mr.Stream("soul:stream:orders.created")
+	require.NoError(t, err)
+	assert.Len(t, entries, 3, "IDs are accepted again after the window")
+}
+
+// TestRedisSubscriptionCreateGroup tests that a recreated group keeps the start position of its subscription.
+func TestRedisSubscriptionCreateGroup(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := newTestRedisBroker(t, mr)
+	ctx := context.Background()
+	stream := b.stream("orders.created")
+	require.NoError(t, b.client.XAdd(ctx, &redis.XAddArgs{Stream: stream, Values: map[string]any{"id": "order-1"}}).Err())
+
+	tests := []struct {
+		start string
+		want  int
+	}{
+		{"$", 0},
+		{"0", 1},
+	}
+	for _, tt := range tests {
+		sub := &redisSubscription{broker: b, stream: stream, group: "g" + tt.start, start: tt.start, ctx: ctx}
+		require.NoError(t, sub.createGroup())
+
+		streams, err := b.client.XReadGroup(ctx, &redis.XReadGroupArgs{
+			Group:    sub.group,
+			Consumer: "c",
+			Streams:  []string{stream, ">"},
+			Block:    -1,
+		}).Result()
+		if tt.want == 0 {
+			assert.ErrorIs(t, err, redis.Nil, tt.start)
+			continue
+		}
+		require.NoError(t, err)
+		assert.Len(t, streams[0].Messages, tt.want, tt.start)
+	}
+}
+
+// TestRedisStreamsBrokerQueueGroups tests that a consumer group receives each
+// message once across brokers while a subscription without group receives all.
+func TestRedisStreamsBrokerQueueGroups(t *testing.T) {
+	mr := miniredis.RunT(t)
+	brokers := []*RedisStreamsBroker{newTestRedisBroker(t, mr), newTestRedisBroker(t, mr)}
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	workers := map[string]int{}
+	audit := map[string]int{}
+	record := func(counts map[string]int) Handler {
+		return func(ctx context.Context, msg *Message) error {
+			mu.Lock()
+			counts[string(msg.Data)]++
+			mu.Unlock()
+			return nil
+		}
+	}
+
+	for _, b := range brokers {
+		_, err := b.Subscribe(ctx, "orders", "workers", record(workers))
+		require.NoError(t, err)
+	}
+	_, err := brokers[0].Subscribe(ctx, "orders", "", record(audit))
+	require.NoError(t, err)
+
+	const total = 10
+	for i := 0; i < total; i++ {
+		require.NoError(t, brokers[1].Publish(ctx, "orders", []byte(strconv.Itoa(i))))
+	}
+
+	assert.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(workers) == total && len(audit) == total
+	}, 5*time.Second, 10*time.Millisecond)
+
+	mu.Lock()
+	defer mu.Unlock()
+	for i := 0; i < total; i++ {
+		assert.Equal(t, 1, workers[strconv.Itoa(i)], "message %d", i)
+		assert.Equal(t, 1, audit[strconv.Itoa(i)], "message %d", i)
+	}
+
+	_, err = brokers[0].Subscribe(ctx, "orders.*", "workers", record(workers))
+	assert.Error(t, err, "wildcards are not supported")
+}
+
+// TestRedisStreamsBrokerRedelivery tests that a failed message is delivered
+// again until the handler succeeds, and dead-lettered once it runs out of attempts.
+func TestRedisStreamsBrokerRedelivery(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := newTestRedisBroker(t, mr, WithRedeliveryDelay(10*time.Millisecond), WithMaxDeliver(3), WithDeadLetter("jobs.dead"))
+	ctx := context.Background()
+
+	attempts := make(chan int, 10)
+	_, err := b.Subscribe(ctx, "jobs", "workers", func(ctx context.Context, msg *Message) error {
+		attempts <- msg.Attempt
+		if string(msg.Data) == "poison" || msg.Attempt < 2 {
+			return errors.New("not yet")
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	dead := make(chan *Message, 1)
+	_, err = b.Subscribe(ctx, "jobs.dead", "dlq", func(ctx context.Context, msg *Message) error {
+		dead <- msg
+		return nil
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, b.Publish(ctx, "jobs", []byte("run")))
+	for want := 1; want <= 2; want++ {
+		select {
+		case got := <-attempts:
+			assert.Equal(t, want, got)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("attempt %d was not delivered", want)
+		}
+	}
+
+	require.NoError(t, b.Publish(ctx, "jobs", []byte("poison"), "job-2"))
+	select {
+	case msg := <-dead:
+		assert.Equal(t, "job-2:dlq", msg.ID)
+		assert.Equal(t, "jobs", msg.Header.Get(DeadLetterSubjectHeader))
+	case <-time.After(5 * time.Second):
+		t.Fatal("message was not dead-lettered")
+	}
+
+	assert.Eventually(t, func() bool {
+		pending, err := b.client.XPending(ctx, "soul:stream:jobs", "workers").Result()
+		return err == nil && pending.Count == 0
+	}, time.Second, 10*time.Millisecond, "every message is acknowledged")
+}
+
+// TestRedisStreamsBrokerReclaim tests that messages left pending by a crashed
+// consumer are claimed and processed after the ack wait.
+func TestRedisStreamsBrokerReclaim(t *testing.T) {
+	mr := miniredis.RunT(t)
+	b := newTestRedisBroker(t, mr, WithAckWait(50*time.Millisecond))
+	ctx := context.Background()
+
+	require.NoError(t, b.Publish(ctx, "jobs", []byte("orphan"), "job-1"))
+
+	// A consumer reads the message and crashes before acknowledging it
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	defer client.Close()
+	require.NoError(t, client.XGroupCreate(ctx, "soul:stream:jobs", "workers", "0").Err())
+	streams, err := client.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    "workers",
+		Consumer: "crashed",
+		Streams:  []string{"soul:stream:jobs", ">"},
+		Count:    1,
+	}).Result()
+	require.NoError(t, err)
+	require.Len(t, streams[0].Messages, 1)
+
+	received := make(chan *Message, 1)
+	_, err = b.Subscribe(ctx, "jobs", "workers", func(ctx context.Context, msg *Message) error {
+		received <- msg
+		return nil
+	})
+	require.NoError(t, err)
+
+	select {
+	case msg := <-received:
+		assert.Equal(t, "job-1", msg.ID)
+		assert.Equal(t, "orphan", string(msg.Data))
+		assert.GreaterOrEqual(t, msg.Attempt, 2)
+	case <-time.After(5 * time.Second):
+		t.Fatal("pending message was not reclaimed")
+	}
+}
+
+// TestNewBroker tests selecting the broker by driver.
+func TestNewBroker(t *testing.T) {
+	mr := miniredis.RunT(t)
+
+	b, err := NewBroker(BrokerConfig{Driver: "memory"})
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryBroker{}, b)
+	b.Close()
+
+	b, err = NewBroker(BrokerConfig{Driver: "redis", RedisURL: mr.Addr()})
+	require.NoError(t, err)
+	assert.IsType(t, &RedisStreamsBroker{}, b)
+	b.Close()
+
+	_, err = NewBroker(BrokerConfig{NatsURL: "nats://127.0.0.1:1"})
+	assert.ErrorContains(t, err, "NATS", "nats is the default driver")
+
+	_, err = NewBroker(BrokerConfig{Driver: "kafka"})
+	assert.EqualError(t, err, "unknown pubsub driver: kafka")
+
+	assert.Panics(t, func() {
+		MustNewBroker(BrokerConfig{Driver: "kafka"})
+	})
+}
@@ -369,15 +369,13 @@ func genHandlerImports(server spec.Server, handler spec.Handler, moduleName stri
 
 			if method.IsSSE {
 				i.AddNativeImport("time")
-				i.AddNativeImport("sync")
-				i.AddNativeImport("fmt")
-				i.AddNativeImport("encoding/json")
 
 				// i.AddProjectImport(path.Join(moduleName, types.TypesDir))
 				i.AddProjectImport(path.Join(moduleName, types.ContextDir))
 				i.AddProjectImport(path.Join(moduleName, types.SessionDir))
 
 				i.AddExternalImport("github.com/google/uuid")
+				i.AddExternalImport("github.com/templwind/soul/webserver/sse")
 
 				continue
 			}
@@ -457,6 +455,7 @@ func genHandlerImports(server spec.Server, handler spec.Handler, moduleName stri
 			i.AddNativeImport("encoding/json")
 			i.AddNativeImport("time")
 			i.AddNativeImport("context")
+			i.AddExternalImport("github.com/templwind/soul/pubsub")
 			i.AddProjectImport(path.Join(moduleName, types.ContextDir))
 			i.AddProjectImport(path.Join(moduleName, getLogicFolderPath(server, handler)), "logicHandler")
 			i.AddProjectImport(path.Join(moduleName, types.TypesDir))
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
 	"text/template"
 	"time"
@@ -69,6 +70,7 @@ type (
 		prefix           string
 		jwtTrans         string
 		maxBytes         string
+		languages        []string
 	}
 	route struct {
 		method             string
@@ -130,6 +132,11 @@ func buildRoutes(builder *SaaSBuilder) error {
 			g.middlewares = append([]string{jwtMiddleware}, g.middlewares...)
 		}
 
+		if len(g.languages) > 0 {
+			// Negotiate the language validation errors are localized to
+			g.middlewares = append([]string{languagesMiddleware(g.languages)}, g.middlewares...)
+		}
+
 		for _, r := range g.routes {
 			if len(r.doc) > 0 {
 				routesBuilder.WriteString(fmt.Sprintf("\n%s\n", util.GetDoc(r.doc)))
@@ -305,6 +312,7 @@ func buildRoutes(builder *SaaSBuilder) error {
 func genRouteImports(builder *SaaSBuilder, parentPkg string, site *spec.SiteSpec, hasStaticOverride, hasStaticEmbed bool, error404Override string, ignorePrefixes []string) string {
 	i := imports.New()
 	hasJwt := false
+	hasLanguages := false
 	for _, server := range site.Servers {
 		folder := strings.ToLower(server.GetAnnotation(types.GroupProperty))
 		if folder != "" {
@@ -317,6 +325,9 @@ func genRouteImports(builder *SaaSBuilder, parentPkg string, site *spec.SiteSpec
 		if len(jwt) > 0 {
 			hasJwt = true
 		}
+		if len(parseLanguages(server.GetAnnotation("languages"))) > 0 {
+			hasLanguages = true
+		}
 	}
 
 	folder := "notfound"
@@ -332,6 +343,9 @@ func genRouteImports(builder *SaaSBuilder, parentPkg string, site *spec.SiteSpec
 		i.AddExternalImport("github.com/labstack/echo-jwt/v4")
 
 	}
+	if hasLanguages {
+		i.AddExternalImport("github.com/templwind/soul/webserver/httpx")
+	}
 
 	return i.Build()
 }
@@ -410,6 +424,7 @@ func getRoutes(site *spec.SiteSpec) ([]group, error) {
 
 		groupedRoutes.timeout = server.GetAnnotation("timeout")
 		groupedRoutes.maxBytes = server.GetAnnotation("maxBytes")
+		groupedRoutes.languages = parseLanguages(server.GetAnnotation("languages"))
 
 		jwt := server.GetAnnotation("jwt")
 		if len(jwt) > 0 {
@@ -442,6 +457,26 @@ func getRoutes(site *spec.SiteSpec) ([]group, error) {
 	return routes, nil
 }
 
+// parseLanguages splits the comma separated languages annotation of a server block
+func parseLanguages(annotation string) []string {
+	var languages []string
+	for _, lang := range strings.Split(strings.ReplaceAll(annotation, `"`, ""), ",") {
+		if lang = strings.TrimSpace(lang); lang != "" {
+			languages = append(languages, lang)
+		}
+	}
+	return languages
+}
+
+// languagesMiddleware returns the httpx.Languages middleware for languages
+func languagesMiddleware(languages []string) string {
+	quoted := make([]string, len(languages))
+	for i, lang := range languages {
+		quoted[i] = strconv.Quote(lang)
+	}
+	return "httpx.Languages(" + strings.Join(quoted, ", ") + "),"
+}
+
 func toPrefix(folder string) string {
 	replacer := strings.NewReplacer("/", "", "-", "")
 	return replacer.Replace(folder)
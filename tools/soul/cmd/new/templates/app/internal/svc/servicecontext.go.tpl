@@ -66,6 +66,10 @@ func NewServiceContext(c *{{.config}}) *ServiceContext {
 		}
 	}
 
+	// Make the hub available to the package level sse.Send functions
+	eventHub := sse.NewEventHub()
+	sse.SetDefault(eventHub)
+
 	return &ServiceContext{
 		Config: c,
 		DB:  sqlxDB,
@@ -74,6 +78,6 @@ func NewServiceContext(c *{{.config}}) *ServiceContext {
 		RateLimiter:    rateLimiter,
 		JobManager:     jobManager,
 		PubSubBroker:   pubSubBroker,
-		EventHub:       sse.NewEventHub(),
+		EventHub:       eventHub,
 	}
 }
@@ -29,6 +29,7 @@ func main() {
 	server := webserver.MustNewServer(
 		c.WebServerConf,
 		webserver.WithMiddleware(middleware.Recover()),
+		webserver.WithErrorHandler(webserver.ErrorHandler()),
 	)
 	defer server.Stop()
 
@@ -445,15 +445,6 @@ SSE
 --------------------------------
 */}}
 {{ define "sse-request-block" }}
-		// Define SSEEvent locally
-		type SSEEvent struct {
-			ID     string      `json:"id"`
-			Status string      `json:"status"`
-			Time   time.Time   `json:"time"`
-			Path   string      `json:"path"`
-			Data   interface{} `json:"data,omitempty"`
-		}
-
 		// Extract user from the session context
 		user := session.UserFromContext(c)
 		if user == nil {
@@ -462,91 +453,12 @@ SSE
 			})
 		}
 
-		// Set SSE headers
-		c.Response().Header().Set("Content-Type", "text/event-stream")
-		c.Response().Header().Set("Cache-Control", "no-cache")
-		c.Response().Header().Set("Connection", "keep-alive")
-		c.Response().WriteHeader(http.StatusOK)
-
-		// Ensure Flusher is supported
-		flusher, ok := c.Response().Writer.(http.Flusher)
-		if !ok {
-			return c.JSON(http.StatusInternalServerError, map[string]interface{}{
-				"error": "Streaming not supported",
-			})
-		}
-
-		// Subscribe client to EventHub using both path and clientID
-		eventChan := svcCtx.EventHub.Subscribe(path, user.ID)
-		defer func() {
-			svcCtx.EventHub.Unsubscribe(path, user.ID)
-			c.Logger().Infof("Client %d unsubscribed from path %s", user.ID, path)
-		}()
-
-		// Send initial connection event
-		initialEvent := SSEEvent{
+		// Stream the events of this path to the user, replaying missed events on reconnect
+		return sse.Serve(c, svcCtx.EventHub, path, user.ID, sse.WithInitialEvent(sse.Event{
 			ID:     uuid.New().String(),
-			Status: "connected",
+			Status: sse.StatusConnected,
 			Time:   time.Now(),
-			Path:   path,
-		}
-		jsonData, _ := json.Marshal(initialEvent)
-		if _, err := c.Response().Writer.Write([]byte(fmt.Sprintf("data: %s\n\n", jsonData))); err != nil {
-			c.Logger().Errorf("Failed to send initial event for client %d on path %s: %v", user.ID, path, err)
-			return err
-		}
-		flusher.Flush()
-
-		// Mutex for safe writes
-		var writeMutex sync.Mutex
-
-		// Goroutine to handle event sending
-		ctx := c.Request().Context()
-		go func() {
-			for {
-				select {
-				case <-ctx.Done():
-					return
-				case event := <-eventChan:
-					jsonData, err := json.Marshal(event)
-					if err != nil {
-						c.Logger().Errorf("Failed to marshal event for client %d on path %s: %v", user.ID, path, err)
-						continue
-					}
-
-					writeMutex.Lock()
-					_, err = c.Response().Writer.Write([]byte(fmt.Sprintf("data: %s\n\n", jsonData)))
-					flusher.Flush()
-					writeMutex.Unlock()
-
-					if err != nil {
-						c.Logger().Errorf("Failed to send event for client %d on path %s: %v", user.ID, path, err)
-						return
-					}
-				}
-			}
-		}()
-
-		// Keep connection alive with heartbeats
-		ticker := time.NewTicker(30 * time.Second)
-		defer ticker.Stop()
-
-		for {
-			select {
-			case <-ctx.Done():
-				// Client disconnected
-				return nil
-			case <-ticker.C:
-				writeMutex.Lock()
-				if _, err := c.Response().Writer.Write([]byte(": keepalive\n\n")); err != nil {
-					writeMutex.Unlock()
-					c.Logger().Errorf("Failed to send heartbeat for client %d on path %s: %v", user.ID, path, err)
-					return err
-				}
-				flusher.Flush()
-				writeMutex.Unlock()
-			}
-		}
+		}))
 {{ end }}
 
 
@@ -569,14 +481,13 @@ func {{.HandlerName}}(svcCtx *svc.ServiceContext, topic, group string) {
 		// Note: We continue even if stream creation fails, as it might already exist
 	}
 
-	err = svcCtx.PubSubBroker.Subscribe(topic, group, func(msg []byte) ([]byte, error) {
+	_, err = svcCtx.PubSubBroker.Subscribe(context.Background(), topic, group, func(ctx context.Context, msg *pubsub.Message) error {
 		var req {{if .HasPointerRequest}}*{{end}}{{if .HasArrayRequest}}[]{{end}}types.{{.PubSubTopic.RequestType}}
-		if err := json.Unmarshal(msg, &req); err != nil {
+		if err := json.Unmarshal(msg.Data, &req); err != nil {
 			log.Printf("Failed to unmarshal message: %v", err)
-			return nil, err
+			return err
 		}
 
-		ctx := context.Background()
 		l := {{.LogicName}}.New{{.LogicType}}(ctx, svcCtx)
 		{{ if .HasResponseType }}response, {{ end }}err := l.{{.LogicFunc}}(&req)
 		if err != nil {
@@ -587,17 +498,17 @@ func {{.HandlerName}}(svcCtx *svc.ServiceContext, topic, group string) {
 		responseData, err := json.Marshal(response)
 		if err != nil {
 			log.Printf("Failed to marshal response: %v", err)
-			return nil, err
+			return err
 		}
 
-		err = svcCtx.PubSubBroker.Publish("{{.PubSubTopic.ResponseTopic}}", responseData)
+		err = svcCtx.PubSubBroker.Publish(ctx, "{{.PubSubTopic.ResponseTopic}}", responseData)
 		if err != nil {
 			log.Printf("Failed to publish response to topic %s: %v", "{{.PubSubTopic.ResponseTopic}}", err)
-			return nil, err
+			return err
 		}
 		log.Printf("Successfully published to topic %s", "{{.PubSubTopic.ResponseTopic}}")
 		{{ end }}
-		return []byte{}, nil
+		return nil
 	})
 	if err != nil {
 		log.Printf("Failed to subscribe to topic %s: %v", topic, err)
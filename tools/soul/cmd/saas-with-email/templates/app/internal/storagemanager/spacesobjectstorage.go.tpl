@@ -4,8 +4,10 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"{{ .serviceName }}/internal/config"
@@ -17,6 +19,7 @@ import (
 	"github.com/aws/aws-sdk-go/aws/credentials"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3manager"
 	"github.com/jmoiron/sqlx"
 )
 
@@ -30,9 +33,12 @@ type StorageManager struct {
 	Config       *config.Config
 	DB           *sqlx.DB
 	s3Client     *s3.S3
-	bucketName   string
 	uploadConfig UploadConfig
 	threshold    float64
+
+	// mu guards bucketName, which CreateNewBucket switches while uploads run
+	mu         sync.RWMutex
+	bucketName string
 }
 
 type UploadConfig struct {
@@ -86,40 +92,49 @@ func NewStorageManager(ctx context.Context, c *config.Config, db *sqlx.DB, thres
 	}, nil
 }
 
+// currentBucket returns the bucket new objects are stored in
+func (sm *StorageManager) currentBucket() string {
+	sm.mu.RLock()
+	defer sm.mu.RUnlock()
+	return sm.bucketName
+}
+
 // CheckBucketUsage checks the current usage of the bucket. If no primary bucket exists, it uses or creates one.
 func (sm *StorageManager) CheckBucketUsage() (float64, error) {
+	bucketName := sm.currentBucket()
+
 	// Try to get the primary bucket
-	fmt.Println("Checking bucket usage...", sm.bucketName)
+	fmt.Println("Checking bucket usage...", bucketName)
 	primaryBucket, err := models.BucketByIsPrimary(sm.ctx, sm.DB, true)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			// No primary bucket exists, check if the configured bucket exists in Spaces
-			fmt.Println("No primary bucket found, checking if configured bucket exists...", sm.bucketName)
+			fmt.Println("No primary bucket found, checking if configured bucket exists...", bucketName)
 
 			fmt.Println("Using key:", sm.uploadConfig.AccessKeyID)
 
 			// Check if the bucket exists and we own it
 			_, err := sm.s3Client.HeadBucket(&s3.HeadBucketInput{
-				Bucket:              aws.String(sm.bucketName),
+				Bucket:              aws.String(bucketName),
 				ExpectedBucketOwner: aws.String(sm.uploadConfig.AccessKeyID),
 			})
 			if err != nil {
 				// If the bucket doesn't exist or we don't own it, create it
 				if awsErr, ok := err.(awserr.Error); ok && awsErr.Code() == s3.ErrCodeNoSuchBucket || awsErr.Code() == "NotFound" {
-					fmt.Printf("Bucket %s does not exist or is not owned by us, creating it...\n", sm.bucketName)
-					_, err := sm.CreateNewBucket(sm.bucketName)
+					fmt.Printf("Bucket %s does not exist or is not owned by us, creating it...\n", bucketName)
+					_, err := sm.CreateNewBucket(bucketName)
 					if err != nil && !strings.Contains(err.Error(), "already exists") {
-						return 0, fmt.Errorf("failed to create bucket %s: %w", sm.bucketName, err)
+						return 0, fmt.Errorf("failed to create bucket %s: %w", bucketName, err)
 					}
 				} else {
 					return 0, fmt.Errorf("failed to check if bucket exists: %w", err)
 				}
 			} else {
-				fmt.Printf("Bucket %s exists and is owned by us, proceeding...\n", sm.bucketName)
+				fmt.Printf("Bucket %s exists and is owned by us, proceeding...\n", bucketName)
 			}
 
 			// Now add the bucket to the database as the primary bucket
-			err = sm.addBucketToDB(sm.bucketName, true)
+			err = sm.addBucketToDB(bucketName, true)
 			if err != nil {
 				return 0, fmt.Errorf("failed to add bucket to database: %w", err)
 			}
@@ -142,18 +157,19 @@ func (sm *StorageManager) CheckBucketUsage() (float64, error) {
 
 // generateNextBucketName generates the next bucket name based on the current bucket name
 func (sm *StorageManager) generateNextBucketName() string {
-	bucketNameParts := strings.Split(sm.bucketName, "-")
+	bucketName := sm.currentBucket()
+	bucketNameParts := strings.Split(bucketName, "-")
 	var newBucketName string
 	if len(bucketNameParts) > 1 {
 		lastPart := bucketNameParts[len(bucketNameParts)-1]
 		lastNumber, err := strconv.Atoi(lastPart)
 		if err != nil {
-			newBucketName = fmt.Sprintf("%s-1", sm.bucketName)
+			newBucketName = fmt.Sprintf("%s-1", bucketName)
 		} else {
 			newBucketName = fmt.Sprintf("%s-%d", strings.Join(bucketNameParts[:len(bucketNameParts)-1], "-"), lastNumber+1)
 		}
 	} else {
-		newBucketName = fmt.Sprintf("%s-1", sm.bucketName)
+		newBucketName = fmt.Sprintf("%s-1", bucketName)
 	}
 	return newBucketName
 }
@@ -197,7 +213,9 @@ func (sm *StorageManager) CreateNewBucket(newBucketName string) (string, error)
 		return "", fmt.Errorf("failed to update database with new bucket: %w", err)
 	}
 
+	sm.mu.Lock()
 	sm.bucketName = newBucketName
+	sm.mu.Unlock()
 	return newBucketName, nil
 }
 
@@ -343,3 +361,38 @@ func (sm *StorageManager) GetCurrentPrimaryBucket() (string, error) {
 	}
 	return primaryBucket.BucketName, nil
 }
+
+// PutObject streams r to key in the current bucket and returns the location
+// <bucket>/<key>, so the object is found after the bucket switched. With
+// DeleteObject it lets the StorageManager back an httpx.ObjectSink for
+// multipart uploads.
+func (sm *StorageManager) PutObject(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	bucketName := sm.currentBucket()
+	uploader := s3manager.NewUploaderWithClient(sm.s3Client)
+	_, err := uploader.UploadWithContext(ctx, &s3manager.UploadInput{
+		Bucket:      aws.String(bucketName),
+		Key:         aws.String(key),
+		Body:        r,
+		ContentType: aws.String(contentType),
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", key, err)
+	}
+	return bucketName + "/" + key, nil
+}
+
+// DeleteObject removes the object at a location returned by PutObject
+func (sm *StorageManager) DeleteObject(ctx context.Context, location string) error {
+	bucketName, key, ok := strings.Cut(location, "/")
+	if !ok {
+		return fmt.Errorf("invalid object location %s", location)
+	}
+	_, err := sm.s3Client.DeleteObjectWithContext(ctx, &s3.DeleteObjectInput{
+		Bucket: aws.String(bucketName),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete %s: %w", location, err)
+	}
+	return nil
+}
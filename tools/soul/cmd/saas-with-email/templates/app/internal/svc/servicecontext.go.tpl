@@ -62,6 +62,10 @@ func NewServiceContext(c *{{.config}}) *ServiceContext {
 
 	// customStatic := middleware.CustomStaticMiddleware("build", c.EmbeddedFS["build"], c.Environment == "production")
 
+	// Make the hub available to the package level sse.Send functions
+	eventHub := sse.NewEventHub()
+	sse.SetDefault(eventHub)
+
 	return &ServiceContext{
 		Config: c,
 		DB:  sqlxDB,
@@ -81,7 +85,7 @@ func NewServiceContext(c *{{.config}}) *ServiceContext {
 		{{end -}}
 		JobManager:     jobManager,
 		PubSubBroker:   pubsub.MustNewNATSBroker(c.Nats.URL, c.Redis.URL),
-		EventHub:       sse.NewEventHub(),
+		EventHub:       eventHub,
 	}
 }
 
@@ -120,6 +120,7 @@ func genSvcImports(builder *SaaSBuilder, hasMiddlware bool) string {
 	// i.AddExternalImport("github.com/aws/aws-sdk-go/aws/session")
 	i.AddExternalImport("github.com/jmoiron/sqlx")
 	i.AddExternalImport("github.com/templwind/soul/db")
+	i.AddExternalImport("github.com/templwind/soul/events")
 	i.AddExternalImport("github.com/templwind/soul/pubsub")
 	i.AddExternalImport("github.com/templwind/soul/webserver/sse")
 
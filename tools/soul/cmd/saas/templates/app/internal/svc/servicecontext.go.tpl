@@ -49,22 +49,26 @@ func NewServiceContext(c *{{.config}}) *ServiceContext {
 
 	// customStatic := middleware.CustomStaticMiddleware("build", c.EmbeddedFS["build"], c.Environment == "production")
 
-	// Create a PubSub broker (use NoOpBroker if NATS is not available)
+	// Create a PubSub broker (use events.NoOpBroker if NATS is not available)
 	var pubSubBroker pubsub.Broker
 	if c.Nats.URL == "" || c.Nats.URL == "nats://nats:4222" {
 		log.Println("NATS URL not provided or using default. Using no-op broker instead.")
-		pubSubBroker = &NoOpBroker{}
+		pubSubBroker = &events.NoOpBroker{}
 	} else {
 		// Try to create a real NATS broker
 		broker, err := pubsub.NewNATSBroker(c.Nats.URL, c.Redis.URL)
 		if err != nil {
 			log.Printf("Failed to create NATS broker: %v. Using no-op broker instead.", err)
-			pubSubBroker = &NoOpBroker{}
+			pubSubBroker = &events.NoOpBroker{}
 		} else {
 			pubSubBroker = broker
 		}
 	}
 
+	// Make the hub available to the package level sse.Send functions
+	eventHub := sse.NewEventHub()
+	sse.SetDefault(eventHub)
+
 	return &ServiceContext{
 		Config: c,
 		DB:  sqlxDB,
@@ -77,7 +81,7 @@ func NewServiceContext(c *{{.config}}) *ServiceContext {
 		RateLimiter:    rateLimiter,
 		JobManager:     jobManager,
 		PubSubBroker:   pubSubBroker,
-		EventHub:       sse.NewEventHub(),
+		EventHub:       eventHub,
 	}
 }
 
@@ -96,21 +100,3 @@ func (c *ServiceContext) StartInstanceCountUpdater(appLabel string, cfg *config.
 		}
 	}()
 }
-
-// NoOpBroker is a no-op implementation of the pubsub.Broker interface for when NATS is not available
-type NoOpBroker struct{}
-
-func (b *NoOpBroker) Publish(subject string, message []byte, msgID ...string) error {
-	log.Printf("NoOpBroker: Would publish to subject %s", subject)
-	return nil
-}
-
-func (b *NoOpBroker) Subscribe(subject string, group string, handler func([]byte) ([]byte, error)) error {
-	log.Printf("NoOpBroker: Would subscribe to subject %s with group %s", subject, group)
-	return nil
-}
-
-func (b *NoOpBroker) CreateStream(streamName, subject string, dedupWindow time.Duration) error {
-	log.Printf("NoOpBroker: Would create stream %s for subject %s with dedup window %v", streamName, subject, dedupWindow)
-	return nil
-}
\ No newline at end of file
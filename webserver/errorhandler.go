@@ -0,0 +1,136 @@
+package webserver
+
+import (
+	"context"
+	"html"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/templwind/soul"
+	"github.com/templwind/soul/htmx"
+)
+
+// ErrorView renders an Error for HTML routes
+type ErrorView func(c echo.Context, e *Error) templ.Component
+
+// ErrorHandlerOpt configures the ErrorHandler
+type ErrorHandlerOpt func(*errorHandler)
+
+type errorHandler struct {
+	apiPrefixes []string
+	page        ErrorView
+	toast       ErrorView
+	toastTarget string
+}
+
+// WithAPIRoutes sets the path prefixes of API routes, /api by default
+func WithAPIRoutes(prefixes ...string) ErrorHandlerOpt {
+	return func(h *errorHandler) {
+		h.apiPrefixes = prefixes
+	}
+}
+
+// WithErrorPage sets the page rendered for errors of HTML routes
+func WithErrorPage(page ErrorView) ErrorHandlerOpt {
+	return func(h *errorHandler) {
+		h.page = page
+	}
+}
+
+// WithErrorToast sets the toast rendered for errors of htmx requests. The
+// toast is appended to the element matching target, e.g. #toasts, or swapped
+// as the toast decides if target is empty.
+func WithErrorToast(toast ErrorView, target string) ErrorHandlerOpt {
+	return func(h *errorHandler) {
+		h.toast = toast
+		h.toastTarget = target
+	}
+}
+
+// ErrorHandler returns an echo.HTTPErrorHandler rendering every error
+// returned by a handler consistently, see AsError for how errors map to
+// statuses:
+//   - API routes, and requests accepting JSON but not HTML, get
+//     application/problem+json
+//   - htmx requests get the toast with 200, as htmx does not swap error
+//     responses by default
+//   - other requests get the error page with the status of the error
+//
+// Validation errors are localized to the language of the request and server
+// errors are logged with their cause.
+func ErrorHandler(opts ...ErrorHandlerOpt) echo.HTTPErrorHandler {
+	h := &errorHandler{
+		apiPrefixes: []string{"/api"},
+		page:        defaultErrorPage,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h.handle
+}
+
+func (h *errorHandler) handle(err error, c echo.Context) {
+	if c.Response().Committed {
+		return
+	}
+
+	// Copy the error so shared errors are not modified
+	e := *AsError(err)
+	if e.Status >= http.StatusInternalServerError {
+		c.Logger().Error(err)
+	}
+	if e.Instance == "" {
+		e.Instance = c.Request().URL.Path
+	}
+	if len(e.Errors) > 0 {
+		e.Errors = e.Errors.Localize(c)
+	}
+
+	switch {
+	case c.Request().Method == http.MethodHead:
+		err = c.NoContent(e.Status)
+	case h.isAPI(c):
+		err = Problem(c, &e)
+	case htmx.IsHtmxRequest(c.Request()) && h.toast != nil:
+		if h.toastTarget != "" {
+			htmx.Retarget(c.Response(), h.toastTarget)
+			htmx.Reswap(c.Response(), "beforeend")
+		}
+		err = soul.Render(c, http.StatusOK, h.toast(c, &e))
+	default:
+		err = soul.Render(c, e.Status, h.page(c, &e))
+	}
+	if err != nil {
+		c.Logger().Error(err)
+	}
+}
+
+// isAPI reports whether the request is for an API route or prefers JSON over HTML
+func (h *errorHandler) isAPI(c echo.Context) bool {
+	path := c.Request().URL.Path
+	for _, prefix := range h.apiPrefixes {
+		if path == prefix || strings.HasPrefix(path, strings.TrimSuffix(prefix, "/")+"/") {
+			return true
+		}
+	}
+	accept := c.Request().Header.Get(echo.HeaderAccept)
+	return strings.Contains(accept, "json") && !strings.Contains(accept, "text/html")
+}
+
+// defaultErrorPage renders the title and detail of an error as plain HTML
+func defaultErrorPage(c echo.Context, e *Error) templ.Component {
+	return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		page := "<!DOCTYPE html><html><head><title>" + html.EscapeString(e.Title) + "</title></head><body><h1>" + html.EscapeString(e.Title) + "</h1>"
+		if e.Detail != "" {
+			page += "<p>" + html.EscapeString(e.Detail) + "</p>"
+		}
+		for _, fe := range e.Errors {
+			page += "<p>" + html.EscapeString(fe.Field+": "+fe.Message) + "</p>"
+		}
+		_, err := io.WriteString(w, page+"</body></html>")
+		return err
+	})
+}
@@ -0,0 +1,130 @@
+package webserver
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/templwind/soul/webserver/httpx"
+)
+
+// MIMEApplicationProblemJSON is the media type of RFC 9457 problem details
+const MIMEApplicationProblemJSON = "application/problem+json"
+
+// Error is an error with an HTTP status. It is rendered as RFC 9457 problem
+// details for API routes and as an error page or toast for HTML routes by
+// the ErrorHandler.
+type Error struct {
+	// Type is a URI identifying the problem type, about:blank if empty
+	Type string `json:"type,omitempty"`
+	// Status is the HTTP status code
+	Status int `json:"status"`
+	// Title is a short summary of the problem type, the status text by default
+	Title string `json:"title"`
+	// Detail explains this occurrence of the problem to the client
+	Detail string `json:"detail,omitempty"`
+	// Instance identifies this occurrence of the problem, the request path by default
+	Instance string `json:"instance,omitempty"`
+	// Code is a stable, machine readable code, e.g. not_found
+	Code string `json:"code,omitempty"`
+	// Errors lists the invalid fields of the request
+	Errors httpx.ValidationErrors `json:"errors,omitempty"`
+	// Err is the underlying error, it is logged but never sent to the client
+	Err error `json:"-"`
+}
+
+// NewError returns an Error with status, code and detail. An empty code is
+// derived from the status text, e.g. not_found for 404.
+func NewError(status int, code, detail string) *Error {
+	if code == "" {
+		code = strings.ToLower(strings.ReplaceAll(http.StatusText(status), " ", "_"))
+	}
+	return &Error{
+		Status: status,
+		Title:  http.StatusText(status),
+		Detail: detail,
+		Code:   code,
+	}
+}
+
+// WrapError returns an Error with status and code caused by err. The
+// message of err is not sent to the client.
+func WrapError(err error, status int, code string) *Error {
+	e := NewError(status, code, "")
+	e.Err = err
+	return e
+}
+
+// Error implements the error interface
+func (e *Error) Error() string {
+	msg := e.Title
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// AsError maps err to an Error:
+//   - an Error wrapped in err is returned as is
+//   - httpx.ValidationErrors become 422 with the invalid fields
+//   - an httpx.BindError becomes 400 with its message as detail
+//   - sql.ErrNoRows becomes 404
+//   - an echo.HTTPError keeps its status and message
+//   - anything else is a 500 without detail
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+
+	if errs, ok := httpx.AsValidationErrors(err); ok {
+		e = NewError(http.StatusUnprocessableEntity, "validation_failed", "one or more fields are invalid")
+		e.Errors = errs
+		e.Err = err
+		return e
+	}
+
+	var bindErr *httpx.BindError
+	if errors.As(err, &bindErr) {
+		e = NewError(http.StatusBadRequest, "", bindErr.Error())
+		e.Err = err
+		return e
+	}
+
+	if errors.Is(err, sql.ErrNoRows) {
+		return WrapError(err, http.StatusNotFound, "")
+	}
+
+	var he *echo.HTTPError
+	if errors.As(err, &he) {
+		e = NewError(he.Code, "", "")
+		if msg := fmt.Sprint(he.Message); he.Message != nil && msg != e.Title {
+			e.Detail = msg
+		}
+		e.Err = he.Internal
+		return e
+	}
+
+	return WrapError(err, http.StatusInternalServerError, "")
+}
+
+// Problem writes e as application/problem+json
+func Problem(c echo.Context, e *Error) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return fmt.Errorf("failed to encode problem details: %w", err)
+	}
+	return c.Blob(e.Status, MIMEApplicationProblemJSON, body)
+}
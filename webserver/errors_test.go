@@ -0,0 +1,133 @@
+package webserver
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/webserver/httpx"
+)
+
+type signupRequest struct {
+	Email string `json:"email" validate:"email"`
+}
+
+// TestAsError tests mapping errors to statuses and codes.
+func TestAsError(t *testing.T) {
+	notFound := NewError(http.StatusNotFound, "user_not_found", "no such user")
+
+	tests := []struct {
+		err    error
+		status int
+		code   string
+		detail string
+	}{
+		{fmt.Errorf("loading: %w", notFound), http.StatusNotFound, "user_not_found", "no such user"},
+		{httpx.ValidationErrors{{Field: "email", Rule: "email"}}, http.StatusUnprocessableEntity, "validation_failed", "one or more fields are invalid"},
+		{&httpx.BindError{Err: errors.New("invalid character")}, http.StatusBadRequest, "bad_request", "invalid character"},
+		{fmt.Errorf("get user: %w", sql.ErrNoRows), http.StatusNotFound, "not_found", ""},
+		{echo.ErrMethodNotAllowed, http.StatusMethodNotAllowed, "method_not_allowed", ""},
+		{echo.NewHTTPError(http.StatusForbidden, "admins only"), http.StatusForbidden, "forbidden", "admins only"},
+		{errors.New("connection refused"), http.StatusInternalServerError, "internal_server_error", ""},
+	}
+	for _, tt := range tests {
+		e := AsError(tt.err)
+		assert.Equal(t, tt.status, e.Status, tt.err.Error())
+		assert.Equal(t, tt.code, e.Code, tt.err.Error())
+		assert.Equal(t, tt.detail, e.Detail, tt.err.Error())
+	}
+}
+
+func serve(h echo.HTTPErrorHandler, req *http.Request, err error) *httptest.ResponseRecorder {
+	e := echo.New()
+	e.HTTPErrorHandler = h
+	e.Any("/*", func(c echo.Context) error {
+		return err
+	})
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+	return rec
+}
+
+// TestErrorHandlerProblem tests problem details for API routes.
+func TestErrorHandlerProblem(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodPost, "/api/signup", strings.NewReader(`{"email":"nope"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+
+	var signup signupRequest
+	err := httpx.Parse(e.NewContext(req, httptest.NewRecorder()), &signup, "/api/signup")
+	require.Error(t, err)
+
+	rec := serve(ErrorHandler(), httptest.NewRequest(http.MethodPost, "/api/signup", nil), err)
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var problem map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, "Unprocessable Entity", problem["title"])
+	assert.Equal(t, "/api/signup", problem["instance"])
+	assert.Equal(t, "validation_failed", problem["code"])
+	fields := problem["errors"].([]any)
+	require.Len(t, fields, 1)
+	assert.Equal(t, "email", fields[0].(map[string]any)["field"])
+
+	// The cause of server errors is not sent
+	rec = serve(ErrorHandler(), httptest.NewRequest(http.MethodGet, "/api/users", nil), errors.New("password=secret"))
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.NotContains(t, rec.Body.String(), "secret")
+
+	// Requests preferring JSON get problem details on any route
+	req = httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(echo.HeaderAccept, echo.MIMEApplicationJSON)
+	rec = serve(ErrorHandler(), req, sql.ErrNoRows)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, MIMEApplicationProblemJSON, rec.Header().Get(echo.HeaderContentType))
+}
+
+func text(format string) ErrorView {
+	return func(c echo.Context, e *Error) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, err := fmt.Fprintf(w, format, e.Status, e.Title)
+			return err
+		})
+	}
+}
+
+// TestErrorHandlerHTML tests error pages and htmx toasts.
+func TestErrorHandlerHTML(t *testing.T) {
+	h := ErrorHandler(
+		WithErrorPage(text("<main>%d %s</main>")),
+		WithErrorToast(text("<div class=toast>%d %s</div>"), "#toasts"),
+	)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(echo.HeaderAccept, "text/html,application/json;q=0.9")
+	rec := serve(h, req, sql.ErrNoRows)
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "<main>404 Not Found</main>", rec.Body.String())
+
+	req = httptest.NewRequest(http.MethodDelete, "/users/1", nil)
+	req.Header.Set("HX-Request", "true")
+	rec = serve(h, req, echo.ErrForbidden)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "#toasts", rec.Header().Get("HX-Retarget"))
+	assert.Equal(t, "beforeend", rec.Header().Get("HX-Reswap"))
+	assert.Equal(t, "<div class=toast>403 Forbidden</div>", rec.Body.String())
+
+	// The default page escapes the detail
+	rec = serve(ErrorHandler(), httptest.NewRequest(http.MethodGet, "/", nil), NewError(http.StatusConflict, "", "<b>taken</b>"))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), "&lt;b&gt;taken&lt;/b&gt;")
+}
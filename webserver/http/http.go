@@ -0,0 +1,78 @@
+// Package http is the former copy of the request binding of httpx.
+//
+// Deprecated: use github.com/templwind/soul/webserver/httpx, every function
+// of this package forwards to it.
+package http
+
+import (
+	"github.com/labstack/echo/v4"
+	"github.com/templwind/soul/webserver/httpx"
+)
+
+// Validator defines the interface for validating the request.
+//
+// Deprecated: use httpx.Validator.
+type Validator = httpx.Validator
+
+// Parse parses the request.
+//
+// Deprecated: use httpx.Parse.
+func Parse(c echo.Context, v any, pattern string) error {
+	return httpx.Parse(c, v, pattern)
+}
+
+// ParseHeaders parses the headers request.
+//
+// Deprecated: use httpx.ParseHeaders.
+func ParseHeaders(c echo.Context, v any) error {
+	return httpx.ParseHeaders(c, v)
+}
+
+// ParseForm parses the form request, including multipart form data.
+//
+// Deprecated: use httpx.ParseForm.
+func ParseForm(c echo.Context, v any) error {
+	return httpx.ParseForm(c, v)
+}
+
+// ParseHeader parses the request header and returns a map.
+//
+// Deprecated: use httpx.ParseHeader.
+func ParseHeader(headerValue string) map[string]string {
+	return httpx.ParseHeader(headerValue)
+}
+
+// ParseJsonBody parses the post request which contains json in body.
+//
+// Deprecated: use httpx.ParseBody.
+func ParseJsonBody(c echo.Context, v any) error {
+	return httpx.ParseJsonBody(c, v)
+}
+
+// ParsePath parses the symbols reside in url path.
+//
+// Deprecated: use httpx.ParsePath.
+func ParsePath(c echo.Context, v any, pattern string) error {
+	return httpx.ParsePath(c, v, pattern)
+}
+
+// ParseQuery parses the query parameters.
+//
+// Deprecated: use httpx.ParseQuery.
+func ParseQuery(c echo.Context, v any) error {
+	return httpx.ParseQuery(c, v)
+}
+
+// SetValidator sets the validator.
+//
+// Deprecated: use httpx.SetValidator.
+func SetValidator(val Validator) {
+	httpx.SetValidator(val)
+}
+
+// ValidateStruct validates the struct fields based on the `validate` tag.
+//
+// Deprecated: use httpx.ValidateStruct.
+func ValidateStruct(v any) error {
+	return httpx.ValidateStruct(v)
+}
@@ -0,0 +1,30 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestParseForwardsToHttpx tests that the deprecated package binds like httpx.
+func TestParseForwardsToHttpx(t *testing.T) {
+	type Login struct {
+		ID    string `path:"id"`
+		Email string `json:"email" validate:"email"`
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/users/7", strings.NewReader(`{"email":"a@example.com"}`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("7")
+
+	var l Login
+	assert.NoError(t, Parse(c, &l, "/users/:id"))
+	assert.Equal(t, Login{ID: "7", Email: "a@example.com"}, l)
+	assert.EqualError(t, ValidateStruct(&Login{Email: "nope"}), "field Email: nope does not validate as email")
+}
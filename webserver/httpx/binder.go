@@ -0,0 +1,537 @@
+package httpx
+
+import (
+	"database/sql"
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sources are the struct tags request values are bound from
+var sources = []string{pathKey, "query", formKey, "header"}
+
+var (
+	timeType            = reflect.TypeOf(time.Time{})
+	nullTimeType        = reflect.TypeOf(sql.NullTime{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+	scannerType         = reflect.TypeOf((*sql.Scanner)(nil)).Elem()
+)
+
+// maxSliceIndex bounds the indices of bracket notation so a request cannot allocate huge slices
+const maxSliceIndex = 1000
+
+// timeLayouts are the layouts tried in order when parsing times
+var timeLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04", "2006-01-02"}
+
+// structPlan is the cached binding plan of a struct type
+type structPlan struct {
+	fields []*fieldPlan
+	// streams marks plans with UploadedFile fields, their multipart bodies are streamed to the upload sink
+	streams bool
+}
+
+// fieldPlan describes how a single struct field is bound
+type fieldPlan struct {
+	// name is the Go name of the field
+	name string
+	// index is the index sequence of the field, it passes through embedded structs
+	index []int
+	typ   reflect.Type
+	// keys holds the request-facing name of the field per source
+	keys map[string]string
+	// optional holds the sources the field is optional in
+	optional map[string]bool
+	// fallback is the name used inside bracket notation when the field has no tag for the source
+	fallback string
+	// file is the name of the file the field describes
+	file string
+	// upload holds the limits of the file tag
+	upload *fileRule
+}
+
+var plans sync.Map // reflect.Type -> *structPlan
+
+// planFor returns the binding plan of the struct type t, building it once
+func planFor(t reflect.Type) *structPlan {
+	if plan, ok := plans.Load(t); ok {
+		return plan.(*structPlan)
+	}
+	plan, _ := plans.LoadOrStore(t, buildPlan(t, nil))
+	return plan.(*structPlan)
+}
+
+// buildPlan builds the plan of t. Fields of embedded structs without tags
+// are promoted into the plan.
+func buildPlan(t reflect.Type, index []int) *structPlan {
+	plan := &structPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		fieldIndex := append(append([]int(nil), index...), i)
+
+		keys := make(map[string]string)
+		optional := make(map[string]bool)
+		for _, source := range sources {
+			tag, ok := sf.Tag.Lookup(source)
+			if !ok || tag == "" || tag == "-" {
+				continue
+			}
+			name, opts, _ := strings.Cut(tag, ",")
+			keys[source] = name
+			optional[source] = strings.Contains(opts, "optional")
+		}
+		file, upload := parseFileTag(sf.Tag.Get("file"))
+
+		if sf.Anonymous && len(keys) == 0 && file == "" {
+			if ft := indirectType(sf.Type); ft.Kind() == reflect.Struct && !isScalar(ft) {
+				embedded := buildPlan(ft, fieldIndex)
+				plan.fields = append(plan.fields, embedded.fields...)
+				plan.streams = plan.streams || embedded.streams
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+
+		fallback := sf.Name
+		if name, _, _ := strings.Cut(sf.Tag.Get("json"), ","); name != "" && name != "-" {
+			fallback = name
+		}
+
+		plan.fields = append(plan.fields, &fieldPlan{
+			name:     sf.Name,
+			index:    fieldIndex,
+			typ:      sf.Type,
+			keys:     keys,
+			optional: optional,
+			fallback: fallback,
+			file:     file,
+			upload:   upload,
+		})
+		if file != "" && isUploadedFile(sf.Type) {
+			plan.streams = true
+		}
+	}
+	return plan
+}
+
+// bindValues binds the values of source into the struct v. Inside bracket
+// notation prefix is the key of the enclosing field, e.g. "address" for
+// "address[city]".
+func bindValues(v reflect.Value, plan *structPlan, source string, values map[string][]string, prefix string) error {
+	for _, fp := range plan.fields {
+		key, ok := fp.keys[source]
+		if prefix != "" {
+			if !ok {
+				key = fp.fallback
+			}
+			key = prefix + "[" + key + "]"
+		} else if !ok {
+			continue
+		}
+
+		if err := bindField(v, fp, source, values, key); err != nil {
+			return fmt.Errorf("error setting %s parameter %s: %w", source, key, err)
+		}
+	}
+	return nil
+}
+
+// bindField binds the values stored under key into the field of v described by fp
+func bindField(v reflect.Value, fp *fieldPlan, source string, values map[string][]string, key string) error {
+	base := indirectType(fp.typ)
+
+	switch {
+	case base.Kind() == reflect.Struct && !isScalar(base):
+		// Nested struct in bracket notation, e.g. address[city]
+		if !hasNested(values, key) {
+			return nil
+		}
+		field, ok := fieldByIndex(v, fp.index)
+		if !ok {
+			return nil
+		}
+		return bindValues(indirect(field), planFor(base), source, values, key)
+
+	case base.Kind() == reflect.Slice && isNestedStruct(indirectType(base.Elem())):
+		// Slice of structs in bracket notation, e.g. items[0][name]
+		indices := nestedIndices(values, key)
+		if len(indices) == 0 {
+			return nil
+		}
+		if indices[len(indices)-1] > maxSliceIndex {
+			return fmt.Errorf("index exceeds %d", maxSliceIndex)
+		}
+		field, ok := fieldByIndex(v, fp.index)
+		if !ok {
+			return nil
+		}
+		field = indirect(field)
+		slice := reflect.MakeSlice(base, indices[len(indices)-1]+1, indices[len(indices)-1]+1)
+		elemType := indirectType(base.Elem())
+		for _, i := range indices {
+			if err := bindValues(indirect(slice.Index(i)), planFor(elemType), source, values, key+"["+strconv.Itoa(i)+"]"); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+
+	case base.Kind() == reflect.Map && base.Key().Kind() == reflect.String && len(values[key]) == 0:
+		// Map in bracket notation, e.g. meta[color]=red
+		entries := nestedEntries(values, key)
+		if len(entries) == 0 {
+			return nil
+		}
+		field, ok := fieldByIndex(v, fp.index)
+		if !ok {
+			return nil
+		}
+		field = indirect(field)
+		m := reflect.MakeMapWithSize(base, len(entries))
+		for k, raw := range entries {
+			elem := reflect.New(base.Elem()).Elem()
+			if err := setValues(elem, raw); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(base.Key()), elem)
+		}
+		field.Set(m)
+		return nil
+	}
+
+	raw := lookupValues(values, key)
+	if len(raw) == 0 {
+		return nil
+	}
+	field, ok := fieldByIndex(v, fp.index)
+	if !ok {
+		return nil
+	}
+	return setValues(field, raw)
+}
+
+// checkPresent returns an error for the first field of v that is required in
+// source but has no value or only empty ones. The standalone Parse functions
+// check presence, Parse leaves it to validation so all missing fields are
+// reported.
+func checkPresent(v any, source string, values map[string][]string) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	for _, fp := range planFor(val.Type()).fields {
+		key, ok := fp.keys[source]
+		if !ok || fp.optional[source] {
+			continue
+		}
+		if isEmptyRaw(lookupValues(values, key)) && !hasNested(values, key) {
+			return fmt.Errorf("missing required %s parameter: %s", source, key)
+		}
+	}
+	return nil
+}
+
+// checkFilesPresent returns an error for the first file of v that is
+// required but was not bound
+func checkFilesPresent(v any) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	for _, fp := range planFor(val.Type()).fields {
+		if fp.file == "" || fp.upload.optional {
+			continue
+		}
+		if field, ok := fieldByIndex(val, fp.index); ok && field.IsZero() {
+			return fmt.Errorf("missing required file parameter: %s", fp.file)
+		}
+	}
+	return nil
+}
+
+// lookupValues returns the values of key, including repeated keys, "key[]"
+// and indexed "key[0]" forms
+func lookupValues(values map[string][]string, key string) []string {
+	if raw := values[key]; len(raw) > 0 {
+		return raw
+	}
+	if raw := values[key+"[]"]; len(raw) > 0 {
+		return raw
+	}
+
+	var raw []string
+	for _, i := range nestedIndices(values, key) {
+		raw = append(raw, values[key+"["+strconv.Itoa(i)+"]"]...)
+	}
+	return raw
+}
+
+// hasNested reports whether values holds a key nested in key
+func hasNested(values map[string][]string, key string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, key+"[") {
+			return true
+		}
+	}
+	return false
+}
+
+// nestedIndices returns the sorted indices used in keys nested in key, e.g. 0 and 1 for key[0][a] and key[1]
+func nestedIndices(values map[string][]string, key string) []int {
+	seen := make(map[int]bool)
+	for k := range values {
+		rest, ok := strings.CutPrefix(k, key+"[")
+		if !ok {
+			continue
+		}
+		end := strings.IndexByte(rest, ']')
+		if end <= 0 {
+			continue
+		}
+		if i, err := strconv.Atoi(rest[:end]); err == nil && i >= 0 {
+			seen[i] = true
+		}
+	}
+
+	indices := make([]int, 0, len(seen))
+	for i := range seen {
+		indices = append(indices, i)
+	}
+	sort.Ints(indices)
+	return indices
+}
+
+// nestedEntries returns the values of the keys directly nested in key by their inner name
+func nestedEntries(values map[string][]string, key string) map[string][]string {
+	entries := make(map[string][]string)
+	for k, raw := range values {
+		rest, ok := strings.CutPrefix(k, key+"[")
+		if !ok || !strings.HasSuffix(rest, "]") {
+			continue
+		}
+		name := strings.TrimSuffix(rest, "]")
+		if name == "" || strings.ContainsAny(name, "[]") {
+			continue
+		}
+		entries[name] = raw
+	}
+	return entries
+}
+
+// fieldByIndex returns the field at index, allocating nil embedded pointers on the way
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, false
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, v.CanSet()
+}
+
+// indirect returns the value v points to, allocating it if v is a nil pointer
+func indirect(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			v.Set(reflect.New(v.Type().Elem()))
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// indirectType returns the type t points to
+func indirectType(t reflect.Type) reflect.Type {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	return t
+}
+
+// isScalar reports whether values of t are parsed from a single string
+func isScalar(t reflect.Type) bool {
+	if t == timeType || t == nullTimeType {
+		return true
+	}
+	return reflect.PointerTo(t).Implements(textUnmarshalerType) || reflect.PointerTo(t).Implements(scannerType)
+}
+
+// isNestedStruct reports whether t is a struct bound with bracket notation
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && !isScalar(t)
+}
+
+// setValues sets field from one or more raw values. Slices take one element
+// per value, a single value is split on commas. Empty values are absent for
+// fields without an empty representation, such as numbers and pointers.
+func setValues(field reflect.Value, raw []string) error {
+	if isEmptyRaw(raw) && !keepsEmpty(field.Type()) {
+		return nil
+	}
+
+	t := indirectType(field.Type())
+	if t.Kind() != reflect.Slice || isScalar(t) || t.Elem().Kind() == reflect.Uint8 {
+		return setFieldValue(field, raw[0])
+	}
+
+	if len(raw) == 1 {
+		raw = strings.Split(raw[0], ",")
+	}
+	field = indirect(field)
+	slice := reflect.MakeSlice(t, len(raw), len(raw))
+	for i, value := range raw {
+		if err := setFieldValue(slice.Index(i), strings.TrimSpace(value)); err != nil {
+			return err
+		}
+	}
+	field.Set(slice)
+	return nil
+}
+
+// isEmptyRaw reports whether all of raw are empty, e.g. for ?page=
+func isEmptyRaw(raw []string) bool {
+	for _, value := range raw {
+		if value != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// keepsEmpty reports whether an empty value is set on a field of type t
+// rather than leaving it unset. Only strings, byte slices and slices of
+// strings hold empty values, pointers stay nil.
+func keepsEmpty(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.String:
+		return true
+	case reflect.Slice:
+		return t.Elem().Kind() == reflect.String || t.Elem().Kind() == reflect.Uint8
+	}
+	return false
+}
+
+// setFieldValue sets a reflected field's value from a string input.
+//
+// Supported types:
+//   - String, Bool, Int*, Uint*, Float*
+//   - Pointers to any supported type, allocated when set
+//   - time.Time (RFC3339, "2006-01-02T15:04" or "2006-01-02")
+//   - sql.Null* and other sql.Scanner types, an empty value stays null
+//   - encoding.TextUnmarshaler types such as uuid.UUID
+//   - Slices of supported types (comma-separated values)
+//   - map[string]string (JSON format)
+//
+// Returns an error if type conversion fails or if the field type is unsupported.
+func setFieldValue(field reflect.Value, value string) error {
+	if field.Kind() == reflect.Ptr {
+		return setFieldValue(indirect(field), value)
+	}
+
+	switch field.Type() {
+	case timeType:
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(t))
+		return nil
+	case nullTimeType:
+		if value == "" {
+			return nil
+		}
+		t, err := parseTime(value)
+		if err != nil {
+			return err
+		}
+		field.Set(reflect.ValueOf(sql.NullTime{Time: t, Valid: true}))
+		return nil
+	}
+
+	if field.CanAddr() {
+		switch target := field.Addr().Interface().(type) {
+		case encoding.TextUnmarshaler:
+			return target.UnmarshalText([]byte(value))
+		case sql.Scanner:
+			if value == "" {
+				return nil
+			}
+			return target.Scan(value)
+		}
+	}
+
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(value)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		intValue, err := strconv.ParseInt(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetInt(intValue)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		uintValue, err := strconv.ParseUint(value, 10, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetUint(uintValue)
+	case reflect.Float32, reflect.Float64:
+		floatValue, err := strconv.ParseFloat(value, field.Type().Bits())
+		if err != nil {
+			return err
+		}
+		field.SetFloat(floatValue)
+	case reflect.Bool:
+		boolValue, err := strconv.ParseBool(value)
+		if err != nil {
+			return err
+		}
+		field.SetBool(boolValue)
+	case reflect.Slice:
+		if field.Type().Elem().Kind() == reflect.Uint8 {
+			field.SetBytes([]byte(value))
+			return nil
+		}
+		return setValues(field, []string{value})
+	case reflect.Map:
+		// Handle map types from JSON, e.g. {"key": "value"}
+		m := reflect.New(field.Type())
+		if err := json.Unmarshal([]byte(value), m.Interface()); err != nil {
+			return err
+		}
+		field.Set(m.Elem())
+	case reflect.Interface:
+		if field.NumMethod() != 0 {
+			return fmt.Errorf("unsupported field type: %s", field.Type().String())
+		}
+		field.Set(reflect.ValueOf(value))
+	default:
+		return fmt.Errorf("unsupported field type: %s", field.Type().String())
+	}
+	return nil
+}
+
+// parseTime parses value with the first matching layout of timeLayouts
+func parseTime(value string) (time.Time, error) {
+	var err error
+	for _, layout := range timeLayouts {
+		var t time.Time
+		if t, err = time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
@@ -0,0 +1,144 @@
+package httpx
+
+import (
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type Address struct {
+	City string `form:"city"`
+	Zip  *int   `form:"zip"`
+}
+
+type LineItem struct {
+	SKU string `json:"sku"`
+	Qty int    `json:"qty"`
+}
+
+type Paging struct {
+	Page int `query:"page,optional"`
+}
+
+type SearchRequest struct {
+	Paging
+	IDs       []int64           `query:"id,optional"`
+	Tags      []string          `query:"tags,optional"`
+	Limit     *int              `query:"limit,optional"`
+	Owner     uuid.UUID         `query:"owner,optional"`
+	Since     time.Time         `query:"since,optional"`
+	Deleted   sql.NullBool      `query:"deleted,optional"`
+	Score     sql.NullFloat64   `query:"score,optional"`
+	Filter    map[string]string `query:"filter,optional"`
+	RequestID string            `header:"X-Request-Id,optional"`
+}
+
+type OrderForm struct {
+	Name    string     `form:"name"`
+	Address Address    `form:"address"`
+	Billing *Address   `form:"billing,optional"`
+	Items   []LineItem `form:"items"`
+}
+
+// TestParseQueryTypes tests typed slices, pointers, text unmarshalers, sql.Null types and embedded structs.
+func TestParseQueryTypes(t *testing.T) {
+	owner := uuid.New()
+	query := url.Values{}
+	query.Add("id", "1")
+	query.Add("id", "2")
+	query.Set("tags[]", "a")
+	query.Set("limit", "10")
+	query.Set("owner", owner.String())
+	query.Set("since", "2024-02-01")
+	query.Set("deleted", "true")
+	query.Set("page", "3")
+	query.Set("filter[status]", "open")
+	query.Set("filter[kind]", "bug")
+
+	req := httptest.NewRequest(http.MethodGet, "/search?"+query.Encode(), nil)
+	req.Header.Set("X-Request-Id", "abc")
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	var sr SearchRequest
+	require.NoError(t, Parse(c, &sr, "/search"))
+
+	assert.Equal(t, []int64{1, 2}, sr.IDs)
+	assert.Equal(t, []string{"a"}, sr.Tags)
+	require.NotNil(t, sr.Limit)
+	assert.Equal(t, 10, *sr.Limit)
+	assert.Equal(t, owner, sr.Owner)
+	assert.Equal(t, time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC), sr.Since)
+	assert.Equal(t, sql.NullBool{Bool: true, Valid: true}, sr.Deleted)
+	assert.False(t, sr.Score.Valid)
+	assert.Equal(t, 3, sr.Page)
+	assert.Equal(t, map[string]string{"status": "open", "kind": "bug"}, sr.Filter)
+	assert.Equal(t, "abc", sr.RequestID)
+}
+
+// TestParseQueryCommaSeparated tests that a single value is split into slice elements.
+func TestParseQueryCommaSeparated(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?id=4,5,6", nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	var sr SearchRequest
+	require.NoError(t, ParseQuery(c, &sr))
+	assert.Equal(t, []int64{4, 5, 6}, sr.IDs)
+	assert.Nil(t, sr.Limit)
+}
+
+// TestParseQueryInvalidValue tests that conversion errors name the parameter.
+func TestParseQueryInvalidValue(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/search?id=1&id=x", nil)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	var sr SearchRequest
+	err := ParseQuery(c, &sr)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "error setting query parameter id")
+}
+
+// TestParseFormBracketNotation tests nested structs and slices of structs in form data.
+func TestParseFormBracketNotation(t *testing.T) {
+	form := url.Values{}
+	form.Set("name", "Ada")
+	form.Set("address[city]", "London")
+	form.Set("address[zip]", "12345")
+	form.Set("items[0][sku]", "A-1")
+	form.Set("items[0][qty]", "2")
+	form.Set("items[1][sku]", "B-2")
+	form.Set("items[1][qty]", "1")
+
+	req := httptest.NewRequest(http.MethodPost, "/orders", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	var of OrderForm
+	require.NoError(t, Parse(c, &of, "/orders"))
+
+	assert.Equal(t, "Ada", of.Name)
+	assert.Equal(t, "London", of.Address.City)
+	require.NotNil(t, of.Address.Zip)
+	assert.Equal(t, 12345, *of.Address.Zip)
+	assert.Nil(t, of.Billing)
+	assert.Equal(t, []LineItem{{SKU: "A-1", Qty: 2}, {SKU: "B-2", Qty: 1}}, of.Items)
+}
+
+// TestParseSliceBody tests that slice targets are decoded from the body only.
+func TestParseSliceBody(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/items?sku=x", strings.NewReader(`[{"sku":"A-1","qty":2}]`))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+
+	var items []LineItem
+	require.NoError(t, Parse(c, &items, "/items"))
+	assert.Equal(t, []LineItem{{SKU: "A-1", Qty: 2}}, items)
+}
@@ -0,0 +1,277 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+const (
+	// MIMEApplicationMsgpack is the media type of MessagePack bodies
+	MIMEApplicationMsgpack = "application/msgpack"
+	// MIMEApplicationCBOR is the media type of CBOR bodies
+	MIMEApplicationCBOR = "application/cbor"
+)
+
+// Decoder decodes a request body into v
+type Decoder func(r io.Reader, v any) error
+
+// Encoder encodes v as a response body
+type Encoder func(w io.Writer, v any) error
+
+var (
+	codecsMu sync.RWMutex
+	decoders = map[string]Decoder{}
+	encoders = map[string]Encoder{}
+	// offers are the media types with an encoder in order of preference
+	offers []string
+)
+
+func init() {
+	RegisterDecoder(echo.MIMEApplicationJSON, decodeJSON)
+	RegisterEncoder(echo.MIMEApplicationJSON, encodeJSON)
+	for _, mediaType := range []string{echo.MIMEApplicationXML, echo.MIMETextXML} {
+		RegisterDecoder(mediaType, decodeXML)
+		RegisterEncoder(mediaType, encodeXML)
+	}
+	for _, mediaType := range []string{MIMEApplicationMsgpack, "application/x-msgpack", "application/vnd.msgpack"} {
+		RegisterDecoder(mediaType, decodeMsgpack)
+		RegisterEncoder(mediaType, encodeMsgpack)
+	}
+	RegisterDecoder(MIMEApplicationCBOR, decodeCBOR)
+	RegisterEncoder(MIMEApplicationCBOR, encodeCBOR)
+}
+
+// RegisterDecoder sets the decoder of request bodies of mediaType, replacing
+// any decoder registered before. Structured syntax suffixes fall back to the
+// decoder of their base type, application/problem+json uses the JSON decoder.
+func RegisterDecoder(mediaType string, dec Decoder) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	decoders[strings.ToLower(mediaType)] = dec
+}
+
+// RegisterEncoder sets the encoder of responses of mediaType, replacing any
+// encoder registered before. Media types registered first are preferred when
+// the Accept header of a request allows several.
+func RegisterEncoder(mediaType string, enc Encoder) {
+	codecsMu.Lock()
+	defer codecsMu.Unlock()
+	mediaType = strings.ToLower(mediaType)
+	if _, ok := encoders[mediaType]; !ok {
+		offers = append(offers, mediaType)
+	}
+	encoders[mediaType] = enc
+}
+
+// decoderFor returns the decoder of the media type of a Content-Type header
+func decoderFor(contentType string) (Decoder, bool) {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, false
+	}
+
+	codecsMu.RLock()
+	defer codecsMu.RUnlock()
+	if dec, ok := decoders[mediaType]; ok {
+		return dec, true
+	}
+	if i := strings.LastIndex(mediaType, "+"); i >= 0 {
+		dec, ok := decoders["application/"+mediaType[i+1:]]
+		return dec, ok
+	}
+	return nil, false
+}
+
+// withBody reports whether the request has a body with a registered decoder
+func withBody(r *http.Request) bool {
+	if r.ContentLength == 0 || r.Body == nil || r.Body == http.NoBody {
+		return false
+	}
+	_, ok := decoderFor(r.Header.Get(echo.HeaderContentType))
+	return ok
+}
+
+// bodyFormat names the format of a request body in errors, e.g. json for application/problem+json
+func bodyFormat(r *http.Request) string {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get(echo.HeaderContentType))
+	mediaType = mediaType[strings.LastIndexAny(mediaType, "/+")+1:]
+	return strings.TrimPrefix(mediaType, "x-")
+}
+
+// ParseBody decodes the request body into v with the decoder registered for
+// its Content-Type. JSON, XML, MessagePack and CBOR are supported out of the
+// box, MessagePack and CBOR use the json tags of v. Bodies without a decoder,
+// such as forms, are left to the other parse functions.
+//
+// The body size is limited to maxBodyLen (8MB) to prevent memory exhaustion,
+// and the body is restored so later handlers can read it again.
+func ParseBody(c echo.Context, v any) error {
+	r := c.Request()
+	if !withBody(r) {
+		return nil
+	}
+	dec, _ := decoderFor(r.Header.Get(echo.HeaderContentType))
+
+	// Use TeeReader to read the body while simultaneously copying it to the buffer
+	var bodyBuffer bytes.Buffer
+	teeReader := io.TeeReader(io.LimitReader(r.Body, maxBodyLen), &bodyBuffer)
+	err := dec(teeReader, v)
+
+	// Restore the body for later use
+	r.Body = io.NopCloser(&bodyBuffer)
+
+	if errors.Is(err, io.EOF) && bodyBuffer.Len() == 0 {
+		// A chunked request without content
+		return nil
+	}
+	return err
+}
+
+// Respond writes v with status in the media type preferred by the Accept
+// header of the request among the registered encoders, JSON if the request
+// accepts anything. Requests accepting none of them get 406 Not Acceptable.
+func Respond(c echo.Context, status int, v any) error {
+	codecsMu.RLock()
+	mediaType := negotiateMediaType(c.Request().Header.Get(echo.HeaderAccept), offers)
+	enc := encoders[mediaType]
+	codecsMu.RUnlock()
+
+	c.Response().Header().Add(echo.HeaderVary, echo.HeaderAccept)
+	if enc == nil {
+		return echo.NewHTTPError(http.StatusNotAcceptable)
+	}
+
+	var buf bytes.Buffer
+	if err := enc(&buf, v); err != nil {
+		return fmt.Errorf("failed to encode response as %s: %w", mediaType, err)
+	}
+	return c.Blob(status, contentTypeOf(mediaType), buf.Bytes())
+}
+
+// contentTypeOf adds the charset to textual media types
+func contentTypeOf(mediaType string) string {
+	switch mediaType {
+	case echo.MIMEApplicationJSON, echo.MIMEApplicationXML, echo.MIMETextXML:
+		return mediaType + "; charset=UTF-8"
+	}
+	return mediaType
+}
+
+// negotiateMediaType picks the offer preferred by an Accept header, the
+// first offer if the header is empty and "" if no offer is acceptable
+func negotiateMediaType(accept string, offers []string) string {
+	if len(offers) == 0 {
+		return ""
+	}
+	if strings.TrimSpace(accept) == "" {
+		return offers[0]
+	}
+
+	type preference struct {
+		mediaType string
+		q         float64
+		// specificity ranks type/subtype over type/* over */*
+		specificity int
+	}
+	var prefs []preference
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if v, ok := params["q"]; ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		specificity := 2
+		switch {
+		case mediaType == "*/*":
+			specificity = 0
+		case strings.HasSuffix(mediaType, "/*"):
+			specificity = 1
+		}
+		prefs = append(prefs, preference{mediaType: mediaType, q: q, specificity: specificity})
+	}
+
+	// The most specific range matching an offer decides its quality
+	best, bestQ := "", 0.0
+	for _, offer := range offers {
+		matched := -1
+		q := 0.0
+		for _, pref := range prefs {
+			if pref.specificity <= matched || !mediaTypeMatches(pref.mediaType, offer) {
+				continue
+			}
+			matched, q = pref.specificity, pref.q
+		}
+		if q > bestQ {
+			best, bestQ = offer, q
+		}
+	}
+	return best
+}
+
+// mediaTypeMatches reports whether the media range r includes mediaType
+func mediaTypeMatches(r, mediaType string) bool {
+	if r == "*/*" || r == mediaType {
+		return true
+	}
+	if prefix, ok := strings.CutSuffix(r, "*"); ok {
+		return strings.HasPrefix(mediaType, prefix)
+	}
+	return false
+}
+
+func decodeJSON(r io.Reader, v any) error {
+	return json.NewDecoder(r).Decode(v)
+}
+
+func encodeJSON(w io.Writer, v any) error {
+	return json.NewEncoder(w).Encode(v)
+}
+
+func decodeXML(r io.Reader, v any) error {
+	return xml.NewDecoder(r).Decode(v)
+}
+
+func encodeXML(w io.Writer, v any) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	return xml.NewEncoder(w).Encode(v)
+}
+
+func decodeMsgpack(r io.Reader, v any) error {
+	dec := msgpack.NewDecoder(r)
+	dec.SetCustomStructTag("json")
+	return dec.Decode(v)
+}
+
+func encodeMsgpack(w io.Writer, v any) error {
+	enc := msgpack.NewEncoder(w)
+	enc.SetCustomStructTag("json")
+	return enc.Encode(v)
+}
+
+func decodeCBOR(r io.Reader, v any) error {
+	return cbor.NewDecoder(r).Decode(v)
+}
+
+func encodeCBOR(w io.Writer, v any) error {
+	return cbor.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,163 @@
+package httpx
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/fxamacker/cbor/v2"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+type Note struct {
+	XMLName xml.Name `json:"-" xml:"note"`
+	ID      string   `path:"id" xml:"-"`
+	Title   string   `json:"title" xml:"title"`
+	Tags    []string `json:"tags,optional" xml:"tag"`
+}
+
+func newBodyContext(contentType string, body []byte) echo.Context {
+	req := httptest.NewRequest(http.MethodPut, "/notes/9", bytes.NewReader(body))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	c := echo.New().NewContext(req, httptest.NewRecorder())
+	c.SetParamNames("id")
+	c.SetParamValues("9")
+	return c
+}
+
+// TestParseBodyFormats tests decoding bodies by Content-Type.
+func TestParseBodyFormats(t *testing.T) {
+	msgpackBody, err := msgpack.Marshal(map[string]any{"title": "Hi", "tags": []string{"a", "b"}})
+	require.NoError(t, err)
+	cborBody, err := cbor.Marshal(map[string]any{"title": "Hi", "tags": []string{"a", "b"}})
+	require.NoError(t, err)
+
+	tests := []struct {
+		contentType string
+		body        []byte
+	}{
+		{echo.MIMEApplicationJSONCharsetUTF8, []byte(`{"title":"Hi","tags":["a","b"]}`)},
+		{"application/merge-patch+json", []byte(`{"title":"Hi","tags":["a","b"]}`)},
+		{echo.MIMEApplicationXML, []byte(`<note><title>Hi</title><tag>a</tag><tag>b</tag></note>`)},
+		{echo.MIMETextXMLCharsetUTF8, []byte(`<note><title>Hi</title><tag>a</tag><tag>b</tag></note>`)},
+		{MIMEApplicationMsgpack, msgpackBody},
+		{"application/x-msgpack", msgpackBody},
+		{MIMEApplicationCBOR, cborBody},
+	}
+	for _, tt := range tests {
+		c := newBodyContext(tt.contentType, tt.body)
+
+		var n Note
+		require.NoError(t, Parse(c, &n, "/notes/:id"), tt.contentType)
+		assert.Equal(t, "9", n.ID, tt.contentType)
+		assert.Equal(t, "Hi", n.Title, tt.contentType)
+		assert.Equal(t, []string{"a", "b"}, n.Tags, tt.contentType)
+
+		// The body is restored for later handlers
+		body, _ := io.ReadAll(c.Request().Body)
+		assert.Equal(t, tt.body, body, tt.contentType)
+	}
+}
+
+// TestParseBodyUnknownType tests that bodies without a decoder are ignored until one is registered.
+func TestParseBodyUnknownType(t *testing.T) {
+	var n Note
+	require.NoError(t, ParseBody(newBodyContext("text/csv", []byte("title\nHi")), &n))
+	assert.Empty(t, n.Title)
+
+	RegisterDecoder("text/csv", func(r io.Reader, v any) error {
+		b, err := io.ReadAll(r)
+		if err != nil {
+			return err
+		}
+		v.(*Note).Title = strings.Split(string(b), "\n")[1]
+		return nil
+	})
+	defer func() {
+		codecsMu.Lock()
+		delete(decoders, "text/csv")
+		codecsMu.Unlock()
+	}()
+
+	require.NoError(t, ParseBody(newBodyContext("text/csv", []byte("title\nHi")), &n))
+	assert.Equal(t, "Hi", n.Title)
+}
+
+// TestParseMixedFormAndBody tests that the error names the body format.
+func TestParseMixedFormAndBody(t *testing.T) {
+	c := newBodyContext(echo.MIMEApplicationXML, []byte(`<note/>`))
+	c.Request().PostForm = map[string][]string{"title": {"Hi"}}
+
+	var n Note
+	assert.EqualError(t, Parse(c, &n, "/notes/:id"), "cannot mix form and xml data")
+}
+
+// TestRespond tests encoding responses by Accept.
+func TestRespond(t *testing.T) {
+	n := Note{Title: "Hi", Tags: []string{"a"}}
+
+	respond := func(accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/notes/9", nil)
+		if accept != "" {
+			req.Header.Set(echo.HeaderAccept, accept)
+		}
+		rec := httptest.NewRecorder()
+		c := echo.New().NewContext(req, rec)
+		if err := Respond(c, http.StatusOK, n); err != nil {
+			c.Echo().HTTPErrorHandler(err, c)
+		}
+		return rec
+	}
+
+	rec := respond("")
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, echo.HeaderAccept, rec.Header().Get(echo.HeaderVary))
+	var decoded Note
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &decoded))
+	assert.Equal(t, "Hi", decoded.Title)
+
+	rec = respond("text/html, application/xml;q=0.9, */*;q=0.8")
+	assert.Equal(t, echo.MIMEApplicationXMLCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Contains(t, rec.Body.String(), "<note><title>Hi</title><tag>a</tag></note>")
+
+	rec = respond("application/msgpack")
+	assert.Equal(t, MIMEApplicationMsgpack, rec.Header().Get(echo.HeaderContentType))
+	decoded = Note{}
+	require.NoError(t, decodeMsgpack(rec.Body, &decoded))
+	assert.Equal(t, []string{"a"}, decoded.Tags)
+
+	rec = respond("application/cbor")
+	assert.Equal(t, MIMEApplicationCBOR, rec.Header().Get(echo.HeaderContentType))
+
+	rec = respond("text/html")
+	assert.Equal(t, http.StatusNotAcceptable, rec.Code)
+}
+
+// TestNegotiateMediaType tests Accept negotiation with quality values and wildcards.
+func TestNegotiateMediaType(t *testing.T) {
+	offers := []string{"application/json", "application/xml", "application/cbor"}
+
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", "application/json"},
+		{"*/*", "application/json"},
+		{"application/*", "application/json"},
+		{"application/xml", "application/xml"},
+		{"application/json;q=0.5, application/cbor", "application/cbor"},
+		{"application/*;q=0.5, application/json;q=0", "application/xml"},
+		{"text/plain", ""},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiateMediaType(tt.accept, offers), tt.accept)
+	}
+}
@@ -0,0 +1,148 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+// messageKeyPrefix prefixes the rule of a FieldError to form its message key
+const messageKeyPrefix = "validation."
+
+// FieldError describes a field that failed a validation rule
+type FieldError struct {
+	// Field is the name of the field in the request, e.g. email or address[city]
+	Field string `json:"field"`
+	// Path is the Go path of the field, e.g. Address.City
+	Path string `json:"-"`
+	// Rule is the failing rule of the validate tag, e.g. min
+	Rule string `json:"rule"`
+	// Param is the parameter of the rule, e.g. 8 for min=8
+	Param string `json:"param,omitempty"`
+	// Key is the message key used to translate the error, e.g. validation.min
+	Key string `json:"key"`
+	// Message is the human readable message, in English unless translated
+	Message string `json:"message"`
+	// Value is the rejected value, it is not serialized so secrets don't leak
+	Value any `json:"-"`
+}
+
+// Error implements the error interface
+func (fe FieldError) Error() string {
+	return fmt.Sprintf("field %s: %s", fe.Path, fe.Message)
+}
+
+// ValidationErrors lists every field of a request that failed validation
+type ValidationErrors []FieldError
+
+// Error implements the error interface
+func (ve ValidationErrors) Error() string {
+	msgs := make([]string, len(ve))
+	for i, fe := range ve {
+		msgs[i] = fe.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Has reports whether the request field failed validation
+func (ve ValidationErrors) Has(field string) bool {
+	for _, fe := range ve {
+		if fe.Field == field {
+			return true
+		}
+	}
+	return false
+}
+
+// Get returns the message of the request field, empty if the field is valid
+func (ve ValidationErrors) Get(field string) string {
+	for _, fe := range ve {
+		if fe.Field == field {
+			return fe.Message
+		}
+	}
+	return ""
+}
+
+// Fields maps the request fields to their messages
+func (ve ValidationErrors) Fields() map[string]string {
+	fields := make(map[string]string, len(ve))
+	for _, fe := range ve {
+		if _, ok := fields[fe.Field]; !ok {
+			fields[fe.Field] = fe.Message
+		}
+	}
+	return fields
+}
+
+// Translate returns a copy of the errors with their messages translated to
+// lang by the configured Translator. Messages without a translation are kept.
+func (ve ValidationErrors) Translate(lang string) ValidationErrors {
+	t := loadTranslator()
+	if t == nil || lang == "" {
+		return ve
+	}
+
+	translated := make(ValidationErrors, len(ve))
+	for i, fe := range ve {
+		if msg, ok := t.Translate(lang, fe); ok {
+			fe.Message = msg
+		}
+		translated[i] = fe
+	}
+	return translated
+}
+
+// Localize translates the errors to the language negotiated for the request
+func (ve ValidationErrors) Localize(c echo.Context) ValidationErrors {
+	return ve.Translate(Language(c))
+}
+
+// AsValidationErrors returns the ValidationErrors wrapped in err
+func AsValidationErrors(err error) (ValidationErrors, bool) {
+	var ve ValidationErrors
+	if errors.As(err, &ve) {
+		return ve, true
+	}
+	return nil, false
+}
+
+// BindError is returned by Parse when the request cannot be bound to the
+// target, e.g. a malformed body or a query value of the wrong type
+type BindError struct {
+	Err error
+}
+
+// Error implements the error interface
+func (e *BindError) Error() string {
+	return e.Err.Error()
+}
+
+// Unwrap returns the underlying error
+func (e *BindError) Unwrap() error {
+	return e.Err
+}
+
+// storeError marks failures of the upload sink, they are not caused by the request
+type storeError struct {
+	err error
+}
+
+func (e *storeError) Error() string {
+	return e.err.Error()
+}
+
+func (e *storeError) Unwrap() error {
+	return e.err
+}
+
+// bindError wraps err in a BindError unless it is a validation or upload sink failure
+func bindError(err error) error {
+	var se *storeError
+	if _, ok := AsValidationErrors(err); ok || errors.As(err, &se) {
+		return err
+	}
+	return &BindError{Err: err}
+}
@@ -0,0 +1,35 @@
+package httpx
+
+import (
+	"net/http"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/templwind/soul"
+	"github.com/templwind/soul/htmx"
+)
+
+// RenderForm re-renders a form with the validation errors of err inline. The
+// errors are localized to the language of the request before form is called.
+// Errors other than ValidationErrors are returned unchanged:
+//
+//	if err := httpx.Parse(c, &req, path); err != nil {
+//	    return httpx.RenderForm(c, err, func(errs httpx.ValidationErrors) templ.Component {
+//	        return signup.Form(req, errs)
+//	    })
+//	}
+//
+// Responses to htmx requests use 200 because htmx does not swap error
+// responses by default, other requests get 422 Unprocessable Entity.
+func RenderForm(c echo.Context, err error, form func(errs ValidationErrors) templ.Component) error {
+	errs, ok := AsValidationErrors(err)
+	if !ok {
+		return err
+	}
+
+	status := http.StatusUnprocessableEntity
+	if htmx.IsHtmxRequest(c.Request()) {
+		status = http.StatusOK
+	}
+	return soul.Render(c, status, form(errs.Localize(c)))
+}
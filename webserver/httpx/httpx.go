@@ -1,17 +1,12 @@
 package httpx
 
 import (
-	"bytes"
-	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"reflect"
-	"strconv"
 	"strings"
 	"sync/atomic"
-	"time"
 
 	"github.com/labstack/echo/v4"
 )
@@ -34,8 +29,8 @@ type Validator interface {
 var validator atomic.Value
 
 // Parse handles the complete parsing of an HTTP request, processing path parameters,
-// query parameters, form data, headers, and JSON body. It also validates the parsed data
-// if a validator is configured.
+// query parameters, form data, headers, and the body in any format with a registered
+// decoder. It also validates the parsed data if a validator is configured.
 //
 // Parameters:
 //   - c: The Echo context containing the HTTP request
@@ -49,37 +44,43 @@ var validator atomic.Value
 //   - `file:"name"` or `file:"name,optional"` for file metadata
 //   - `header:"name"` or `header:"name,optional"` for headers
 //
-// Returns an error if parsing fails or validation fails.
+// Returns an error if parsing fails or validation fails. Requests that cannot
+// be bound return a *BindError, failed validation returns ValidationErrors,
+// localized when the Languages middleware is in use.
 func Parse(c echo.Context, v any, pattern string) error {
-	// Check if both JSON and form data are present
-	isJSON := withJsonBody(c.Request())
+	// Check if both a body and form data are present
+	hasBody := withBody(c.Request())
 	isForm := c.Request().PostForm != nil && len(c.Request().PostForm) > 0
 
-	if isJSON && isForm {
-		return errors.New("cannot mix form and json data")
+	if hasBody && isForm {
+		return bindError(fmt.Errorf("cannot mix form and %s data", bodyFormat(c.Request())))
 	}
 
-	if err := ParsePath(c, v, pattern); err != nil {
-		return err
+	// Missing parameters are left to validation, which reports all of them
+	if err := parsePath(c, v, pattern); err != nil {
+		return bindError(err)
 	}
 
-	if err := ParseQuery(c, v); err != nil {
-		return err
+	if err := parseQuery(c, v); err != nil {
+		return bindError(err)
 	}
 
-	if err := ParseForm(c, v); err != nil {
-		return err
+	if err := parseForm(c, v); err != nil {
+		return bindError(err)
 	}
 
-	if err := ParseHeaders(c, v); err != nil {
-		return err
+	if err := parseHeaders(c, v); err != nil {
+		return bindError(err)
 	}
 
-	if err := ParseJsonBody(c, v); err != nil {
-		return err
+	if err := ParseBody(c, v); err != nil {
+		return bindError(err)
 	}
 
 	if err := ValidateStruct(v); err != nil {
+		if errs, ok := err.(ValidationErrors); ok {
+			return errs.Localize(c)
+		}
 		return err
 	}
 
@@ -108,55 +109,75 @@ func Parse(c echo.Context, v any, pattern string) error {
 //
 // Returns an error if a required header is missing or if type conversion fails.
 func ParseHeaders(c echo.Context, v any) error {
-	headers := c.Request().Header
-	val := reflect.ValueOf(v).Elem()
-	typ := val.Type()
-
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-		headerTag := fieldType.Tag.Get("header")
-
-		if headerTag != "" {
-			tagParts := strings.Split(headerTag, ",")
-			headerName := tagParts[0]
-			isOptional := len(tagParts) > 1 && tagParts[1] == "optional"
-
-			headerValue := headers.Get(headerName)
-			if headerValue != "" {
-				if err := setFieldValue(field, headerValue); err != nil {
-					return fmt.Errorf("error setting header parameter %s: %w", headerName, err)
-				}
-			} else if !isOptional {
-				return fmt.Errorf("missing required header parameter: %s", headerName)
-			}
-		}
+	if err := parseHeaders(c, v); err != nil {
+		return err
 	}
+	return checkPresent(v, "header", c.Request().Header)
+}
 
-	return nil
+func parseHeaders(c echo.Context, v any) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	return bindValues(val, planFor(val.Type()), "header", c.Request().Header, "")
 }
 
 // ParseForm handles both regular form data and multipart form data, including file uploads.
-// Form values are mapped using the "form" struct tag, and file metadata using the "file" tag.
+// Form values are mapped using the "form" struct tag, and files using the "file" tag.
 //
 // Parameters:
 //   - c: The Echo context containing the HTTP request
 //   - v: A pointer to the struct where form values will be stored
 //
-// Supports both single values and slices. Fields can be marked as optional:
+// Supports single values, slices from repeated keys, and nested structs, maps
+// and slices of structs in bracket notation such as address[city] or
+// items[0][name]. Fields can be marked as optional:
 //
 //	type FormData struct {
-//	    Name   string   `form:"name"`            // required field
-//	    Tags   []string `form:"tags,optional"`   // optional slice
-//	    File   string   `file:"upload"`          // required file
-//	    Size   int64    `file:"upload,optional"` // optional file metadata
+//	    Name    string                  `form:"name"`                   // required field
+//	    Tags    []string                `form:"tags,optional"`          // optional slice
+//	    Address Address                 `form:"address"`                // address[city], address[zip]
+//	    Upload  *multipart.FileHeader   `file:"upload,max=5MB"`         // required file
+//	    Images  []*multipart.FileHeader `file:"images,optional,count=3"` // optional files
 //	}
 //
-// Returns an error if parsing fails, a required field is missing, or type conversion fails.
+// File tags take the limits max, types and count, see ParseMultipart. Structs
+// with UploadedFile fields are streamed to the sink set by SetUploadSink
+// instead of being buffered. Fields named Filename, FileSize or ContentType
+// with a file tag receive the metadata of the file.
+//
+// Returns an error if parsing fails, a required field or file is missing,
+// or type conversion fails.
 func ParseForm(c echo.Context, v any) error {
+	if err := parseForm(c, v); err != nil {
+		return err
+	}
+
+	r := c.Request()
+	values := r.Form
+	if r.MultipartForm != nil {
+		values = r.MultipartForm.Value
+	}
+	if err := checkPresent(v, formKey, values); err != nil {
+		return err
+	}
+	return checkFilesPresent(v)
+}
+
+func parseForm(c echo.Context, v any) error {
 	// Check if the request is multipart
 	isMultipart := strings.HasPrefix(c.Request().Header.Get("Content-Type"), "multipart/form-data")
 
+	val, isStruct := structValue(v)
+	if isMultipart && isStruct && planFor(val.Type()).streams {
+		sink := loadUploadSink()
+		if sink == nil {
+			return &storeError{errors.New("streaming file uploads needs an upload sink, see SetUploadSink")}
+		}
+		return ParseMultipart(c, v, sink)
+	}
+
 	if isMultipart {
 		if err := c.Request().ParseMultipartForm(maxMemory); err != nil {
 			return fmt.Errorf("failed to parse multipart form: %w", err)
@@ -167,70 +188,23 @@ func ParseForm(c echo.Context, v any) error {
 		}
 	}
 
-	val := reflect.ValueOf(v).Elem()
-	typ := val.Type()
-
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-		formTag := fieldType.Tag.Get("form")
-
-		if formTag != "" {
-			tagParts := strings.Split(formTag, ",")
-			formName := tagParts[0]
-			isOptional := len(tagParts) > 1 && tagParts[1] == "optional"
-
-			var formValues []string
-			if isMultipart {
-				formValues = c.Request().MultipartForm.Value[formName]
-			} else {
-				formValues = c.Request().Form[formName]
-			}
-
-			if len(formValues) > 0 {
-				if field.Kind() == reflect.Slice {
-					slice := reflect.MakeSlice(field.Type(), 0, len(formValues))
-					for _, formValue := range formValues {
-						newElem := reflect.New(field.Type().Elem()).Elem()
-						if err := setFieldValue(newElem, formValue); err != nil {
-							return fmt.Errorf("error setting form value %s: %w", formName, err)
-						}
-						slice = reflect.Append(slice, newElem)
-					}
-					field.Set(slice)
-				} else {
-					if err := setFieldValue(field, formValues[0]); err != nil {
-						return fmt.Errorf("error setting form value %s: %w", formName, err)
-					}
-				}
-			} else if !isOptional {
-				return fmt.Errorf("missing required form parameter: %s", formName)
-			}
-		}
+	if !isStruct {
+		return nil
+	}
+	plan := planFor(val.Type())
 
-		fileTag := fieldType.Tag.Get("file")
-		if isMultipart && fileTag != "" {
-			tagParts := strings.Split(fileTag, ",")
-			fileName := tagParts[0]
-			isOptional := len(tagParts) > 1 && tagParts[1] == "optional"
-
-			fileHeader, err := c.FormFile(fileName)
-			if err == nil && fileHeader != nil {
-				switch fieldType.Name {
-				case "Filename":
-					field.SetString(fileHeader.Filename)
-				case "FileSize":
-					field.SetInt(fileHeader.Size)
-				case "ContentType":
-					field.SetString(fileHeader.Header.Get("Content-Type"))
-				}
-			} else if !isOptional {
-				return fmt.Errorf("missing required file parameter: %s", fileName)
-			}
-		}
+	values := c.Request().Form
+	if isMultipart {
+		values = c.Request().MultipartForm.Value
+	}
+	if err := bindValues(val, plan, formKey, values, ""); err != nil {
+		return err
 	}
 
-	return nil
+	if !isMultipart {
+		return nil
+	}
+	return bindFiles(c.Request().MultipartForm, val, plan)
 }
 
 // ParseHeader parses a single header value that contains key-value pairs
@@ -266,32 +240,12 @@ func ParseHeader(headerValue string) map[string]string {
 // ParseJsonBody decodes JSON data from the request body into the target struct.
 // Only processes the request if Content-Type is application/json.
 //
-// Parameters:
-//   - c: The Echo context containing the HTTP request
-//   - v: A pointer to the struct where JSON data will be decoded
-//
-// ParseJsonBody extracts and parses the JSON body of an HTTP request into the target struct.
-// The body size is limited to maxBodyLen (8MB) to prevent memory exhaustion.
-// Returns an error if JSON decoding fails.
+// Deprecated: use ParseBody, which decodes every registered format.
 func ParseJsonBody(c echo.Context, v any) error {
-	if withJsonBody(c.Request()) {
-		// Create a buffer to store the body
-		var bodyBuffer bytes.Buffer
-
-		// Use TeeReader to read the body while simultaneously copying it to the buffer
-		// This also applies the maxBodyLen limit to prevent memory exhaustion
-		teeReader := io.TeeReader(io.LimitReader(c.Request().Body, maxBodyLen), &bodyBuffer)
-
-		// Decode the JSON directly from the teeReader
-		err := json.NewDecoder(teeReader).Decode(v)
-
-		// Restore the body for later use
-		c.Request().Body = io.NopCloser(&bodyBuffer)
-
-		return err
+	if !withJsonBody(c.Request()) {
+		return nil
 	}
-
-	return nil
+	return ParseBody(c, v)
 }
 
 // ParsePath extracts and parses URL path parameters into the target struct.
@@ -309,43 +263,60 @@ func ParseJsonBody(c echo.Context, v any) error {
 //	    Slug string `path:"slug,optional"` // optional
 //	}
 //
-// Returns an error if parsing fails, a required parameter is missing, or type conversion fails.
+// Returns an error if the path does not match the pattern, a required
+// parameter of the pattern is empty, or type conversion fails. Fields whose
+// parameter is not in the pattern are left alone, so a struct may serve
+// several routes.
 func ParsePath(c echo.Context, v any, pattern string) error {
 	vars, err := extractPathVars(c, pattern)
 	if err != nil {
 		return err
 	}
+	if err := bindPath(v, vars); err != nil {
+		return err
+	}
+	return checkPathPresent(v, vars)
+}
 
-	val := reflect.ValueOf(v).Elem()
-	typ := val.Type()
-
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-		pathTag := fieldType.Tag.Get("path")
-
-		if pathTag != "" {
-			tagParts := strings.Split(pathTag, ",")
-			pathName := tagParts[0]
-			isOptional := len(tagParts) > 1 && tagParts[1] == "optional"
+func parsePath(c echo.Context, v any, pattern string) error {
+	vars, err := extractPathVars(c, pattern)
+	if err != nil {
+		return err
+	}
+	return bindPath(v, vars)
+}
 
-			var paramValue string
-			if value, ok := vars[pathName]; ok {
-				paramValue = value
-			} else {
-				paramValue = c.Param(pathName)
-			}
+func bindPath(v any, vars map[string]string) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
 
-			if paramValue != "" {
-				if err := setFieldValue(field, paramValue); err != nil {
-					return fmt.Errorf("error setting path parameter %s: %w", pathName, err)
-				}
-			} else if !isOptional {
-				return fmt.Errorf("missing required path parameter: %s", pathName)
-			}
+	values := make(map[string][]string, len(vars))
+	for name, value := range vars {
+		if value != "" {
+			values[name] = []string{value}
 		}
 	}
+	return bindValues(val, planFor(val.Type()), pathKey, values, "")
+}
 
+// checkPathPresent returns an error for the first required path field whose
+// parameter is in vars but empty
+func checkPathPresent(v any, vars map[string]string) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+	for _, fp := range planFor(val.Type()).fields {
+		key, ok := fp.keys[pathKey]
+		if !ok || fp.optional[pathKey] {
+			continue
+		}
+		if value, declared := vars[key]; declared && value == "" {
+			return fmt.Errorf("missing required path parameter: %s", key)
+		}
+	}
 	return nil
 }
 
@@ -444,127 +415,30 @@ func extractParamNames(part string) []string {
 //   - c: The Echo context containing the HTTP request
 //   - v: A pointer to the struct where query values will be stored
 //
-// Query fields can be marked as optional using the "optional" tag modifier:
+// Slices take repeated keys (?id=1&id=2), "id[]" keys or comma-separated
+// values, nested structs use bracket notation (?filter[status]=open). Query
+// fields can be marked as optional using the "optional" tag modifier:
 //
 //	type QueryParams struct {
-//	    Page  int    `query:"page"`           // required
-//	    Size  int    `query:"size,optional"`  // optional
-//	    Sort  string `query:"sort,optional"`  // optional
+//	    Page  int     `query:"page"`           // required
+//	    Size  *int    `query:"size,optional"`  // optional, nil if absent
+//	    IDs   []int64 `query:"id,optional"`    // optional
 //	}
 //
 // Returns an error if a required parameter is missing or if type conversion fails.
 func ParseQuery(c echo.Context, v any) error {
-	queryParams := c.QueryParams()
-	val := reflect.ValueOf(v).Elem()
-	typ := val.Type()
-
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-		queryTag := fieldType.Tag.Get("query")
-
-		if queryTag != "" {
-			tagParts := strings.Split(queryTag, ",")
-			queryName := tagParts[0]
-			isOptional := len(tagParts) > 1 && tagParts[1] == "optional"
-
-			queryValue := queryParams.Get(queryName)
-			if queryValue != "" {
-				if err := setFieldValue(field, queryValue); err != nil {
-					return fmt.Errorf("error setting query parameter %s: %w", queryName, err)
-				}
-			} else if !isOptional {
-				return fmt.Errorf("missing required query parameter: %s", queryName)
-			}
-		}
+	if err := parseQuery(c, v); err != nil {
+		return err
 	}
-
-	return nil
+	return checkPresent(v, "query", c.QueryParams())
 }
 
-// setFieldValue sets a reflected field's value from a string input.
-// Supports multiple types including basic types, time.Time, slices, and maps.
-//
-// Parameters:
-//   - field: The reflected field to set
-//   - value: The string value to parse and set
-//
-// Supported types:
-//   - String
-//   - Int, Int8, Int16, Int32, Int64
-//   - Uint, Uint8, Uint16, Uint32, Uint64
-//   - Float32, Float64
-//   - Bool
-//   - time.Time (RFC3339 format)
-//   - []string (comma-separated values)
-//   - map[string]string (JSON format)
-//
-// Returns an error if type conversion fails or if the field type is unsupported.
-func setFieldValue(field reflect.Value, value string) error {
-	switch field.Kind() {
-	case reflect.String:
-		field.SetString(value)
-	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
-		intValue, err := strconv.ParseInt(value, 10, 64)
-		if err != nil {
-			return err
-		}
-		field.SetInt(intValue)
-	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
-		uintValue, err := strconv.ParseUint(value, 10, 64)
-		if err != nil {
-			return err
-		}
-		field.SetUint(uintValue)
-	case reflect.Float32, reflect.Float64:
-		floatValue, err := strconv.ParseFloat(value, 64)
-		if err != nil {
-			return err
-		}
-		field.SetFloat(floatValue)
-	case reflect.Bool:
-		boolValue, err := strconv.ParseBool(value)
-		if err != nil {
-			return err
-		}
-		field.SetBool(boolValue)
-	case reflect.Struct:
-		if field.Type() == reflect.TypeOf(time.Time{}) {
-			timeValue, err := time.Parse(time.RFC3339, value)
-			if err != nil {
-				return err
-			}
-			field.Set(reflect.ValueOf(timeValue))
-		} else {
-			return fmt.Errorf("unsupported struct type: %s", field.Type().String())
-		}
-	case reflect.Slice:
-		// Handle slice types (assuming string slice for now)
-		if field.Type().Elem().Kind() == reflect.String {
-			values := strings.Split(value, ",")
-			slice := reflect.MakeSlice(field.Type(), len(values), len(values))
-			for i, v := range values {
-				slice.Index(i).SetString(v)
-			}
-			field.Set(slice)
-		} else {
-			return fmt.Errorf("unsupported slice type: %s", field.Type().Elem().String())
-		}
-	case reflect.Map:
-		// Handle map types (assuming string to string map for now)
-		if field.Type().Key().Kind() == reflect.String && field.Type().Elem().Kind() == reflect.String {
-			var mapValue map[string]string
-			if err := json.Unmarshal([]byte(value), &mapValue); err != nil {
-				return err
-			}
-			field.Set(reflect.ValueOf(mapValue))
-		} else {
-			return fmt.Errorf("unsupported map type: %s", field.Type().String())
-		}
-	default:
-		return fmt.Errorf("unsupported field type: %s", field.Kind().String())
+func parseQuery(c echo.Context, v any) error {
+	val, ok := structValue(v)
+	if !ok {
+		return nil
 	}
-	return nil
+	return bindValues(val, planFor(val.Type()), "query", c.QueryParams(), "")
 }
 
 // SetValidator configures the validator used for validating parsed data.
@@ -576,6 +450,17 @@ func SetValidator(val Validator) {
 	validator.Store(val)
 }
 
+// structValue returns the struct v points to, reporting false for other
+// targets such as slices, which are only bound from the body
+func structValue(v any) (reflect.Value, bool) {
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Ptr || val.IsNil() {
+		return reflect.Value{}, false
+	}
+	val = val.Elem()
+	return val, val.Kind() == reflect.Struct
+}
+
 // withJsonBody checks if the request contains JSON data based on Content-Type
 // and Content-Length headers.
 //
@@ -3,6 +3,7 @@ package httpx
 import (
 	"bytes"
 	"errors"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
@@ -119,6 +120,8 @@ func TestParseMixingJsonAndFormData(t *testing.T) {
 	if err != nil {
 		assert.Contains(t, err.Error(), "cannot mix form and json data")
 	}
+	var bindErr *BindError
+	assert.ErrorAs(t, err, &bindErr)
 }
 
 // TestParseMissingHeader tests missing header validation.
@@ -614,3 +617,100 @@ func TestOptionalQueryParams(t *testing.T) {
 		})
 	}
 }
+
+// PresenceRequest is a struct for testing the presence checks of the standalone parse functions.
+type PresenceRequest struct {
+	ID     string   `path:"id"`
+	Email  string   `query:"email"`
+	Page   int      `query:"page,optional"`
+	Tags   []string `query:"tags"`
+	Name   string   `form:"name"`
+	Token  string   `header:"X-Token"`
+	Locale string   `header:"X-Locale,optional"`
+}
+
+// TestStandaloneParseRequiresParameters tests that the standalone parse
+// functions report missing required parameters, as Parse leaves them to validation.
+func TestStandaloneParseRequiresParameters(t *testing.T) {
+	e := echo.New()
+	newContext := func(target string, body string) echo.Context {
+		req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	c := newContext("/items?email=a@example.com&tags[]=x", "")
+	assert.NoError(t, ParseQuery(c, new(PresenceRequest)))
+	assert.EqualError(t, ParseHeaders(c, new(PresenceRequest)), "missing required header parameter: X-Token")
+	assert.EqualError(t, ParseForm(c, new(PresenceRequest)), "missing required form parameter: name")
+
+	c = newContext("/items?tags=x", "name=widget")
+	assert.EqualError(t, ParseQuery(c, new(PresenceRequest)), "missing required query parameter: email")
+	assert.NoError(t, ParseForm(c, new(PresenceRequest)))
+	c.Request().Header.Set("X-Token", "secret")
+	assert.NoError(t, ParseHeaders(c, new(PresenceRequest)))
+
+	c = newContext("/items/", "")
+	c.SetParamNames("id")
+	c.SetParamValues("")
+	assert.EqualError(t, ParsePath(c, new(PresenceRequest), ""), "missing required path parameter: id")
+	assert.NoError(t, ParsePath(newContext("/items", ""), new(PresenceRequest), "/items"), "parameters not in the pattern are not required")
+}
+
+// FileRequest is a struct for testing required files.
+type FileRequest struct {
+	Upload *multipart.FileHeader `file:"upload"`
+	Extra  *multipart.FileHeader `file:"extra,optional"`
+}
+
+// TestParseFormRequiresFiles tests that ParseForm reports missing required files.
+func TestParseFormRequiresFiles(t *testing.T) {
+	e := echo.New()
+	newContext := func(file string) echo.Context {
+		var body bytes.Buffer
+		w := multipart.NewWriter(&body)
+		fw, _ := w.CreateFormFile(file, "a.txt")
+		fw.Write([]byte("hello"))
+		w.Close()
+		req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+		req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+		return e.NewContext(req, httptest.NewRecorder())
+	}
+
+	assert.NoError(t, ParseForm(newContext("upload"), new(FileRequest)))
+	assert.EqualError(t, ParseForm(newContext("extra"), new(FileRequest)), "missing required file parameter: upload")
+}
+
+// EmptyValuesRequest is a struct for testing empty raw values.
+type EmptyValuesRequest struct {
+	Page  *int      `query:"page,optional"`
+	Size  int       `query:"size,optional"`
+	IDs   []int64   `query:"ids,optional"`
+	Since time.Time `query:"since,optional"`
+	Name  *string   `query:"name,optional"`
+	Q     string    `query:"q,optional"`
+}
+
+// TestParseQueryEmptyValues tests that empty values leave fields without an empty representation unset.
+func TestParseQueryEmptyValues(t *testing.T) {
+	e := echo.New()
+	req := httptest.NewRequest(http.MethodGet, "/items?page=&size=&ids=&since=&name=&q=", nil)
+	c := e.NewContext(req, httptest.NewRecorder())
+
+	var r EmptyValuesRequest
+	assert.NoError(t, ParseQuery(c, &r))
+	assert.Nil(t, r.Page)
+	assert.Zero(t, r.Size)
+	assert.Nil(t, r.IDs)
+	assert.True(t, r.Since.IsZero())
+	assert.Nil(t, r.Name)
+	assert.Equal(t, "", r.Q)
+
+	assert.NoError(t, Parse(c, &r, "/items"))
+	assert.Nil(t, r.Page)
+
+	type Required struct {
+		Page int `query:"page"`
+	}
+	assert.EqualError(t, ParseQuery(c, new(Required)), "missing required query parameter: page")
+}
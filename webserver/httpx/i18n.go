@@ -0,0 +1,137 @@
+package httpx
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/labstack/echo/v4"
+)
+
+// languageKey is the echo context key of the negotiated language
+const languageKey = "httpx.language"
+
+// Translator translates validation errors into the languages of an application
+type Translator interface {
+	// Translate returns the message of fe in lang, false if there is no translation.
+	Translate(lang string, fe FieldError) (string, bool)
+}
+
+var translator atomic.Value
+
+// SetTranslator sets the Translator used to localize validation errors
+func SetTranslator(t Translator) {
+	translator.Store(&t)
+}
+
+func loadTranslator() Translator {
+	if t, ok := translator.Load().(*Translator); ok {
+		return *t
+	}
+	return nil
+}
+
+// Catalog is a Translator holding message templates by language and message
+// key. Templates may reference {field}, {rule}, {param} and {value}:
+//
+//	httpx.SetTranslator(httpx.Catalog{
+//	    "de": {
+//	        "validation.required": "{field} ist erforderlich",
+//	        "validation.min":      "mindestens {param} Zeichen",
+//	    },
+//	})
+//
+// A regional language such as de-AT falls back to de.
+type Catalog map[string]map[string]string
+
+// Translate implements Translator
+func (c Catalog) Translate(lang string, fe FieldError) (string, bool) {
+	for _, l := range []string{lang, baseLanguage(lang)} {
+		if tpl, ok := c[l][fe.Key]; ok {
+			return strings.NewReplacer(
+				"{field}", fe.Field,
+				"{rule}", fe.Rule,
+				"{param}", fe.Param,
+				"{value}", fmt.Sprint(fe.Value),
+			).Replace(tpl), true
+		}
+	}
+	return "", false
+}
+
+// Languages returns middleware negotiating the language of every request from
+// its Accept-Language header among langs, the languages declared in the
+// server block of the .api file. The first language is the default.
+func Languages(langs ...string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			if lang := negotiate(c.Request().Header.Get("Accept-Language"), langs); lang != "" {
+				c.Set(languageKey, lang)
+			}
+			return next(c)
+		}
+	}
+}
+
+// Language returns the language negotiated for the request by the Languages
+// middleware, empty if the middleware is not in use
+func Language(c echo.Context) string {
+	lang, _ := c.Get(languageKey).(string)
+	return lang
+}
+
+// negotiate picks the supported language preferred by an Accept-Language
+// header, the first supported language if none matches
+func negotiate(header string, supported []string) string {
+	if len(supported) == 0 {
+		return ""
+	}
+
+	type preference struct {
+		lang string
+		q    float64
+	}
+	var prefs []preference
+	for _, part := range strings.Split(header, ",") {
+		lang, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if lang == "" {
+			continue
+		}
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+				q = parsed
+			}
+		}
+		if q > 0 {
+			prefs = append(prefs, preference{lang: lang, q: q})
+		}
+	}
+	sort.SliceStable(prefs, func(i, j int) bool { return prefs[i].q > prefs[j].q })
+
+	for _, pref := range prefs {
+		if pref.lang == "*" {
+			return supported[0]
+		}
+		// Prefer an exact match, then the base language on either side
+		for _, lang := range supported {
+			if strings.EqualFold(pref.lang, lang) {
+				return lang
+			}
+		}
+		for _, lang := range supported {
+			if strings.EqualFold(baseLanguage(pref.lang), baseLanguage(lang)) {
+				return lang
+			}
+		}
+	}
+	return supported[0]
+}
+
+// baseLanguage strips the region of a language tag, de-AT becomes de
+func baseLanguage(lang string) string {
+	base, _, _ := strings.Cut(lang, "-")
+	return strings.ToLower(base)
+}
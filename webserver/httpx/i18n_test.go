@@ -0,0 +1,158 @@
+package httpx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type SignupForm struct {
+	Email    string   `form:"email" validate:"email"`
+	Password string   `form:"password" validate:"min=8"`
+	Address  *Address `form:"address,optional"`
+	Nickname string   `form:"nickname" validate:"alphanum"`
+}
+
+func newFormContext(form url.Values, header http.Header) (echo.Context, *httptest.ResponseRecorder) {
+	req := httptest.NewRequest(http.MethodPost, "/signup", strings.NewReader(form.Encode()))
+	req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationForm)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	return echo.New().NewContext(req, rec), rec
+}
+
+// TestValidationErrorsListsEveryField tests that every failing field is reported with its request-facing name.
+func TestValidationErrorsListsEveryField(t *testing.T) {
+	c, _ := newFormContext(url.Values{
+		"email":         {"nope"},
+		"password":      {"short"},
+		"address[city]": {"Paris"},
+		"address[zip]":  {"75001"},
+		"nickname":      {"no spaces"},
+	}, nil)
+
+	var f SignupForm
+	err := Parse(c, &f, "/signup")
+
+	errs, ok := AsValidationErrors(err)
+	require.True(t, ok)
+	require.Len(t, errs, 3)
+
+	assert.Equal(t, FieldError{Field: "email", Path: "Email", Rule: "email", Key: "validation.email", Message: "nope does not validate as email", Value: "nope"}, errs[0])
+	assert.Equal(t, "password", errs[1].Field)
+	assert.Equal(t, "min", errs[1].Rule)
+	assert.Equal(t, "8", errs[1].Param)
+	assert.Equal(t, "minimum length is 8", errs[1].Message)
+	assert.Equal(t, "nickname", errs[2].Field)
+	assert.True(t, errs.Has("password"))
+	assert.False(t, errs.Has("address[city]"))
+	assert.Equal(t, "Paris", f.Address.City)
+	assert.Equal(t, map[string]string{
+		"email":    "nope does not validate as email",
+		"password": "minimum length is 8",
+		"nickname": "must be alphanumeric",
+	}, errs.Fields())
+}
+
+// TestValidationErrorsNestedNames tests bracket notation names for nested struct fields.
+func TestValidationErrorsNestedNames(t *testing.T) {
+	type Shipping struct {
+		Address Address `form:"address"`
+	}
+	type Nested struct {
+		City string `form:"city" validate:"required"`
+	}
+	type Order struct {
+		Shipping Shipping `form:"shipping"`
+		Nested   Nested   `form:"nested"`
+	}
+
+	errs, ok := AsValidationErrors(ValidateStruct(&Order{Shipping: Shipping{Address: Address{City: "Rome"}}}))
+	require.True(t, ok)
+	require.Len(t, errs, 1)
+	assert.Equal(t, "nested[city]", errs[0].Field)
+	assert.Equal(t, "Nested.City", errs[0].Path)
+	assert.Equal(t, "field Nested.City: is required", errs.Error())
+}
+
+// TestParseLocalizesErrors tests translation of messages into the negotiated language.
+func TestParseLocalizesErrors(t *testing.T) {
+	SetTranslator(Catalog{
+		"de": {
+			"validation.required": "{field} ist erforderlich",
+			"validation.min":      "mindestens {param} Zeichen",
+		},
+	})
+	defer SetTranslator(nil)
+
+	c, _ := newFormContext(url.Values{"email": {"a@example.com"}, "password": {"short"}}, http.Header{
+		"Accept-Language": {"de-AT,de;q=0.9,en;q=0.8"},
+	})
+
+	var f SignupForm
+	err := Languages("en", "de")(func(c echo.Context) error {
+		return Parse(c, &f, "/signup")
+	})(c)
+
+	errs, ok := AsValidationErrors(err)
+	require.True(t, ok)
+	assert.Equal(t, "de", Language(c))
+	assert.Equal(t, "mindestens 8 Zeichen", errs.Get("password"))
+	assert.Equal(t, "nickname ist erforderlich", errs.Get("nickname"))
+	assert.Equal(t, "validation.min", errs[0].Key)
+}
+
+// TestNegotiate tests Accept-Language negotiation against the declared languages.
+func TestNegotiate(t *testing.T) {
+	supported := []string{"en", "de", "pt-BR"}
+
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", "en"},
+		{"fr", "en"},
+		{"de", "de"},
+		{"de-CH", "de"},
+		{"pt", "pt-BR"},
+		{"fr;q=1, de;q=0.5, en;q=0.7", "en"},
+		{"de;q=0, *", "en"},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, negotiate(tt.header, supported), tt.header)
+	}
+	assert.Equal(t, "", negotiate("de", nil))
+}
+
+// TestRenderForm tests re-rendering a form with its errors.
+func TestRenderForm(t *testing.T) {
+	form := func(errs ValidationErrors) templ.Component {
+		return templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+			_, err := io.WriteString(w, "password: "+errs.Get("password"))
+			return err
+		})
+	}
+
+	c, rec := newFormContext(url.Values{"email": {"a@example.com"}, "password": {"short"}, "nickname": {"bob"}}, nil)
+	var f SignupForm
+	require.NoError(t, RenderForm(c, Parse(c, &f, "/signup"), form))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Equal(t, "password: minimum length is 8", rec.Body.String())
+
+	c, rec = newFormContext(url.Values{"password": {"short"}}, http.Header{"Hx-Request": {"true"}})
+	require.NoError(t, RenderForm(c, Parse(c, &f, "/signup"), form))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	assert.EqualError(t, RenderForm(c, assert.AnError, form), assert.AnError.Error())
+}
@@ -0,0 +1,447 @@
+package httpx
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// FieldContext is the field a rule is checked against
+type FieldContext struct {
+	// Value is the value of the field, set pointers are dereferenced
+	Value reflect.Value
+	// Param is the parameter of the rule, e.g. 8 for min=8
+	Param string
+	// Parent is the struct holding the field, for rules comparing fields
+	Parent reflect.Value
+}
+
+// RuleFunc reports whether a field satisfies a rule
+type RuleFunc func(fc FieldContext) bool
+
+// compiledRule is a rule of a validate tag, compiled for the type of its field
+type compiledRule struct {
+	name  string
+	param string
+	check RuleFunc
+	// message is the English message, {value} is replaced by the rejected value
+	message string
+	// presence rules are checked for empty values too
+	presence bool
+}
+
+var (
+	customRules   = map[string]RuleFunc{}
+	customRulesMu sync.RWMutex
+)
+
+// RegisterRule adds a rule usable in validate tags, replacing any rule with
+// the same name. The message key of its errors is validation.<name>:
+//
+//	httpx.RegisterRule("even", func(fc httpx.FieldContext) bool {
+//	    return fc.Value.Int()%2 == 0
+//	})
+func RegisterRule(name string, fn RuleFunc) {
+	customRulesMu.Lock()
+	customRules[name] = fn
+	customRulesMu.Unlock()
+	resetValidationPlans()
+}
+
+// unregisterRule removes a custom rule, tests use it to clean up after RegisterRule
+func unregisterRule(name string) {
+	customRulesMu.Lock()
+	delete(customRules, name)
+	customRulesMu.Unlock()
+	resetValidationPlans()
+}
+
+// resetValidationPlans drops the compiled plans, which may refer to a changed rule
+func resetValidationPlans() {
+	validationPlans.Range(func(key, _ any) bool {
+		validationPlans.Delete(key)
+		return true
+	})
+}
+
+var (
+	emailRegexp        = regexp.MustCompile(`^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`)
+	urlRegexp          = regexp.MustCompile(`^(http|https)://[a-zA-Z0-9\-\.]+\.[a-zA-Z]{2,}(?:/[\w\-\./?%&=]*)?$`)
+	uuidRegexp         = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+	nonDigitRegexp     = regexp.MustCompile(`\D`)
+	errMissingParam    = errors.New("missing parameter")
+	errUnsupportedType = errors.New("unsupported field type")
+)
+
+// stringRules are the rules checking the text of a field
+var stringRules = map[string]struct {
+	check   func(s string) bool
+	message string
+}{
+	"email":      {isValidEmail, "{value} does not validate as email"},
+	"url":        {isValidURL, "invalid URL"},
+	"uuid":       {uuidRegexp.MatchString, "must be a valid UUID"},
+	"alphanum":   {isAlphanumeric, "must be alphanumeric"},
+	"creditcard": {isValidCreditCard, "invalid credit card number"},
+	"ip":         {isIP, "must be a valid IP address"},
+	"ipv4":       {isIPv4, "must be a valid IPv4 address"},
+	"ipv6":       {isIPv6, "must be a valid IPv6 address"},
+	"cidr": {func(s string) bool {
+		_, _, err := net.ParseCIDR(s)
+		return err == nil
+	}, "must be a valid CIDR notation"},
+}
+
+// compileRule compiles the rule name with param for values of type t, a field of the struct type parent
+func compileRule(name, param string, t, parent reflect.Type) (compiledRule, error) {
+	rule := compiledRule{name: name, param: param}
+
+	if sr, ok := stringRules[name]; ok {
+		rule.check = func(fc FieldContext) bool { return sr.check(stringOf(fc.Value)) }
+		rule.message = sr.message
+		return rule, nil
+	}
+
+	var err error
+	switch name {
+	case "required":
+		rule.presence = true
+		rule.check = func(fc FieldContext) bool { return !isEmpty(fc.Value) }
+		rule.message = "is required"
+	case "required_if":
+		rule.presence = true
+		rule.check, err = compileRequiredIf(param, parent)
+		rule.message = "is required"
+	case "min", "max", "len", "gt", "gte", "lt", "lte":
+		rule.check, rule.message, err = compileComparison(name, param, t)
+	case "eqfield", "nefield", "gtfield", "gtefield", "ltfield", "ltefield":
+		rule.check, rule.message, err = compileFieldComparison(name, param, parent)
+	case "oneof":
+		options := strings.Fields(param)
+		if len(options) == 0 {
+			return rule, errMissingParam
+		}
+		rule.check = func(fc FieldContext) bool {
+			s := stringOf(fc.Value)
+			for _, option := range options {
+				if s == option {
+					return true
+				}
+			}
+			return false
+		}
+		rule.message = "must be one of " + strings.Join(options, ", ")
+	case "pattern":
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(param); err == nil {
+			rule.check = func(fc FieldContext) bool { return re.MatchString(stringOf(fc.Value)) }
+			rule.message = "does not match the required pattern"
+		}
+	case "datetime":
+		if param == "" {
+			return rule, errMissingParam
+		}
+		rule.check = func(fc FieldContext) bool {
+			_, err := time.Parse(param, stringOf(fc.Value))
+			return err == nil
+		}
+		rule.message = "must be a date in the format " + param
+	default:
+		customRulesMu.RLock()
+		fn, ok := customRules[name]
+		customRulesMu.RUnlock()
+		if !ok {
+			return rule, fmt.Errorf("unknown rule %s", name)
+		}
+		rule.check = fn
+		rule.message = "is invalid"
+	}
+	if err != nil {
+		return rule, fmt.Errorf("rule %s: %w", name, err)
+	}
+	return rule, nil
+}
+
+// comparisonMessages are the messages of the comparison rules by kind of value
+var comparisonMessages = map[string][3]string{
+	//       length                      number                      time
+	"min": {"minimum length is %s", "must be at least %s", ""},
+	"max": {"maximum length is %s", "must be at most %s", ""},
+	"len": {"length must be %s", "must be %s", ""},
+	"gt":  {"length must be greater than %s", "must be greater than %s", "must be after %s"},
+	"gte": {"minimum length is %s", "must be at least %s", "must not be before %s"},
+	"lt":  {"length must be less than %s", "must be less than %s", "must be before %s"},
+	"lte": {"maximum length is %s", "must be at most %s", "must not be after %s"},
+}
+
+// compileComparison compiles a comparison of the length of strings, slices
+// and maps, the value of numbers, or of times with a date or now
+func compileComparison(name, param string, t reflect.Type) (RuleFunc, string, error) {
+	messages := comparisonMessages[name]
+
+	if t == timeType {
+		if messages[2] == "" {
+			return nil, "", errUnsupportedType
+		}
+		if param == "" || param == "now" {
+			return func(fc FieldContext) bool {
+				return compareOrdered(name, fc.Value.Interface().(time.Time).Compare(time.Now()))
+			}, fmt.Sprintf(messages[2], "now"), nil
+		}
+		bound, err := parseTime(param)
+		if err != nil {
+			return nil, "", err
+		}
+		return func(fc FieldContext) bool {
+			return compareOrdered(name, fc.Value.Interface().(time.Time).Compare(bound))
+		}, fmt.Sprintf(messages[2], param), nil
+	}
+
+	if param == "" {
+		return nil, "", errMissingParam
+	}
+	switch t.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		n, err := strconv.Atoi(param)
+		if err != nil {
+			return nil, "", err
+		}
+		return func(fc FieldContext) bool {
+			length := fc.Value.Len()
+			if fc.Value.Kind() == reflect.String {
+				length = utf8.RuneCountInString(fc.Value.String())
+			}
+			return compareOrdered(name, compareNumbers(float64(length), float64(n)))
+		}, fmt.Sprintf(messages[0], param), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			return nil, "", err
+		}
+		return func(fc FieldContext) bool {
+			f, _ := numberOf(fc.Value)
+			return compareOrdered(name, compareNumbers(f, n))
+		}, fmt.Sprintf(messages[1], param), nil
+	}
+	return nil, "", errUnsupportedType
+}
+
+// fieldComparisonMessages are the messages of the rules comparing fields
+var fieldComparisonMessages = map[string]string{
+	"eqfield":  "must match %s",
+	"nefield":  "must differ from %s",
+	"gtfield":  "must be greater than %s",
+	"gtefield": "must be at least %s",
+	"ltfield":  "must be less than %s",
+	"ltefield": "must be at most %s",
+}
+
+// compileFieldComparison compiles a comparison with the field param of the struct type parent
+func compileFieldComparison(name, param string, parent reflect.Type) (RuleFunc, string, error) {
+	other, ok := parent.FieldByName(param)
+	if !ok {
+		return nil, "", fmt.Errorf("no field %s", param)
+	}
+	message := fmt.Sprintf(fieldComparisonMessages[name], requestName(other))
+
+	return func(fc FieldContext) bool {
+		otherValue, err := fc.Parent.FieldByIndexErr(other.Index)
+		if err != nil {
+			return false
+		}
+		otherValue = deref(otherValue)
+		if !otherValue.IsValid() {
+			return name == "nefield"
+		}
+
+		switch name {
+		case "eqfield":
+			return valuesEqual(fc.Value, otherValue)
+		case "nefield":
+			return !valuesEqual(fc.Value, otherValue)
+		}
+		cmp, ok := compareValues(fc.Value, otherValue)
+		if !ok {
+			return false
+		}
+		return compareOrdered(strings.TrimSuffix(name, "field"), cmp)
+	}, message, nil
+}
+
+// compileRequiredIf compiles the condition of required_if=Field value [Field value...]
+func compileRequiredIf(param string, parent reflect.Type) (RuleFunc, error) {
+	parts := strings.Fields(param)
+	if len(parts) == 0 || len(parts)%2 != 0 {
+		return nil, errors.New("expected pairs of field and value")
+	}
+
+	type condition struct {
+		index []int
+		value string
+	}
+	conditions := make([]condition, 0, len(parts)/2)
+	for i := 0; i < len(parts); i += 2 {
+		other, ok := parent.FieldByName(parts[i])
+		if !ok {
+			return nil, fmt.Errorf("no field %s", parts[i])
+		}
+		conditions = append(conditions, condition{index: other.Index, value: parts[i+1]})
+	}
+
+	return func(fc FieldContext) bool {
+		for _, cond := range conditions {
+			otherValue, err := fc.Parent.FieldByIndexErr(cond.index)
+			if err != nil {
+				return true
+			}
+			if otherValue = deref(otherValue); !otherValue.IsValid() || stringOf(otherValue) != cond.value {
+				return true
+			}
+		}
+		return !isEmpty(fc.Value)
+	}, nil
+}
+
+// compareOrdered reports whether cmp, the result of comparing a value with a bound, satisfies the rule name
+func compareOrdered(name string, cmp int) bool {
+	switch name {
+	case "min", "gte":
+		return cmp >= 0
+	case "max", "lte":
+		return cmp <= 0
+	case "len":
+		return cmp == 0
+	case "gt":
+		return cmp > 0
+	case "lt":
+		return cmp < 0
+	}
+	return false
+}
+
+func compareNumbers(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// compareValues compares two numbers, strings or times
+func compareValues(a, b reflect.Value) (int, bool) {
+	if a.Type() == timeType && b.Type() == timeType {
+		return a.Interface().(time.Time).Compare(b.Interface().(time.Time)), true
+	}
+	if x, ok := numberOf(a); ok {
+		if y, ok := numberOf(b); ok {
+			return compareNumbers(x, y), true
+		}
+	}
+	if a.Kind() == reflect.String && b.Kind() == reflect.String {
+		return strings.Compare(a.String(), b.String()), true
+	}
+	return 0, false
+}
+
+// valuesEqual reports whether two field values are equal
+func valuesEqual(a, b reflect.Value) bool {
+	if cmp, ok := compareValues(a, b); ok {
+		return cmp == 0
+	}
+	return reflect.DeepEqual(valueOf(a), valueOf(b))
+}
+
+// numberOf returns the value of a number as float64
+func numberOf(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	}
+	return 0, false
+}
+
+// stringOf returns the text of a value, using String for types such as uuid.UUID
+func stringOf(v reflect.Value) string {
+	if !v.IsValid() {
+		return ""
+	}
+	if v.Kind() == reflect.String {
+		return v.String()
+	}
+	if s, ok := valueOf(v).(fmt.Stringer); ok {
+		return s.String()
+	}
+	return fmt.Sprint(valueOf(v))
+}
+
+func isValidEmail(email string) bool {
+	return emailRegexp.MatchString(email)
+}
+
+func isValidCreditCard(number string) bool {
+	// Remove any non-digit characters
+	number = nonDigitRegexp.ReplaceAllString(number, "")
+
+	// Check if the number is between 13 and 19 digits
+	if len(number) < 13 || len(number) > 19 {
+		return false
+	}
+
+	// Luhn algorithm
+	sum := 0
+	isEven := false
+	for i := len(number) - 1; i >= 0; i-- {
+		digit := int(number[i] - '0')
+		if isEven {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		isEven = !isEven
+	}
+	return sum%10 == 0
+}
+
+func isAlphanumeric(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
+			return false
+		}
+	}
+	return true
+}
+
+func isValidURL(url string) bool {
+	return urlRegexp.MatchString(url)
+}
+
+func isIP(s string) bool {
+	return net.ParseIP(s) != nil
+}
+
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil && !strings.Contains(s, ":")
+}
+
+func isIPv6(s string) bool {
+	return net.ParseIP(s) != nil && strings.Contains(s, ":")
+}
@@ -0,0 +1,196 @@
+package httpx
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// failedRules validates v and returns the failing rule of every field by request name
+func failedRules(t *testing.T, v any) map[string]string {
+	t.Helper()
+	err := ValidateStruct(v)
+	if err == nil {
+		return map[string]string{}
+	}
+	errs, ok := AsValidationErrors(err)
+	require.True(t, ok, err.Error())
+
+	rules := make(map[string]string, len(errs))
+	for _, fe := range errs {
+		rules[fe.Field] = fe.Rule
+	}
+	return rules
+}
+
+type Booking struct {
+	Guests   int       `form:"guests" validate:"min=1,max=8"`
+	Price    float64   `form:"price,optional" validate:"gte=0,lt=1000"`
+	Room     string    `form:"room" validate:"oneof=single double suite"`
+	Code     string    `form:"code,optional" validate:"omitempty,len=6"`
+	Slug     string    `form:"slug,optional" validate:"omitempty,pattern=^[a-z]{2,4}$"`
+	Owner    string    `form:"owner,optional" validate:"omitempty,uuid"`
+	Guest    uuid.UUID `form:"guest" validate:"uuid"`
+	IP       string    `form:"ip,optional" validate:"omitempty,ipv4"`
+	Network  string    `form:"network,optional" validate:"omitempty,cidr"`
+	CheckIn  time.Time `form:"checkIn" validate:"gt=now"`
+	CheckOut time.Time `form:"checkOut" validate:"gtfield=CheckIn"`
+	Birthday string    `form:"birthday,optional" validate:"omitempty,datetime=2006-01-02"`
+}
+
+func validBooking() Booking {
+	return Booking{
+		Guests:   2,
+		Room:     "double",
+		Guest:    uuid.New(),
+		CheckIn:  time.Now().Add(24 * time.Hour),
+		CheckOut: time.Now().Add(48 * time.Hour),
+	}
+}
+
+// TestValidateRules tests the built-in rules.
+func TestValidateRules(t *testing.T) {
+	b := validBooking()
+	assert.Empty(t, failedRules(t, &b))
+
+	b = Booking{
+		Guests:   9,
+		Price:    1000,
+		Room:     "penthouse",
+		Code:     "12345",
+		Slug:     "a,b",
+		Owner:    "not-a-uuid",
+		IP:       "::1",
+		Network:  "10.0.0.0/33",
+		CheckIn:  time.Now().Add(-time.Hour),
+		CheckOut: time.Now().Add(-2 * time.Hour),
+		Birthday: "01/02/2006",
+	}
+	assert.Equal(t, map[string]string{
+		"guests":   "max",
+		"price":    "lt",
+		"room":     "oneof",
+		"code":     "len",
+		"slug":     "pattern",
+		"owner":    "uuid",
+		"guest":    "required",
+		"ip":       "ipv4",
+		"network":  "cidr",
+		"checkIn":  "gt",
+		"checkOut": "gtfield",
+		"birthday": "datetime",
+	}, failedRules(t, &b))
+}
+
+// TestValidateMessages tests the messages of numeric, length and cross-field rules.
+func TestValidateMessages(t *testing.T) {
+	b := validBooking()
+	b.Guests = 0
+	b.Room = "suite"
+	b.CheckOut = b.CheckIn
+
+	errs, ok := AsValidationErrors(ValidateStruct(&b))
+	require.True(t, ok)
+	assert.Equal(t, "is required", errs.Get("guests"))
+	assert.Equal(t, "must be greater than checkIn", errs.Get("checkOut"))
+
+	type Name struct {
+		First string `json:"first" validate:"min=3"`
+		Age   int    `json:"age" validate:"min=18"`
+	}
+	errs, ok = AsValidationErrors(ValidateStruct(&Name{First: "Zoë", Age: 17}))
+	require.True(t, ok)
+	assert.Equal(t, "must be at least 18", errs.Get("age"))
+	assert.False(t, errs.Has("first"))
+}
+
+// TestValidateCrossField tests eqfield and required_if.
+func TestValidateCrossField(t *testing.T) {
+	type Signup struct {
+		Password string `form:"password" validate:"min=8"`
+		Confirm  string `form:"confirm" validate:"eqfield=Password"`
+		Contact  string `form:"contact" validate:"oneof=email phone"`
+		Phone    string `form:"phone,optional" validate:"required_if=Contact phone,pattern=^[0-9+ ]+$"`
+	}
+
+	assert.Empty(t, failedRules(t, &Signup{Password: "secret123", Confirm: "secret123", Contact: "email"}))
+	assert.Equal(t, map[string]string{
+		"confirm": "eqfield",
+		"phone":   "required_if",
+	}, failedRules(t, &Signup{Password: "secret123", Confirm: "secret124", Contact: "phone"}))
+	assert.Equal(t, map[string]string{
+		"phone": "pattern",
+	}, failedRules(t, &Signup{Password: "secret123", Confirm: "secret123", Contact: "phone", Phone: "call me"}))
+
+	errs, _ := AsValidationErrors(ValidateStruct(&Signup{Password: "secret123", Confirm: "x", Contact: "email"}))
+	assert.Equal(t, "must match password", errs.Get("confirm"))
+}
+
+// TestValidateDive tests rules applied to slice and map elements and nested struct elements.
+func TestValidateDive(t *testing.T) {
+	type Item struct {
+		SKU string `json:"sku" validate:"required"`
+	}
+	type Order struct {
+		Emails []string          `json:"emails" validate:"min=1,dive,email"`
+		Tags   map[string]string `json:"tags,optional" validate:"dive,max=3"`
+		Items  []Item            `json:"items"`
+	}
+
+	o := Order{
+		Emails: []string{"a@example.com", "nope"},
+		Tags:   map[string]string{"color": "red", "size": "XXXL"},
+		Items:  []Item{{SKU: "A-1"}, {}},
+	}
+	errs, ok := AsValidationErrors(ValidateStruct(&o))
+	require.True(t, ok)
+	assert.Equal(t, map[string]string{
+		"emails[1]":     "nope does not validate as email",
+		"tags[size]":    "maximum length is 3",
+		"items[1][sku]": "is required",
+	}, errs.Fields())
+	assert.Equal(t, "Items[1].SKU", errs[len(errs)-1].Path)
+
+	assert.Equal(t, map[string]string{"emails": "required"}, failedRules(t, &Order{Items: []Item{{SKU: "A-1"}}}))
+}
+
+// TestRegisterRule tests custom rules.
+func TestRegisterRule(t *testing.T) {
+	type Pair struct {
+		N int `json:"n" validate:"even"`
+	}
+
+	err := ValidateStruct(&Pair{N: 2})
+	require.Error(t, err)
+	_, ok := AsValidationErrors(err)
+	assert.False(t, ok)
+	assert.Contains(t, err.Error(), "unknown rule even")
+
+	RegisterRule("even", func(fc FieldContext) bool {
+		return fc.Value.Int()%2 == 0
+	})
+	t.Cleanup(func() { unregisterRule("even") })
+	assert.NoError(t, ValidateStruct(&Pair{N: 2}))
+
+	errs, ok := AsValidationErrors(ValidateStruct(&Pair{N: 3}))
+	require.True(t, ok)
+	assert.Equal(t, FieldError{Field: "n", Path: "N", Rule: "even", Key: "validation.even", Message: "is invalid", Value: 3}, errs[0])
+}
+
+// TestValidationPlanCached tests that tags are compiled once per struct type.
+func TestValidationPlanCached(t *testing.T) {
+	typ := reflect.TypeOf(Booking{})
+	assert.Same(t, validationPlanFor(typ, true), validationPlanFor(typ, true))
+	assert.NotSame(t, validationPlanFor(typ, true), validationPlanFor(typ, false))
+}
+
+// TestSplitRules tests that pattern takes the rest of the tag.
+func TestSplitRules(t *testing.T) {
+	assert.Equal(t, []string{"required", "pattern=^[a-z]{2,4}$"}, splitRules("required, pattern=^[a-z]{2,4}$"))
+	assert.Equal(t, []string{"required", "min=2"}, splitRules("required,,min=2"))
+	assert.Nil(t, splitRules(""))
+}
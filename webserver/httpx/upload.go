@@ -0,0 +1,525 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	// defaultMaxFileSize limits files whose file tag sets no max
+	defaultMaxFileSize = 32 << 20 // 32MB
+	// sniffLen is the number of bytes used to detect the content type of a file
+	sniffLen = 512
+)
+
+var (
+	fileHeaderType   = reflect.TypeOf((*multipart.FileHeader)(nil))
+	uploadedFileType = reflect.TypeOf((*UploadedFile)(nil))
+)
+
+// FileInfo describes an uploaded file before it is stored
+type FileInfo struct {
+	// Field is the form field the file was sent in
+	Field string
+	// Filename is the name of the file on the client
+	Filename string
+	// ContentType is sniffed from the first bytes of the file, not taken from the client
+	ContentType string
+	// Header is the MIME header of the part
+	Header textproto.MIMEHeader
+}
+
+// UploadedFile is a file streamed to the upload sink
+type UploadedFile struct {
+	FileInfo
+	// Size is the size of the file in bytes
+	Size int64
+	// Location is where the sink stored the file, e.g. a path or an object key
+	Location string
+}
+
+// Sink stores uploaded files as they are streamed from the request
+type Sink interface {
+	// Store reads the file from r until EOF and returns its location. When r
+	// fails, e.g. because the file exceeds its limit, nothing may be kept.
+	Store(ctx context.Context, file FileInfo, r io.Reader) (location string, err error)
+}
+
+// Remover is implemented by sinks that can delete stored files. The files of
+// a request that fails part way are removed again.
+type Remover interface {
+	Remove(ctx context.Context, location string) error
+}
+
+var uploadSink atomic.Value
+
+// SetUploadSink sets the sink Parse streams UploadedFile fields to
+func SetUploadSink(sink Sink) {
+	uploadSink.Store(&sink)
+}
+
+func loadUploadSink() Sink {
+	if sink, ok := uploadSink.Load().(*Sink); ok {
+		return *sink
+	}
+	return nil
+}
+
+// fileRule holds the limits of a file tag such as
+// `file:"photos,optional,max=5MB,types=image/png image/jpeg,count=3"`
+type fileRule struct {
+	maxSize  int64
+	types    []string
+	count    int
+	optional bool
+	err      error
+}
+
+// parseFileTag returns the file name and limits of a file tag
+func parseFileTag(tag string) (string, *fileRule) {
+	name, opts, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return "", nil
+	}
+
+	rule := &fileRule{maxSize: defaultMaxFileSize}
+	for _, opt := range strings.Split(opts, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(opt), "=")
+		switch key {
+		case "optional":
+			rule.optional = true
+		case "max":
+			size, err := parseSize(value)
+			if err != nil {
+				rule.err = fmt.Errorf("invalid max of file %s: %w", name, err)
+			}
+			rule.maxSize = size
+		case "types":
+			rule.types = strings.FieldsFunc(value, func(r rune) bool { return r == ' ' || r == '|' })
+		case "count":
+			count, err := strconv.Atoi(value)
+			if err != nil {
+				rule.err = fmt.Errorf("invalid count of file %s: %w", name, err)
+			}
+			rule.count = count
+		}
+	}
+	return name, rule
+}
+
+// parseSize parses sizes such as 512, 100KB, 5MB or 1GB
+func parseSize(s string) (int64, error) {
+	units := []struct {
+		suffix string
+		factor int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}}
+
+	s = strings.ToUpper(strings.TrimSpace(s))
+	for _, unit := range units {
+		if n, ok := strings.CutSuffix(s, unit.suffix); ok {
+			size, err := strconv.ParseInt(strings.TrimSpace(n), 10, 64)
+			return size * unit.factor, err
+		}
+	}
+	return strconv.ParseInt(s, 10, 64)
+}
+
+// allows reports whether files of contentType are accepted, e.g. image/png by image/*
+func (rule *fileRule) allows(contentType string) bool {
+	if len(rule.types) == 0 {
+		return true
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	for _, t := range rule.types {
+		if mediaTypeMatches(strings.ToLower(t), mediaType) {
+			return true
+		}
+	}
+	return false
+}
+
+// fileError returns the validation error of a file that breaks a limit
+func fileError(field, rule, param, message string) ValidationErrors {
+	return ValidationErrors{{
+		Field:   field,
+		Path:    field,
+		Rule:    rule,
+		Param:   param,
+		Key:     messageKeyPrefix + rule,
+		Message: message,
+	}}
+}
+
+// ParseMultipart streams a multipart form, binding its values to the form
+// tags of v and storing the files of UploadedFile fields with sink without
+// holding them in memory. File tags may set limits per field:
+//
+//	type AvatarForm struct {
+//	    Name   string                `form:"name"`
+//	    Avatar *httpx.UploadedFile   `file:"avatar,max=2MB,types=image/png image/jpeg"`
+//	    Photos []*httpx.UploadedFile `file:"photos,optional,max=10MB,types=image/*,count=5"`
+//	}
+//
+// Files breaking a limit fail with ValidationErrors with the rule filesize,
+// filetype or filecount, and the files stored so far are removed if sink is
+// a Remover. Parse streams structs with UploadedFile fields to the sink set
+// by SetUploadSink.
+func ParseMultipart(c echo.Context, v any, sink Sink) error {
+	r := c.Request()
+	reader, err := r.MultipartReader()
+	if err != nil {
+		return fmt.Errorf("failed to parse multipart form: %w", err)
+	}
+
+	val, ok := structValue(v)
+	if !ok {
+		return errors.New("multipart target must be a pointer to a struct")
+	}
+	plan := planFor(val.Type())
+
+	files := make(map[string]*fieldPlan)
+	for _, fp := range plan.fields {
+		if fp.file != "" {
+			if fp.upload.err != nil {
+				return fp.upload.err
+			}
+			files[fp.file] = fp
+		}
+	}
+
+	ctx := r.Context()
+	var stored []string
+	cleanup := func() {
+		if remover, ok := sink.(Remover); ok {
+			for _, location := range stored {
+				_ = remover.Remove(ctx, location)
+			}
+		}
+	}
+
+	values := make(url.Values)
+	counts := make(map[string]int)
+	var valueBytes int64
+	for {
+		part, err := reader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			cleanup()
+			return fmt.Errorf("failed to read multipart form: %w", err)
+		}
+
+		name := part.FormName()
+		if part.FileName() == "" {
+			// Form values share the memory limit of buffered forms
+			b, err := io.ReadAll(io.LimitReader(part, maxMemory-valueBytes+1))
+			if err != nil {
+				cleanup()
+				return fmt.Errorf("failed to read form value %s: %w", name, err)
+			}
+			if valueBytes += int64(len(b)); valueBytes > maxMemory {
+				cleanup()
+				return errors.New("failed to parse multipart form: form values too large")
+			}
+			values.Add(name, string(b))
+			continue
+		}
+
+		fp, ok := files[name]
+		if !ok {
+			// Files no field asks for are skipped
+			continue
+		}
+		if !isUploadedFile(fp.typ) {
+			cleanup()
+			return fmt.Errorf("cannot stream file %s into %s", name, fp.typ)
+		}
+
+		counts[name]++
+		if limit := fileCountLimit(fp); counts[name] > limit {
+			cleanup()
+			return fileError(name, "filecount", strconv.Itoa(limit), fmt.Sprintf("must not have more than %d files", limit))
+		}
+
+		file, err := storePart(ctx, sink, fp, part)
+		if file != nil {
+			stored = append(stored, file.Location)
+		}
+		if err != nil {
+			cleanup()
+			return err
+		}
+
+		field, _ := fieldByIndex(val, fp.index)
+		if field.Kind() == reflect.Slice {
+			field.Set(reflect.Append(field, reflect.ValueOf(file)))
+		} else {
+			field.Set(reflect.ValueOf(file))
+		}
+	}
+
+	// Make the values available like a buffered form, Form holds the body
+	// values followed by the query values
+	r.PostForm = values
+	r.Form = make(url.Values, len(values))
+	for k, v := range values {
+		r.Form[k] = append([]string(nil), v...)
+	}
+	for k, v := range r.URL.Query() {
+		r.Form[k] = append(r.Form[k], v...)
+	}
+	r.MultipartForm = &multipart.Form{Value: values}
+
+	if err := bindValues(val, plan, formKey, values, ""); err != nil {
+		cleanup()
+		return err
+	}
+	return nil
+}
+
+// fileCountLimit returns how many files a field takes
+func fileCountLimit(fp *fieldPlan) int {
+	if fp.typ.Kind() != reflect.Slice {
+		return 1
+	}
+	if fp.upload.count > 0 {
+		return fp.upload.count
+	}
+	return maxSliceIndex
+}
+
+// storePart sniffs the content type of a file part, checks it against the
+// limits of fp and streams it to sink
+func storePart(ctx context.Context, sink Sink, fp *fieldPlan, part *multipart.Part) (*UploadedFile, error) {
+	name := part.FormName()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(part, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return nil, fmt.Errorf("failed to read file %s: %w", name, err)
+	}
+	head = head[:n]
+
+	info := FileInfo{
+		Field:       name,
+		Filename:    filepath.Base(part.FileName()),
+		ContentType: http.DetectContentType(head),
+		Header:      part.Header,
+	}
+	if !fp.upload.allows(info.ContentType) {
+		return nil, fileError(name, "filetype", strings.Join(fp.upload.types, " "), "must be of type "+strings.Join(fp.upload.types, ", "))
+	}
+
+	limited := &limitedReader{r: io.MultiReader(bytes.NewReader(head), part), limit: fp.upload.maxSize}
+	location, err := sink.Store(ctx, info, limited)
+	if limited.exceeded {
+		max := strconv.FormatInt(fp.upload.maxSize, 10)
+		return nil, fileError(name, "filesize", max, fmt.Sprintf("must not exceed %s bytes", max))
+	}
+	if err != nil {
+		return nil, &storeError{fmt.Errorf("failed to store file %s: %w", name, err)}
+	}
+
+	return &UploadedFile{
+		FileInfo: info,
+		Size:     limited.read,
+		Location: location,
+	}, nil
+}
+
+// errFileTooLarge is returned to sinks reading past the limit of a file
+var errFileTooLarge = errors.New("file too large")
+
+// limitedReader fails once more than limit bytes are read
+type limitedReader struct {
+	r        io.Reader
+	limit    int64
+	read     int64
+	exceeded bool
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.exceeded {
+		return 0, errFileTooLarge
+	}
+	// Read one byte past the limit to tell a file of exactly the limit from a larger one
+	if max := l.limit - l.read + 1; int64(len(p)) > max {
+		p = p[:max]
+	}
+	n, err := l.r.Read(p)
+	if l.read += int64(n); l.read > l.limit {
+		l.exceeded = true
+		return 0, errFileTooLarge
+	}
+	return n, err
+}
+
+// bindFiles binds the files of a buffered multipart form to the file tags of v
+func bindFiles(form *multipart.Form, val reflect.Value, plan *structPlan) error {
+	for _, fp := range plan.fields {
+		if fp.file == "" {
+			continue
+		}
+		if fp.upload.err != nil {
+			return fp.upload.err
+		}
+
+		headers := form.File[fp.file]
+		if len(headers) == 0 {
+			continue
+		}
+		if limit := fileCountLimit(fp); len(headers) > limit {
+			return fileError(fp.file, "filecount", strconv.Itoa(limit), fmt.Sprintf("must not have more than %d files", limit))
+		}
+		for _, fh := range headers {
+			if err := checkFileHeader(fp, fh); err != nil {
+				return err
+			}
+		}
+
+		field, ok := fieldByIndex(val, fp.index)
+		if !ok {
+			continue
+		}
+		switch {
+		case fp.typ == fileHeaderType:
+			field.Set(reflect.ValueOf(headers[0]))
+		case fp.typ == reflect.SliceOf(fileHeaderType):
+			field.Set(reflect.ValueOf(headers))
+		case isUploadedFile(fp.typ):
+			return &storeError{fmt.Errorf("file %s needs an upload sink, see SetUploadSink", fp.file)}
+		default:
+			// File metadata by field name
+			switch fp.name {
+			case "Filename":
+				field.SetString(headers[0].Filename)
+			case "FileSize":
+				field.SetInt(headers[0].Size)
+			case "ContentType":
+				field.SetString(headers[0].Header.Get("Content-Type"))
+			}
+		}
+	}
+	return nil
+}
+
+// checkFileHeader checks a buffered file against the limits of fp
+func checkFileHeader(fp *fieldPlan, fh *multipart.FileHeader) error {
+	if fh.Size > fp.upload.maxSize {
+		max := strconv.FormatInt(fp.upload.maxSize, 10)
+		return fileError(fp.file, "filesize", max, fmt.Sprintf("must not exceed %s bytes", max))
+	}
+	if len(fp.upload.types) == 0 {
+		return nil
+	}
+
+	f, err := fh.Open()
+	if err != nil {
+		return fmt.Errorf("failed to open file %s: %w", fp.file, err)
+	}
+	defer f.Close()
+
+	head := make([]byte, sniffLen)
+	n, err := io.ReadFull(f, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		return fmt.Errorf("failed to read file %s: %w", fp.file, err)
+	}
+	if !fp.upload.allows(http.DetectContentType(head[:n])) {
+		return fileError(fp.file, "filetype", strings.Join(fp.upload.types, " "), "must be of type "+strings.Join(fp.upload.types, ", "))
+	}
+	return nil
+}
+
+// isUploadedFile reports whether t is *UploadedFile or []*UploadedFile
+func isUploadedFile(t reflect.Type) bool {
+	return t == uploadedFileType || (t.Kind() == reflect.Slice && t.Elem() == uploadedFileType)
+}
+
+// DiskSink stores uploaded files in a directory on the local disk
+type DiskSink struct {
+	Dir string
+}
+
+// NewDiskSink creates a sink storing files in dir, which is created if needed
+func NewDiskSink(dir string) *DiskSink {
+	return &DiskSink{Dir: dir}
+}
+
+// Store implements Sink, files are named by a random UUID and keep their extension
+func (s *DiskSink) Store(ctx context.Context, file FileInfo, r io.Reader) (string, error) {
+	if err := os.MkdirAll(s.Dir, 0o755); err != nil {
+		return "", err
+	}
+
+	location := filepath.Join(s.Dir, uuid.New().String()+strings.ToLower(filepath.Ext(file.Filename)))
+	f, err := os.OpenFile(location, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		f.Close()
+		os.Remove(location)
+		return "", err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(location)
+		return "", err
+	}
+	return location, nil
+}
+
+// Remove implements Remover
+func (s *DiskSink) Remove(ctx context.Context, location string) error {
+	return os.Remove(location)
+}
+
+// ObjectStore is an S3-compatible object storage, such as the storagemanager
+// of generated applications
+type ObjectStore interface {
+	// PutObject uploads the object key, reading its content from r until EOF,
+	// and returns the location of the object, such as its bucket and key
+	PutObject(ctx context.Context, key string, r io.Reader, contentType string) (string, error)
+	// DeleteObject deletes the object at a location returned by PutObject
+	DeleteObject(ctx context.Context, location string) error
+}
+
+// ObjectSink stores uploaded files in an object store under prefix
+type ObjectSink struct {
+	store  ObjectStore
+	prefix string
+}
+
+// NewObjectSink creates a sink uploading files to store, keyed by prefix and a random UUID
+func NewObjectSink(store ObjectStore, prefix string) *ObjectSink {
+	return &ObjectSink{store: store, prefix: prefix}
+}
+
+// Store implements Sink
+func (s *ObjectSink) Store(ctx context.Context, file FileInfo, r io.Reader) (string, error) {
+	key := path.Join(s.prefix, uuid.New().String()+strings.ToLower(filepath.Ext(file.Filename)))
+	return s.store.PutObject(ctx, key, r, file.ContentType)
+}
+
+// Remove implements Remover
+func (s *ObjectSink) Remove(ctx context.Context, location string) error {
+	return s.store.DeleteObject(ctx, location)
+}
@@ -0,0 +1,214 @@
+package httpx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+type formFile struct {
+	field, name string
+	content     []byte
+}
+
+func newMultipartContext(values map[string]string, files ...formFile) echo.Context {
+	var body bytes.Buffer
+	w := multipart.NewWriter(&body)
+	for k, v := range values {
+		_ = w.WriteField(k, v)
+	}
+	for _, f := range files {
+		part, _ := w.CreateFormFile(f.field, f.name)
+		_, _ = part.Write(f.content)
+	}
+	_ = w.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/upload", &body)
+	req.Header.Set(echo.HeaderContentType, w.FormDataContentType())
+	return echo.New().NewContext(req, httptest.NewRecorder())
+}
+
+type BufferedUpload struct {
+	Title    string                  `form:"title"`
+	Avatar   *multipart.FileHeader   `file:"avatar,max=1KB,types=image/png"`
+	Docs     []*multipart.FileHeader `file:"docs,optional,count=2"`
+	Filename string                  `file:"avatar"`
+}
+
+// TestParseFormFileHeaders tests binding buffered files with limits.
+func TestParseFormFileHeaders(t *testing.T) {
+	c := newMultipartContext(map[string]string{"title": "Me"},
+		formFile{"avatar", "me.png", pngHeader},
+		formFile{"docs", "a.txt", []byte("a")},
+		formFile{"docs", "b.txt", []byte("b")},
+	)
+
+	var u BufferedUpload
+	require.NoError(t, Parse(c, &u, "/upload"))
+	assert.Equal(t, "Me", u.Title)
+	require.NotNil(t, u.Avatar)
+	assert.Equal(t, "me.png", u.Avatar.Filename)
+	assert.Equal(t, "me.png", u.Filename)
+	require.Len(t, u.Docs, 2)
+	assert.Equal(t, "b.txt", u.Docs[1].Filename)
+}
+
+// TestParseFormFileLimits tests the size, type and count limits of buffered files.
+func TestParseFormFileLimits(t *testing.T) {
+	tests := []struct {
+		files []formFile
+		rule  string
+		field string
+	}{
+		{[]formFile{{"avatar", "me.png", append(pngHeader, make([]byte, 1024)...)}}, "filesize", "avatar"},
+		{[]formFile{{"avatar", "me.png", []byte("GIF89a")}}, "filetype", "avatar"},
+		{[]formFile{{"avatar", "me.png", pngHeader}, {"docs", "a", nil}, {"docs", "b", nil}, {"docs", "c", nil}}, "filecount", "docs"},
+	}
+	for _, tt := range tests {
+		var u BufferedUpload
+		err := Parse(newMultipartContext(map[string]string{"title": "Me"}, tt.files...), &u, "/upload")
+
+		errs, ok := AsValidationErrors(err)
+		require.True(t, ok, tt.rule)
+		assert.Equal(t, tt.rule, errs[0].Rule)
+		assert.Equal(t, tt.field, errs[0].Field)
+	}
+
+	var u BufferedUpload
+	errs, ok := AsValidationErrors(Parse(newMultipartContext(map[string]string{"title": "Me"}), &u, "/upload"))
+	require.True(t, ok)
+	assert.Equal(t, "is required", errs.Get("avatar"))
+	assert.False(t, errs.Has("docs"))
+}
+
+type StreamedUpload struct {
+	Title  string          `form:"title"`
+	Avatar *UploadedFile   `file:"avatar,max=1KB,types=image/*"`
+	Photos []*UploadedFile `file:"photos,optional,count=2"`
+}
+
+// TestParseMultipartStreamsToDisk tests streaming files to a disk sink.
+func TestParseMultipartStreamsToDisk(t *testing.T) {
+	dir := t.TempDir()
+	SetUploadSink(NewDiskSink(dir))
+	defer SetUploadSink(nil)
+
+	c := newMultipartContext(map[string]string{"title": "Trip"},
+		formFile{"avatar", "Me.PNG", pngHeader},
+		formFile{"photos", "1.txt", []byte("one")},
+		formFile{"photos", "2.txt", []byte("two")},
+		formFile{"unknown", "x.txt", []byte("skipped")},
+	)
+
+	var u StreamedUpload
+	require.NoError(t, Parse(c, &u, "/upload"))
+	assert.Equal(t, "Trip", u.Title)
+	assert.Equal(t, "Trip", c.FormValue("title"))
+
+	require.NotNil(t, u.Avatar)
+	assert.Equal(t, "avatar", u.Avatar.Field)
+	assert.Equal(t, "Me.PNG", u.Avatar.Filename)
+	assert.Equal(t, "image/png", u.Avatar.ContentType)
+	assert.Equal(t, int64(len(pngHeader)), u.Avatar.Size)
+	assert.True(t, strings.HasSuffix(u.Avatar.Location, ".png"))
+
+	require.Len(t, u.Photos, 2)
+	content, err := os.ReadFile(u.Photos[1].Location)
+	require.NoError(t, err)
+	assert.Equal(t, "two", string(content))
+
+	entries, _ := os.ReadDir(dir)
+	assert.Len(t, entries, 3)
+}
+
+// TestParseMultipartForm tests that the query values join the body values in Form only.
+func TestParseMultipartForm(t *testing.T) {
+	c := newMultipartContext(map[string]string{"title": "Trip"})
+	r := c.Request()
+	r.URL.RawQuery = "ref=mail&title=Query"
+
+	var u StreamedUpload
+	require.NoError(t, ParseMultipart(c, &u, NewDiskSink(t.TempDir())))
+	assert.Equal(t, "Trip", u.Title)
+	assert.Equal(t, []string{"Trip", "Query"}, r.Form["title"])
+	assert.Equal(t, "mail", c.FormValue("ref"))
+	assert.Equal(t, []string{"Trip"}, r.PostForm["title"])
+	assert.NotContains(t, r.PostForm, "ref")
+}
+
+// TestParseMultipartRemovesOnFailure tests that stored files are removed when a later file breaks a limit.
+func TestParseMultipartRemovesOnFailure(t *testing.T) {
+	dir := t.TempDir()
+	c := newMultipartContext(nil,
+		formFile{"photos", "1.txt", []byte("one")},
+		formFile{"avatar", "big.png", append(pngHeader, make([]byte, 1024)...)},
+	)
+
+	var u StreamedUpload
+	errs, ok := AsValidationErrors(ParseMultipart(c, &u, NewDiskSink(dir)))
+	require.True(t, ok)
+	assert.Equal(t, "filesize", errs[0].Rule)
+	assert.Equal(t, "1024", errs[0].Param)
+
+	entries, _ := os.ReadDir(dir)
+	assert.Empty(t, entries)
+}
+
+// TestParseMultipartNeedsSink tests that streaming without a sink fails.
+func TestParseMultipartNeedsSink(t *testing.T) {
+	var u StreamedUpload
+	err := Parse(newMultipartContext(nil, formFile{"avatar", "me.png", pngHeader}), &u, "/upload")
+	assert.ErrorContains(t, err, "SetUploadSink")
+}
+
+type memoryStore struct {
+	objects map[string][]byte
+}
+
+func (s *memoryStore) PutObject(ctx context.Context, key string, r io.Reader, contentType string) (string, error) {
+	b, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	s.objects["bucket/"+key] = b
+	return "bucket/" + key, nil
+}
+
+func (s *memoryStore) DeleteObject(ctx context.Context, location string) error {
+	delete(s.objects, location)
+	return nil
+}
+
+// TestObjectSink tests storing files in an object store.
+func TestObjectSink(t *testing.T) {
+	store := &memoryStore{objects: map[string][]byte{}}
+	c := newMultipartContext(map[string]string{"title": "Trip"}, formFile{"avatar", "me.png", pngHeader})
+
+	var u StreamedUpload
+	require.NoError(t, ParseMultipart(c, &u, NewObjectSink(store, "uploads")))
+	assert.True(t, strings.HasPrefix(u.Avatar.Location, "bucket/uploads/"))
+	assert.Equal(t, pngHeader, store.objects[u.Avatar.Location])
+}
+
+// TestParseSize tests human readable file sizes.
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{"512": 512, "1KB": 1024, "5 MB": 5 << 20, "1gb": 1 << 30, "10B": 10} {
+		size, err := parseSize(s)
+		require.NoError(t, err, s)
+		assert.Equal(t, want, size, s)
+	}
+	_, err := parseSize("lots")
+	assert.Error(t, err)
+}
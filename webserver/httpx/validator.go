@@ -1,157 +1,356 @@
 package httpx
 
 import (
-	"errors"
 	"fmt"
 	"reflect"
-	"regexp"
 	"strconv"
 	"strings"
-	"unicode"
+	"sync"
 )
 
 // ValidateStruct validates the struct fields based on the `validate` tag.
+// Nested structs, and the structs in slices, are validated by the `validate`
+// tags of their own fields. Every failing field is reported, the returned
+// error is a ValidationErrors. The rules of a struct type are compiled once
+// and cached, an invalid tag is returned as a plain error.
+//
+// Supported rules, see RegisterRule for custom ones:
+//   - required, omitempty, required_if=Field value
+//   - min=N, max=N, len=N: the length of strings, slices and maps, the value of numbers
+//   - gt, gte, lt, lte=N: numbers, or times compared with a date or now
+//   - eqfield, nefield, gtfield, gtefield, ltfield, ltefield=Field: compare with another field
+//   - oneof=a b c, pattern=regexp, datetime=layout
+//   - email, url, uuid, ip, ipv4, ipv6, cidr, alphanum, creditcard
+//   - dive: the rules after it apply to the elements of a slice or map
+//
+// pattern takes the rest of the tag, so its expression may contain commas.
 func ValidateStruct(v any) error {
-	val := reflect.ValueOf(v).Elem()
-	typ := val.Type()
+	val, ok := structValue(v)
+	if !ok {
+		return nil
+	}
+
+	var errs ValidationErrors
+	if err := validateStruct(val, true, "", "", &errs); err != nil {
+		return err
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validationPlan is the compiled validation of a struct type
+type validationPlan struct {
+	fields []validationField
+	err    error
+}
 
-	for i := 0; i < val.NumField(); i++ {
-		field := val.Field(i)
-		fieldType := typ.Field(i)
-		validateTag := fieldType.Tag.Get("validate")
+// validationField is the compiled validation of a single struct field
+type validationField struct {
+	index  int
+	goName string
+	// name is the name of the field in the request
+	name  string
+	rules *fieldRules
+	// inline marks embedded structs whose fields are bound as if declared inline
+	inline bool
+}
 
-		// Check if the field is optional
-		isOptional := isFieldOptional(fieldType)
+// fieldRules are the compiled rules of a field, or of the elements of a field after dive
+type fieldRules struct {
+	rules []compiledRule
+	// skipEmpty skips the rules other than presence rules for empty values
+	skipEmpty bool
+	dive      *fieldRules
+}
 
+type planKey struct {
+	typ reflect.Type
+	top bool
+}
+
+var validationPlans sync.Map // planKey -> *validationPlan
+
+// validationPlanFor returns the cached validation plan of the struct type t.
+// Only the fields of top-level structs are required by default.
+func validationPlanFor(t reflect.Type, top bool) *validationPlan {
+	key := planKey{typ: t, top: top}
+	if plan, ok := validationPlans.Load(key); ok {
+		return plan.(*validationPlan)
+	}
+	plan, _ := validationPlans.LoadOrStore(key, buildValidationPlan(t, top))
+	return plan.(*validationPlan)
+}
+
+func buildValidationPlan(t reflect.Type, top bool) *validationPlan {
+	plan := &validationPlan{}
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		vf := validationField{index: i, goName: sf.Name, name: requestName(sf)}
+		if sf.Anonymous && !hasNameTag(sf) && isNestedStruct(indirectType(sf.Type)) {
+			vf.inline = true
+			plan.fields = append(plan.fields, vf)
+			continue
+		}
+
+		validateTag := sf.Tag.Get("validate")
 		// If it's not optional and not specified in the path, add required validation
-		if !isOptional && fieldType.Tag.Get("path") == "" {
+		if top && !isFieldOptional(sf) && sf.Tag.Get("path") == "" {
 			validateTag = addRequiredValidation(validateTag)
 		}
 
-		if err := validateField(field, validateTag); err != nil {
-			return fmt.Errorf("field %s: %w", fieldType.Name, err)
+		rules, err := compileRules(splitRules(validateTag), sf.Type, t)
+		if err != nil {
+			plan.err = fmt.Errorf("invalid validate tag on %s.%s: %w", t.Name(), sf.Name, err)
+			return plan
 		}
+		vf.rules = rules
+		plan.fields = append(plan.fields, vf)
 	}
-
-	return nil
+	return plan
 }
 
-// isFieldOptional checks if the field is marked as optional in any of its tags
-func isFieldOptional(fieldType reflect.StructField) bool {
-	tags := []string{"query", "form", "json", "header"}
-	for _, tag := range tags {
-		tagValue := fieldType.Tag.Get(tag)
-		if strings.Contains(tagValue, "optional") {
-			return true
+// splitRules splits a validate tag into its rules
+func splitRules(tag string) []string {
+	if tag == "" {
+		return nil
+	}
+	var rules []string
+	for tag != "" {
+		tag = strings.TrimSpace(tag)
+		if strings.HasPrefix(tag, "pattern=") {
+			// The expression may contain commas
+			return append(rules, tag)
 		}
+		rule, rest, _ := strings.Cut(tag, ",")
+		if rule = strings.TrimSpace(rule); rule != "" {
+			rules = append(rules, rule)
+		}
+		tag = rest
 	}
-	return false
+	return rules
 }
 
-// addRequiredValidation adds the "required" validation if it's not already present
-func addRequiredValidation(validateTag string) string {
-	if validateTag == "" {
-		return "required"
-	}
-	if !strings.Contains(validateTag, "required") {
-		return "required," + validateTag
+// compileRules compiles the rules of a field of type t in the struct type parent
+func compileRules(tags []string, t reflect.Type, parent reflect.Type) (*fieldRules, error) {
+	fr := &fieldRules{}
+	valueType := indirectType(t)
+	for i, tag := range tags {
+		name, param, _ := strings.Cut(tag, "=")
+		switch name {
+		case "dive":
+			switch valueType.Kind() {
+			case reflect.Slice, reflect.Array, reflect.Map:
+			default:
+				return nil, fmt.Errorf("dive on %s", valueType)
+			}
+			dive, err := compileRules(tags[i+1:], valueType.Elem(), parent)
+			if err != nil {
+				return nil, err
+			}
+			fr.dive = dive
+			return fr, nil
+		case "omitempty":
+			fr.skipEmpty = true
+			continue
+		case "required_if":
+			// Not required unless the condition is met
+			fr.skipEmpty = true
+		}
+
+		rule, err := compileRule(name, param, valueType, parent)
+		if err != nil {
+			return nil, err
+		}
+		fr.rules = append(fr.rules, rule)
 	}
-	return validateTag
+	return fr, nil
 }
 
-// validateField validates a single field based on the `validate` tag.
-func validateField(field reflect.Value, tag string) error {
-	tags := strings.Split(tag, ",")
+// validateStruct validates the fields of val, prefixing Go field names with
+// prefix and request-facing names with the bracket notation of parent
+func validateStruct(val reflect.Value, top bool, prefix, parent string, errs *ValidationErrors) error {
+	plan := validationPlanFor(val.Type(), top)
+	if plan.err != nil {
+		return plan.err
+	}
 
-	for _, t := range tags {
-		switch {
-		case t == "required":
-			if isEmpty(field) {
-				return errors.New("is required")
-			}
-		case t == "email":
-			if !isValidEmail(field.String()) {
-				return fmt.Errorf("%s does not validate as email", field.String())
-			}
-		case t == "creditcard":
-			if !isValidCreditCard(field.String()) {
-				return errors.New("invalid credit card number")
-			}
-		case t == "alphanum":
-			if !isAlphanumeric(field.String()) {
-				return errors.New("must be alphanumeric")
-			}
-		case t == "url":
-			if !isValidURL(field.String()) {
-				return errors.New("invalid URL")
+	for _, vf := range plan.fields {
+		field := val.Field(vf.index)
+
+		if vf.inline {
+			if field = deref(field); !field.IsValid() {
+				continue
 			}
-		case strings.HasPrefix(t, "min="):
-			min, err := strconv.Atoi(strings.TrimPrefix(t, "min="))
-			if err != nil {
+			if err := validateStruct(field, top, prefix, parent, errs); err != nil {
 				return err
 			}
-			if len(field.String()) < min {
-				return fmt.Errorf("minimum length is %d", min)
-			}
-		case strings.HasPrefix(t, "max="):
-			max, err := strconv.Atoi(strings.TrimPrefix(t, "max="))
-			if err != nil {
+			continue
+		}
+
+		name := vf.name
+		if parent != "" {
+			name = parent + "[" + name + "]"
+		}
+		if err := validateValue(field, val, vf.rules, name, prefix+vf.goName, errs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validateValue checks v of the struct parent against rules and descends into
+// nested structs and the elements of slices and maps
+func validateValue(v, parent reflect.Value, rules *fieldRules, name, path string, errs *ValidationErrors) error {
+	if !checkRules(v, parent, rules, name, path, errs) {
+		return nil
+	}
+
+	v = deref(v)
+	if !v.IsValid() {
+		return nil
+	}
+
+	var elemRules *fieldRules
+	if rules != nil {
+		elemRules = rules.dive
+	}
+	switch {
+	case isNestedStruct(v.Type()):
+		return validateStruct(v, false, path+".", name, errs)
+	case v.Kind() == reflect.Slice || v.Kind() == reflect.Array:
+		if elemRules == nil && !isNestedStruct(indirectType(v.Type().Elem())) {
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			index := "[" + strconv.Itoa(i) + "]"
+			if err := validateValue(v.Index(i), parent, elemRules, name+index, path+index, errs); err != nil {
 				return err
 			}
-			if len(field.String()) > max {
-				return fmt.Errorf("maximum length is %d", max)
+		}
+	case v.Kind() == reflect.Map:
+		if elemRules == nil && !isNestedStruct(indirectType(v.Type().Elem())) {
+			return nil
+		}
+		iter := v.MapRange()
+		for iter.Next() {
+			index := "[" + fmt.Sprint(iter.Key().Interface()) + "]"
+			if err := validateValue(iter.Value(), parent, elemRules, name+index, path+index, errs); err != nil {
+				return err
 			}
 		}
 	}
-
 	return nil
 }
 
-func isValidEmail(email string) bool {
-	pattern := `^[a-zA-Z0-9._%+-]+@[a-zA-Z0-9.-]+\.[a-zA-Z]{2,}$`
-	match, _ := regexp.MatchString(pattern, email)
-	return match
-}
+// checkRules checks v against rules, recording the first failing rule.
+// Rules other than presence rules are skipped for nil pointers and, if the
+// rules allow it, for empty values. It reports whether v passed.
+func checkRules(v, parent reflect.Value, rules *fieldRules, name, path string, errs *ValidationErrors) bool {
+	if rules == nil {
+		return true
+	}
 
-func isValidCreditCard(number string) bool {
-	// Remove any non-digit characters
-	number = regexp.MustCompile(`\D`).ReplaceAllString(number, "")
+	isNil := v.Kind() == reflect.Ptr && v.IsNil()
+	if v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	skip := isNil || (rules.skipEmpty && isEmpty(v))
 
-	// Check if the number is between 13 and 19 digits
-	if len(number) < 13 || len(number) > 19 {
+	for _, rule := range rules.rules {
+		if skip && !rule.presence {
+			continue
+		}
+		fc := FieldContext{Value: v, Param: rule.param, Parent: parent}
+		if rule.check(fc) {
+			continue
+		}
+		*errs = append(*errs, FieldError{
+			Field:   name,
+			Path:    path,
+			Rule:    rule.name,
+			Param:   rule.param,
+			Key:     messageKeyPrefix + rule.name,
+			Message: strings.ReplaceAll(rule.message, "{value}", fmt.Sprint(valueOf(v))),
+			Value:   valueOf(v),
+		})
 		return false
 	}
+	return true
+}
 
-	// Luhn algorithm
-	sum := 0
-	isEven := false
-	for i := len(number) - 1; i >= 0; i-- {
-		digit := int(number[i] - '0')
-		if isEven {
-			digit *= 2
-			if digit > 9 {
-				digit -= 9
-			}
+// nameTags are the tags that name a field in the request, by precedence
+var nameTags = []string{"form", "file", "query", "json", "path", "header"}
+
+// requestName returns the name a field has in the request, falling back to the Go field name
+func requestName(fieldType reflect.StructField) string {
+	for _, tag := range nameTags {
+		name, _, _ := strings.Cut(fieldType.Tag.Get(tag), ",")
+		if name != "" && name != "-" {
+			return name
 		}
-		sum += digit
-		isEven = !isEven
 	}
-	return sum%10 == 0
+	return fieldType.Name
 }
 
-func isAlphanumeric(s string) bool {
-	for _, r := range s {
-		if !unicode.IsLetter(r) && !unicode.IsNumber(r) {
-			return false
+// hasNameTag reports whether the field is named by any of the name tags
+func hasNameTag(fieldType reflect.StructField) bool {
+	for _, tag := range nameTags {
+		if _, ok := fieldType.Tag.Lookup(tag); ok {
+			return true
 		}
 	}
-	return true
+	return false
+}
+
+// isFieldOptional checks if the field is marked as optional in any of its tags
+func isFieldOptional(fieldType reflect.StructField) bool {
+	tags := []string{"query", "form", "file", "json", "header"}
+	for _, tag := range tags {
+		tagValue := fieldType.Tag.Get(tag)
+		if strings.Contains(tagValue, "optional") {
+			return true
+		}
+	}
+	return false
+}
+
+// addRequiredValidation adds the "required" validation if the field has no
+// presence rule yet. Fields with required_if stay conditionally required.
+func addRequiredValidation(validateTag string) string {
+	if validateTag == "" {
+		return "required"
+	}
+	for _, rule := range splitRules(validateTag) {
+		if name, _, _ := strings.Cut(rule, "="); name == "required" || name == "required_if" {
+			return validateTag
+		}
+	}
+	return "required," + validateTag
 }
 
-func isValidURL(url string) bool {
-	pattern := `^(http|https)://[a-zA-Z0-9\-\.]+\.[a-zA-Z]{2,}(?:/[\w\-\./?%&=]*)?$`
-	match, _ := regexp.MatchString(pattern, url)
-	return match
+// deref returns the value v points to, the zero Value for nil pointers
+func deref(v reflect.Value) reflect.Value {
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return reflect.Value{}
+		}
+		v = v.Elem()
+	}
+	return v
+}
+
+// valueOf returns the value of field for use in messages, nil if it cannot be read
+func valueOf(field reflect.Value) any {
+	if !field.IsValid() || !field.CanInterface() {
+		return nil
+	}
+	return field.Interface()
 }
 
 // isEmpty checks if a value is considered empty.
@@ -167,8 +366,16 @@ func isEmpty(field reflect.Value) bool {
 		return field.Float() == 0
 	case reflect.Bool:
 		return !field.Bool()
-	case reflect.Slice, reflect.Map, reflect.Array, reflect.Chan, reflect.Interface, reflect.Ptr:
+	case reflect.Slice, reflect.Map:
+		return field.Len() == 0
+	case reflect.Chan, reflect.Interface, reflect.Ptr:
 		return field.IsNil()
+	case reflect.Array:
+		// Zero UUIDs are empty
+		return field.IsZero()
+	case reflect.Struct:
+		// Zero times are empty, nested structs are checked by their own fields
+		return !isNestedStruct(field.Type()) && field.IsZero()
 	}
 	return false
 }
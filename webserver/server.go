@@ -32,6 +32,13 @@ func WithMiddleware(middleware ...echo.MiddlewareFunc) ServerOpt {
 	}
 }
 
+// WithErrorHandler sets the handler of errors returned by handlers, see ErrorHandler
+func WithErrorHandler(handler echo.HTTPErrorHandler) ServerOpt {
+	return func(s *Server) {
+		s.Echo.HTTPErrorHandler = handler
+	}
+}
+
 func MustNewServer(c WebServerConf, opts ...ServerOpt) *Server {
 	// Create a new Echo instance
 	e := echo.New()
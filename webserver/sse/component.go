@@ -0,0 +1,103 @@
+package sse
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/a-h/templ"
+	"github.com/templwind/soul/htmx"
+)
+
+// ComponentMessage renders c with ctx into a message for the htmx SSE
+// extension. The message is swapped by elements with sse-swap set to event.
+func ComponentMessage(ctx context.Context, event string, c templ.Component) (Message, error) {
+	var sb strings.Builder
+	if err := c.Render(ctx, &sb); err != nil {
+		return Message{}, fmt.Errorf("failed to render component: %w", err)
+	}
+	return Message{Event: event, Data: sb.String()}, nil
+}
+
+// Swap is an out-of-band swap of a component into the element matched by Target
+//
+// Deprecated: build the swaps with htmx.OOB and send its Component with
+// SendComponent or SendComponentToPath.
+type Swap struct {
+	// Target is the CSS selector of the element to update, e.g. "#notifications".
+	// Leave it empty for a component that sets hx-swap-oob on its own root.
+	Target string
+	// Strategy is the htmx swap strategy, defaults to "innerHTML". An
+	// outerHTML swap marks the root element of Component, so it replaces the
+	// target.
+	Strategy string
+	// Component is rendered into the target
+	Component templ.Component
+}
+
+// OOBMessage renders swaps with a background context into a single message
+// whose fragments htmx swaps out-of-band, so one event updates several
+// targets at once
+//
+// Deprecated: build the swaps with htmx.OOB and render its Component with
+// ComponentMessage:
+//
+//	msg, err := sse.ComponentMessage(ctx, "update", htmx.NewOOB(nil).
+//	    Add("count", htmx.SwapInnerHTML, view.Count(n)).
+//	    AddTarget("ul.items", htmx.SwapBeforeEnd, view.Item(item)).
+//	    Component())
+func OOBMessage(event string, swaps ...Swap) (Message, error) {
+	oob := htmx.NewOOB(nil)
+	for _, swap := range swaps {
+		strategy := swap.Strategy
+		if strategy == "" {
+			strategy = string(htmx.SwapInnerHTML)
+		}
+		oob.AddTarget(swap.Target, htmx.Swap(strategy), swap.Component)
+	}
+	return ComponentMessage(context.Background(), event, oob.Component())
+}
+
+// SendComponent renders c and sends it as a named event to a specific client on a specific path
+func SendComponent(ctx context.Context, path string, clientID int64, event string, c templ.Component) error {
+	msg, err := ComponentMessage(ctx, event, c)
+	if err != nil {
+		return err
+	}
+	Send(path, clientID, msg)
+	return nil
+}
+
+// SendComponentToPath renders c and sends it as a named event to all clients on a specific path
+func SendComponentToPath(ctx context.Context, path string, event string, c templ.Component) error {
+	msg, err := ComponentMessage(ctx, event, c)
+	if err != nil {
+		return err
+	}
+	SendToPath(path, msg)
+	return nil
+}
+
+// SendOOB sends swaps as a single named event to a specific client on a specific path
+//
+// Deprecated: send the Component of an htmx.OOB with SendComponent.
+func SendOOB(path string, clientID int64, event string, swaps ...Swap) error {
+	msg, err := OOBMessage(event, swaps...)
+	if err != nil {
+		return err
+	}
+	Send(path, clientID, msg)
+	return nil
+}
+
+// SendOOBToPath sends swaps as a single named event to all clients on a specific path
+//
+// Deprecated: send the Component of an htmx.OOB with SendComponentToPath.
+func SendOOBToPath(path string, event string, swaps ...Swap) error {
+	msg, err := OOBMessage(event, swaps...)
+	if err != nil {
+		return err
+	}
+	SendToPath(path, msg)
+	return nil
+}
@@ -0,0 +1,70 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/a-h/templ"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/htmx"
+)
+
+// TestSendComponent tests that a rendered component reaches the client as a multi-line named event.
+func TestSendComponent(t *testing.T) {
+	hub := NewEventHub()
+	SetDefault(hub)
+	defer SetDefault(nil)
+
+	sub := hub.Subscribe("/feed", 1)
+	require.NoError(t, SendComponent(context.Background(), "/feed", 1, "row", templ.Raw("<tr>\n<td>1</td>\n</tr>")))
+
+	var buf bytes.Buffer
+	require.NoError(t, WriteMessage(&buf, <-sub.C))
+	assert.Equal(t, "id: "+hub.id+"-1\nevent: row\ndata: <tr>\ndata: <td>1</td>\ndata: </tr>\n\n", buf.String())
+}
+
+type localeKey struct{}
+
+// TestComponentMessageContext tests that the component is rendered with the given context.
+func TestComponentMessageContext(t *testing.T) {
+	ctx := context.WithValue(context.Background(), localeKey{}, "de")
+	c := templ.ComponentFunc(func(ctx context.Context, w io.Writer) error {
+		_, err := io.WriteString(w, ctx.Value(localeKey{}).(string))
+		return err
+	})
+
+	msg, err := ComponentMessage(ctx, "locale", c)
+	require.NoError(t, err)
+	assert.Equal(t, "de", msg.Data)
+}
+
+// TestOOBComponentMessage tests that an htmx.OOB is sent as a single event.
+func TestOOBComponentMessage(t *testing.T) {
+	msg, err := ComponentMessage(context.Background(), "update", htmx.NewOOB(nil).
+		Add("count", htmx.SwapInnerHTML, templ.Raw("3")).
+		Add("status", htmx.SwapOuterHTML, templ.Raw(`<p class="ok">ok</p>`)).
+		Component())
+
+	require.NoError(t, err)
+	assert.Equal(t, "update", msg.Event)
+	assert.Equal(t, `<div hx-swap-oob="innerHTML:#count">3</div><p hx-swap-oob="outerHTML:#status" class="ok">ok</p>`, msg.Data)
+
+	_, err = ComponentMessage(context.Background(), "update", htmx.NewOOB(nil).Add("status", htmx.SwapOuterHTML, nil).Component())
+	assert.Error(t, err)
+}
+
+// TestOOBMessage tests that every swap is wrapped for an out-of-band swap.
+func TestOOBMessage(t *testing.T) {
+	msg, err := OOBMessage("update",
+		Swap{Target: "#count", Component: templ.Raw("3")},
+		Swap{Target: `ul[data-id="7"]`, Strategy: "beforeend", Component: templ.Raw("<li>7</li>")},
+		Swap{Component: templ.Raw(`<p id="status" hx-swap-oob="true">ok</p>`)},
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, "update", msg.Event)
+	assert.Equal(t, `<div hx-swap-oob="innerHTML:#count">3</div><div hx-swap-oob="beforeend:ul[data-id=&#34;7&#34;]"><li>7</li></div><p id="status" hx-swap-oob="true">ok</p>`, msg.Data)
+}
@@ -0,0 +1,190 @@
+package sse
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HandlerOption customizes the stream served by Serve and Handler
+type HandlerOption func(opts *handlerOptions)
+
+type handlerOptions struct {
+	heartbeat    time.Duration
+	retry        time.Duration
+	initialEvent interface{}
+}
+
+// WithHeartbeat sets the interval of the keepalive comments that stop proxies from closing idle streams
+func WithHeartbeat(interval time.Duration) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.heartbeat = interval
+	}
+}
+
+// WithRetry sets the reconnection delay the browser should use after the stream drops
+func WithRetry(retry time.Duration) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.retry = retry
+	}
+}
+
+// WithInitialEvent sends data as the first event of every new stream
+func WithInitialEvent(data interface{}) HandlerOption {
+	return func(opts *handlerOptions) {
+		opts.initialEvent = data
+	}
+}
+
+// IdentifyFunc returns the path and client a request subscribes to
+type IdentifyFunc func(c echo.Context) (path string, clientID int64, err error)
+
+// Handler returns an echo handler that streams the events of hub to the client
+// identified by identify
+func Handler(hub *EventHub, identify IdentifyFunc, opts ...HandlerOption) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		path, clientID, err := identify(c)
+		if err != nil {
+			return err
+		}
+		return Serve(c, hub, path, clientID, opts...)
+	}
+}
+
+// Serve streams the events of hub for path and clientID until the client
+// disconnects. Events missed since the Last-Event-ID sent by a reconnecting
+// browser are replayed first.
+func Serve(c echo.Context, hub *EventHub, path string, clientID int64, opts ...HandlerOption) error {
+	o := handlerOptions{
+		heartbeat: 30 * time.Second,
+		retry:     3 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&o)
+	}
+
+	res := c.Response()
+	if _, ok := res.Writer.(http.Flusher); !ok {
+		return echo.NewHTTPError(http.StatusInternalServerError, "streaming not supported")
+	}
+
+	// Subscribe before replaying so no event falls in between. Every
+	// connection gets its own subscription, so several tabs of a client work.
+	sub := hub.Subscribe(path, clientID)
+	defer hub.Unsubscribe(sub)
+
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.Header().Set("X-Accel-Buffering", "no")
+	res.WriteHeader(http.StatusOK)
+
+	if o.retry > 0 {
+		if _, err := fmt.Fprintf(res, "retry: %d\n\n", o.retry.Milliseconds()); err != nil {
+			return nil
+		}
+	}
+
+	if o.initialEvent != nil {
+		if err := WriteMessage(res, Message{Data: o.initialEvent}); err != nil {
+			return nil
+		}
+	}
+
+	var lastSent uint64
+	for _, msg := range hub.Replay(path, clientID, c.Request().Header.Get("Last-Event-ID")) {
+		if err := WriteMessage(res, msg); err != nil {
+			return nil
+		}
+		lastSent, _ = hub.sequence(msg.ID)
+	}
+	res.Flush()
+
+	ticker := time.NewTicker(o.heartbeat)
+	defer ticker.Stop()
+
+	ctx := c.Request().Context()
+	for {
+		select {
+		case <-ctx.Done():
+			// Client disconnected
+			return nil
+		case msg, ok := <-sub.C:
+			if !ok {
+				// The subscription was removed from the hub
+				return nil
+			}
+			if seq, ok := hub.sequence(msg.ID); ok && seq <= lastSent {
+				// Already sent during replay
+				continue
+			}
+			if err := WriteMessage(res, msg); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-ticker.C:
+			if _, err := io.WriteString(res, ": keepalive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		}
+	}
+}
+
+// WriteMessage writes msg to w in text/event-stream framing
+func WriteMessage(w io.Writer, msg Message) error {
+	data, err := encodeData(msg.Data)
+	if err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	if msg.ID != "" {
+		fmt.Fprintf(&buf, "id: %s\n", sanitizeField(msg.ID))
+	}
+	if msg.Event != "" {
+		fmt.Fprintf(&buf, "event: %s\n", sanitizeField(msg.Event))
+	}
+
+	// Every line of the payload needs its own data field
+	data = strings.ReplaceAll(data, "\r\n", "\n")
+	data = strings.ReplaceAll(data, "\r", "\n")
+	for _, line := range strings.Split(data, "\n") {
+		buf.WriteString("data: ")
+		buf.WriteString(line)
+		buf.WriteByte('\n')
+	}
+	buf.WriteByte('\n')
+
+	_, err = buf.WriteTo(w)
+	return err
+}
+
+// encodeData converts an event payload to text
+func encodeData(data interface{}) (string, error) {
+	switch v := data.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal event data: %w", err)
+		}
+		return string(b), nil
+	}
+}
+
+// sanitizeField strips line breaks that would end an id or event field early
+func sanitizeField(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
@@ -0,0 +1,62 @@
+package sse
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestWriteMessage tests the framing of multiline payloads.
+func TestWriteMessage(t *testing.T) {
+	var buf bytes.Buffer
+	err := WriteMessage(&buf, Message{ID: "7", Event: "update", Data: "<div>\n  hi\r\n</div>"})
+
+	assert.NoError(t, err)
+	assert.Equal(t, "id: 7\nevent: update\ndata: <div>\ndata:   hi\ndata: </div>\n\n", buf.String())
+}
+
+// TestServeReplaysMissedEvents tests that a reconnecting client receives the events after Last-Event-ID.
+func TestServeReplaysMissedEvents(t *testing.T) {
+	hub := NewEventHub()
+	hub.BroadcastPath("/feed", "first")
+	hub.Broadcast("/feed", 1, "second")
+	hub.Broadcast("/feed", 2, "not for client 1")
+	hub.BroadcastPath("/feed", map[string]int{"third": 3})
+
+	e := echo.New()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	req := httptest.NewRequest(http.MethodGet, "/feed", nil).WithContext(ctx)
+	req.Header.Set("Last-Event-ID", hub.id+"-1")
+	rec := httptest.NewRecorder()
+
+	err := Serve(e.NewContext(req, rec), hub, "/feed", 1, WithRetry(0))
+
+	assert.NoError(t, err)
+	assert.Equal(t, "text/event-stream", rec.Header().Get(echo.HeaderContentType))
+	body := rec.Body.String()
+	assert.False(t, strings.Contains(body, "first"))
+	assert.False(t, strings.Contains(body, "not for client 1"))
+	assert.Equal(t, "id: "+hub.id+"-2\ndata: second\n\nid: "+hub.id+"-4\ndata: {\"third\":3}\n\n", body)
+}
+
+// TestReplayOtherInstance tests that event ids of other instances are not replayed.
+func TestReplayOtherInstance(t *testing.T) {
+	hub := NewEventHub()
+	other := NewEventHub()
+	hub.BroadcastPath("/feed", "first")
+	hub.BroadcastPath("/feed", "second")
+	other.BroadcastPath("/feed", "elsewhere")
+
+	assert.Len(t, hub.Replay("/feed", 1, hub.id+"-1"), 1)
+	assert.Empty(t, hub.Replay("/feed", 1, other.id+"-1"), "sequences of other instances are unrelated")
+	assert.Empty(t, hub.Replay("/feed", 1, "1"))
+	assert.Empty(t, hub.Replay("/feed", 1, ""))
+}
@@ -1,98 +1,451 @@
 package sse
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
+	"log"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/templwind/soul/pubsub"
 )
 
+// Message is an event as delivered to a subscriber
+type Message struct {
+	// ID is the SSE event id, browsers send the last one back in Last-Event-ID
+	// when reconnecting. The hub sets it to <instance>-<seq>, so ids of other
+	// instances are never mistaken for its own.
+	ID string
+	// Event is the SSE event name matched by htmx sse-swap, empty for the default "message" event
+	Event string
+	// Data is the payload, strings and byte slices are written as-is, other values as JSON
+	Data interface{}
+}
+
+// HubOption customizes an EventHub
+type HubOption func(hub *EventHub)
+
+// WithReplayBuffer sets how many events per path are kept for replay to reconnecting clients
+func WithReplayBuffer(size int) HubOption {
+	return func(hub *EventHub) {
+		hub.replaySize = size
+	}
+}
+
+// WithReplayWindow sets how long the events of a path without subscribers
+// are kept for replay, zero keeps them until the hub is discarded
+func WithReplayWindow(d time.Duration) HubOption {
+	return func(hub *EventHub) {
+		hub.replayWindow = d
+	}
+}
+
+// WithBufferSize sets how many undelivered events a connection may queue before events are dropped
+func WithBufferSize(size int) HubOption {
+	return func(hub *EventHub) {
+		hub.bufferSize = size
+	}
+}
+
+// WithBroker fans broadcasts out through broker on subject, so clients
+// connected to other instances receive them too. Call Start to receive the
+// broadcasts of other instances.
+func WithBroker(broker pubsub.Broker, subject string) HubOption {
+	return func(hub *EventHub) {
+		hub.broker = broker
+		hub.subject = subject
+	}
+}
+
+// Subscription is a single connection of a client to a path. A client may
+// hold several subscriptions at once, e.g. one per browser tab.
+type Subscription struct {
+	ID       uint64
+	Path     string
+	ClientID int64
+	// C receives the events of the subscription, it is closed on Unsubscribe
+	C <-chan Message
+
+	ch      chan Message
+	dropped atomic.Uint64
+}
+
+// Dropped returns the number of events dropped because the subscription fell behind
+func (sub *Subscription) Dropped() uint64 {
+	return sub.dropped.Load()
+}
+
+// Stats is a snapshot of the delivery counters of an EventHub
+type Stats struct {
+	Subscriptions int
+	Delivered     uint64
+	Dropped       uint64
+}
+
 // EventHub manages per-path and per-client event delivery
 type EventHub struct {
-	mu          sync.RWMutex
-	subscribers map[string]map[int64]chan interface{}
+	mu         sync.RWMutex
+	nextID     uint64
+	byPath     map[string]map[uint64]*Subscription
+	byClient   map[int64]map[uint64]*Subscription
+	seq        uint64
+	replay     map[string]*ring
+	replaySize int
+	// replayWindow is how long the ring of a path without subscribers outlives
+	// its last event, rings are swept at most once per window
+	replayWindow time.Duration
+	lastSweep    time.Time
+	bufferSize   int
+	delivered    atomic.Uint64
+	dropped      atomic.Uint64
+
+	id      string
+	broker  pubsub.Broker
+	subject string
+}
+
+// fanout is a broadcast as published to the other instances
+type fanout struct {
+	Origin   string `json:"origin"`
+	Path     string `json:"path,omitempty"`
+	ClientID *int64 `json:"clientId,omitempty"`
+	Event    string `json:"event,omitempty"`
+	Data     string `json:"data"`
 }
 
 // NewEventHub initializes a new EventHub
-func NewEventHub() *EventHub {
-	return &EventHub{
-		subscribers: make(map[string]map[int64]chan interface{}),
+func NewEventHub(opts ...HubOption) *EventHub {
+	hub := &EventHub{
+		byPath:       make(map[string]map[uint64]*Subscription),
+		byClient:     make(map[int64]map[uint64]*Subscription),
+		replay:       make(map[string]*ring),
+		replaySize:   100,
+		replayWindow: 5 * time.Minute,
+		bufferSize:   10,
+		id:           uuid.New().String(),
+	}
+	for _, opt := range opts {
+		opt(hub)
+	}
+	return hub
+}
+
+// Start receives the broadcasts of other instances from the broker until ctx
+// is done. It does nothing if the hub has no broker.
+func (hub *EventHub) Start(ctx context.Context) error {
+	if hub.broker == nil {
+		return nil
+	}
+
+	// An empty group delivers every broadcast to every instance
+	_, err := hub.broker.Subscribe(ctx, hub.subject, "", func(ctx context.Context, msg *pubsub.Message) error {
+		var f fanout
+		if err := json.Unmarshal(msg.Data, &f); err != nil {
+			return fmt.Errorf("failed to unmarshal broadcast: %w", err)
+		}
+		if f.Origin == hub.id {
+			// Already delivered locally
+			return nil
+		}
+		hub.dispatch(f.Path, f.ClientID, Message{Event: f.Event, Data: f.Data})
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", hub.subject, err)
 	}
+	return nil
 }
 
-// Subscribe adds a new subscriber for a specific path and client
-func (hub *EventHub) Subscribe(path string, clientID int64) chan interface{} {
+// Subscribe adds a new subscription for a specific path and client
+func (hub *EventHub) Subscribe(path string, clientID int64) *Subscription {
 	hub.mu.Lock()
 	defer hub.mu.Unlock()
 
-	if _, ok := hub.subscribers[path]; !ok {
-		hub.subscribers[path] = make(map[int64]chan interface{})
+	hub.nextID++
+	ch := make(chan Message, hub.bufferSize) // Buffered channel for async delivery
+	sub := &Subscription{
+		ID:       hub.nextID,
+		Path:     path,
+		ClientID: clientID,
+		C:        ch,
+		ch:       ch,
+	}
+
+	if _, ok := hub.byPath[path]; !ok {
+		hub.byPath[path] = make(map[uint64]*Subscription)
 	}
+	hub.byPath[path][sub.ID] = sub
 
-	ch := make(chan interface{}, 10) // Buffered channel for async delivery
-	hub.subscribers[path][clientID] = ch
-	return ch
+	if _, ok := hub.byClient[clientID]; !ok {
+		hub.byClient[clientID] = make(map[uint64]*Subscription)
+	}
+	hub.byClient[clientID][sub.ID] = sub
+
+	return sub
 }
 
-// Unsubscribe removes a subscriber for a specific path and client
-func (hub *EventHub) Unsubscribe(path string, clientID int64) {
+// Unsubscribe removes a subscription and closes its channel
+func (hub *EventHub) Unsubscribe(sub *Subscription) {
 	hub.mu.Lock()
 	defer hub.mu.Unlock()
 
-	if clients, ok := hub.subscribers[path]; ok {
-		if ch, ok := clients[clientID]; ok {
-			close(ch)
-			delete(clients, clientID)
-		}
-		// Clean up the path if no more subscribers exist
+	subs, ok := hub.byPath[sub.Path]
+	if !ok {
+		return
+	}
+	if _, ok := subs[sub.ID]; !ok {
+		return
+	}
+
+	close(sub.ch)
+	delete(subs, sub.ID)
+	// Clean up the indices if no more subscriptions exist
+	if len(subs) == 0 {
+		delete(hub.byPath, sub.Path)
+	}
+	if clients := hub.byClient[sub.ClientID]; clients != nil {
+		delete(clients, sub.ID)
 		if len(clients) == 0 {
-			delete(hub.subscribers, path)
+			delete(hub.byClient, sub.ClientID)
 		}
 	}
 }
 
-// Broadcast sends an event to a specific client on a specific path
-func (hub *EventHub) Broadcast(path string, clientID int64, event interface{}) {
+// Connections returns the number of open subscriptions of a client
+func (hub *EventHub) Connections(clientID int64) int {
 	hub.mu.RLock()
 	defer hub.mu.RUnlock()
+	return len(hub.byClient[clientID])
+}
 
-	if clients, ok := hub.subscribers[path]; ok {
-		if ch, ok := clients[clientID]; ok {
-			select {
-			case ch <- event:
-			default: // Drop event if the channel is full
-				fmt.Printf("Dropping event for client %d on path %s: channel full\n", clientID, path)
-			}
-		}
+// Stats returns the current delivery counters
+func (hub *EventHub) Stats() Stats {
+	hub.mu.RLock()
+	subscriptions := 0
+	for _, subs := range hub.byPath {
+		subscriptions += len(subs)
 	}
+	hub.mu.RUnlock()
+
+	return Stats{
+		Subscriptions: subscriptions,
+		Delivered:     hub.delivered.Load(),
+		Dropped:       hub.dropped.Load(),
+	}
+}
+
+// Broadcast sends an event to every connection of a specific client on a specific path
+func (hub *EventHub) Broadcast(path string, clientID int64, event interface{}) {
+	hub.publish(path, &clientID, event)
 }
 
 // BroadcastPath sends an event to all clients subscribed to a specific path
 func (hub *EventHub) BroadcastPath(path string, event interface{}) {
-	hub.mu.RLock()
-	defer hub.mu.RUnlock()
+	hub.publish(path, nil, event)
+}
+
+// BroadcastClient sends an event to every connection of a specific client across all paths
+func (hub *EventHub) BroadcastClient(clientID int64, event interface{}) {
+	hub.publish("", &clientID, event)
+}
 
-	if clients, ok := hub.subscribers[path]; ok {
-		for clientID, ch := range clients {
-			select {
-			case ch <- event:
-			default: // Drop event if the channel is full
-				fmt.Printf("Dropping event for client %d on path %s: channel full\n", clientID, path)
+// BroadcastAll sends an event to all clients across all paths
+func (hub *EventHub) BroadcastAll(event interface{}) {
+	hub.publish("", nil, event)
+}
+
+// publish delivers event locally and fans it out to the other instances. An
+// empty path addresses all paths, a nil clientID all clients.
+func (hub *EventHub) publish(path string, clientID *int64, event interface{}) {
+	msg, ok := event.(Message)
+	if !ok {
+		msg = Message{Data: event}
+	}
+	hub.dispatch(path, clientID, msg)
+
+	if hub.broker == nil {
+		return
+	}
+	if err := hub.fanOut(path, clientID, msg); err != nil {
+		log.Printf("sse: failed to fan out event: %v", err)
+	}
+}
+
+func (hub *EventHub) fanOut(path string, clientID *int64, msg Message) error {
+	data, err := encodeData(msg.Data)
+	if err != nil {
+		return err
+	}
+	payload, err := json.Marshal(fanout{
+		Origin:   hub.id,
+		Path:     path,
+		ClientID: clientID,
+		Event:    msg.Event,
+		Data:     data,
+	})
+	if err != nil {
+		return err
+	}
+	return hub.broker.Publish(context.Background(), hub.subject, payload)
+}
+
+// dispatch records msg for replay and delivers it to the matching local subscriptions
+func (hub *EventHub) dispatch(path string, clientID *int64, msg Message) {
+	hub.mu.Lock()
+	defer hub.mu.Unlock()
+
+	hub.seq++
+	msg.ID = hub.id + "-" + strconv.FormatUint(hub.seq, 10)
+
+	var subs map[uint64]*Subscription
+	switch {
+	case clientID != nil:
+		subs = hub.byClient[*clientID]
+	case path != "":
+		subs = hub.byPath[path]
+	}
+
+	if path != "" {
+		hub.record(path, clientID, msg)
+		for _, sub := range subs {
+			if sub.Path == path {
+				hub.deliver(sub, msg)
 			}
 		}
+		return
+	}
+
+	// Record once for every path that is reached
+	recorded := make(map[string]bool)
+	each := func(sub *Subscription) {
+		if !recorded[sub.Path] {
+			recorded[sub.Path] = true
+			hub.record(sub.Path, clientID, msg)
+		}
+		hub.deliver(sub, msg)
+	}
+	if clientID != nil {
+		for _, sub := range subs {
+			each(sub)
+		}
+		return
+	}
+	for _, subs := range hub.byPath {
+		for _, sub := range subs {
+			each(sub)
+		}
 	}
 }
 
-// BroadcastAll sends an event to all clients across all paths
-func (hub *EventHub) BroadcastAll(event interface{}) {
+// Replay returns the buffered events of path addressed to clientID that were
+// sent after the event with id lastEventID, oldest first. Nothing is replayed
+// for ids of other instances, e.g. after a client reconnected to another pod.
+func (hub *EventHub) Replay(path string, clientID int64, lastEventID string) []Message {
+	last, ok := hub.sequence(lastEventID)
+	if !ok {
+		return nil
+	}
+
 	hub.mu.RLock()
 	defer hub.mu.RUnlock()
 
-	for path, clients := range hub.subscribers {
-		for clientID, ch := range clients {
-			select {
-			case ch <- event:
-			default: // Drop event if the channel is full
-				fmt.Printf("Dropping event for client %d on path %s: channel full\n", clientID, path)
-			}
+	r, ok := hub.replay[path]
+	if !ok {
+		return nil
+	}
+	return r.since(last, clientID)
+}
+
+// sequence returns the sequence number of an event id of this hub
+func (hub *EventHub) sequence(id string) (uint64, bool) {
+	origin, seq, ok := strings.Cut(id, hub.id+"-")
+	if !ok || origin != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseUint(seq, 10, 64)
+	return n, err == nil
+}
+
+// record keeps msg for replay. A nil clientID addresses every client of the
+// path. hub.mu must be held.
+func (hub *EventHub) record(path string, clientID *int64, msg Message) {
+	if hub.replaySize <= 0 {
+		return
+	}
+	now := time.Now()
+	hub.sweep(now)
+	r, ok := hub.replay[path]
+	if !ok {
+		r = &ring{entries: make([]entry, 0, hub.replaySize)}
+		hub.replay[path] = r
+	}
+	r.add(entry{seq: hub.seq, clientID: clientID, msg: msg})
+	r.last = now
+}
+
+// sweep drops the rings of paths that have no subscribers and no events
+// within the replay window, so per-user paths don't pile up. hub.mu must be
+// held.
+func (hub *EventHub) sweep(now time.Time) {
+	if hub.replayWindow <= 0 || now.Sub(hub.lastSweep) < hub.replayWindow {
+		return
+	}
+	hub.lastSweep = now
+	for path, r := range hub.replay {
+		if len(hub.byPath[path]) == 0 && now.Sub(r.last) > hub.replayWindow {
+			delete(hub.replay, path)
+		}
+	}
+}
+
+// deliver sends msg to sub without blocking, counting the event as dropped if the subscription fell behind
+func (hub *EventHub) deliver(sub *Subscription, msg Message) {
+	select {
+	case sub.ch <- msg:
+		hub.delivered.Add(1)
+	default:
+		sub.dropped.Add(1)
+		hub.dropped.Add(1)
+	}
+}
+
+// entry is a buffered event
+type entry struct {
+	seq      uint64
+	clientID *int64
+	msg      Message
+}
+
+// ring is a fixed size buffer of the most recent events of a path
+type ring struct {
+	entries []entry
+	start   int
+	// last is when the latest event was added
+	last time.Time
+}
+
+func (r *ring) add(e entry) {
+	if len(r.entries) < cap(r.entries) {
+		r.entries = append(r.entries, e)
+		return
+	}
+	r.entries[r.start] = e
+	r.start = (r.start + 1) % len(r.entries)
+}
+
+// since returns the entries after seq addressed to clientID, oldest first
+func (r *ring) since(seq uint64, clientID int64) []Message {
+	var msgs []Message
+	for i := range r.entries {
+		e := r.entries[(r.start+i)%len(r.entries)]
+		if e.seq > seq && (e.clientID == nil || *e.clientID == clientID) {
+			msgs = append(msgs, e.msg)
 		}
 	}
+	return msgs
 }
@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/pubsub"
+)
+
+// TestHubMultipleTabs tests that every connection of a client receives its events.
+func TestHubMultipleTabs(t *testing.T) {
+	hub := NewEventHub()
+	tab1 := hub.Subscribe("/feed", 1)
+	tab2 := hub.Subscribe("/feed", 1)
+	other := hub.Subscribe("/other", 1)
+	assert.Equal(t, 3, hub.Connections(1))
+
+	hub.Broadcast("/feed", 1, Message{Event: "update", Data: "hi"})
+
+	for _, sub := range []*Subscription{tab1, tab2} {
+		msg := <-sub.C
+		assert.Equal(t, "update", msg.Event)
+		assert.Equal(t, "hi", msg.Data)
+	}
+	assert.Len(t, other.C, 0)
+
+	hub.BroadcastClient(1, "all tabs")
+	for _, sub := range []*Subscription{tab1, tab2, other} {
+		assert.Equal(t, "all tabs", (<-sub.C).Data)
+	}
+
+	hub.Unsubscribe(tab1)
+	hub.Unsubscribe(tab1)
+	_, ok := <-tab1.C
+	assert.False(t, ok)
+	assert.Equal(t, 2, hub.Connections(1))
+}
+
+// TestHubDropCounters tests that events for slow subscriptions are counted as dropped.
+func TestHubDropCounters(t *testing.T) {
+	hub := NewEventHub(WithBufferSize(1))
+	sub := hub.Subscribe("/feed", 1)
+
+	hub.BroadcastPath("/feed", "first")
+	hub.BroadcastPath("/feed", "second")
+
+	assert.Equal(t, uint64(1), sub.Dropped())
+	assert.Equal(t, Stats{Subscriptions: 1, Delivered: 1, Dropped: 1}, hub.Stats())
+}
+
+// TestHubEvictsIdleReplay tests that the replay of a path without subscribers is dropped after the replay window.
+func TestHubEvictsIdleReplay(t *testing.T) {
+	hub := NewEventHub(WithReplayWindow(10 * time.Millisecond))
+	sub := hub.Subscribe("/feed", 1)
+
+	hub.BroadcastPath("/feed", "kept")
+	hub.Broadcast("/users/1", 1, "idle")
+	time.Sleep(20 * time.Millisecond)
+	hub.BroadcastPath("/other", "sweep")
+
+	hub.mu.RLock()
+	defer hub.mu.RUnlock()
+	assert.Contains(t, hub.replay, "/feed", "paths with subscribers keep their replay")
+	assert.NotContains(t, hub.replay, "/users/1")
+	assert.Contains(t, hub.replay, "/other")
+	assert.Len(t, sub.C, 1)
+}
+
+// TestHubBrokerFanOut tests that broadcasts reach the clients of other instances exactly once.
+func TestHubBrokerFanOut(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := pubsub.NewMemoryBroker()
+	defer broker.Close()
+
+	hub1 := NewEventHub(WithBroker(broker, "sse"))
+	hub2 := NewEventHub(WithBroker(broker, "sse"))
+	require.NoError(t, hub1.Start(ctx))
+	require.NoError(t, hub2.Start(ctx))
+
+	local := hub1.Subscribe("/feed", 1)
+	remote := hub2.Subscribe("/feed", 2)
+
+	hub1.BroadcastPath("/feed", Message{Event: "update", Data: map[string]int{"n": 1}})
+
+	select {
+	case msg := <-remote.C:
+		assert.Equal(t, "update", msg.Event)
+		assert.Equal(t, `{"n":1}`, msg.Data)
+	case <-time.After(time.Second):
+		t.Fatal("broadcast did not reach the other instance")
+	}
+
+	assert.Equal(t, map[string]int{"n": 1}, (<-local.C).Data)
+	time.Sleep(20 * time.Millisecond)
+	assert.Len(t, local.C, 0)
+}
@@ -1,6 +1,7 @@
 package sse
 
 import (
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
@@ -89,14 +90,35 @@ type AlertEvent struct {
 	Time       time.Time `json:"time"`
 }
 
-// EventHubInstance is the central hub for managing connections and events.
-// Ensure that this is initialized and injected by the application.
-var EventHubInstance *EventHub
+var defaultHub atomic.Pointer[EventHub]
 
-// Send broadcasts an event to a specific client on a specific path
+// SetDefault sets the hub used by the package level Send functions. Call it
+// once while wiring the application.
+func SetDefault(hub *EventHub) {
+	defaultHub.Store(hub)
+}
+
+// Default returns the hub used by the package level Send functions, nil if none was set
+func Default() *EventHub {
+	return defaultHub.Load()
+}
+
+// Send broadcasts an event to every connection of a specific client on a specific path
 func Send(path string, clientID int64, data interface{}) {
-	if EventHubInstance != nil {
-		EventHubInstance.Broadcast(path, clientID, data)
+	if hub := Default(); hub != nil {
+		hub.Broadcast(path, clientID, data)
+	}
+}
+
+// SendEvent broadcasts a named event, as matched by htmx sse-swap, to a specific client on a specific path
+func SendEvent(path string, clientID int64, event string, data interface{}) {
+	Send(path, clientID, Message{Event: event, Data: data})
+}
+
+// SendToClient broadcasts an event to every connection of a specific client across all paths
+func SendToClient(clientID int64, data interface{}) {
+	if hub := Default(); hub != nil {
+		hub.BroadcastClient(clientID, data)
 	}
 }
 
@@ -125,11 +147,16 @@ func SendWithMetadata(path string, clientID int64, status string, data interface
 
 // SendToPath sends an event to all clients subscribed to a specific path
 func SendToPath(path string, data interface{}) {
-	if EventHubInstance != nil {
-		EventHubInstance.BroadcastPath(path, data)
+	if hub := Default(); hub != nil {
+		hub.BroadcastPath(path, data)
 	}
 }
 
+// SendToPathEvent sends a named event, as matched by htmx sse-swap, to all clients on a specific path
+func SendToPathEvent(path string, event string, data interface{}) {
+	SendToPath(path, Message{Event: event, Data: data})
+}
+
 // SendToPathWithStatus sends an event with a status to all clients on a specific path
 func SendToPathWithStatus(path string, status string, data interface{}) {
 	event := Event{
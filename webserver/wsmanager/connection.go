@@ -1,17 +1,205 @@
 package wsmanager
 
 import (
+	"bufio"
+	"encoding/json"
+	"errors"
 	"net"
 	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrConnectionClosed is returned when sending to a closed connection
+	ErrConnectionClosed = errors.New("wsmanager: connection closed")
+	// ErrSlowConsumer is returned when a connection was evicted because its outbound queue was full
+	ErrSlowConsumer = errors.New("wsmanager: slow consumer evicted")
+)
+
+type (
+	// ConnectionOption customizes a Connection
+	ConnectionOption func(c *Connection)
+
+	// frame is a queued outbound frame
+	frame struct {
+		op   ws.OpCode
+		data []byte
+	}
 )
 
+// WithMaxBacklog sets how many outbound frames may queue up before the connection is evicted as a slow consumer
+func WithMaxBacklog(size int) ConnectionOption {
+	return func(c *Connection) {
+		c.queue = make(chan frame, size)
+	}
+}
+
+// WithWriteTimeout sets how long a single write may block before the connection is closed
+func WithWriteTimeout(timeout time.Duration) ConnectionOption {
+	return func(c *Connection) {
+		c.writeTimeout = timeout
+	}
+}
+
+// WithCallTimeout sets how long Call waits for a reply when the context has no earlier deadline
+func WithCallTimeout(timeout time.Duration) ConnectionOption {
+	return func(c *Connection) {
+		c.callTimeout = timeout
+	}
+}
+
+// WithOnEvict calls fn when the connection is evicted as a slow consumer
+func WithOnEvict(fn func(conn *Connection)) ConnectionOption {
+	return func(c *Connection) {
+		c.onEvict = fn
+	}
+}
+
+// Connection is a WebSocket connection whose outbound frames are queued and
+// written by a dedicated writer goroutine, so senders never block on the socket
 type Connection struct {
+	// ID uniquely identifies the connection across instances
+	ID   string
 	Conn net.Conn
-	mu   sync.Mutex
+	// UserID is the user the connection was authenticated as, nil for anonymous connections
+	UserID any
+
+	mu           sync.Mutex // serializes writes to Conn
+	queue        chan frame
+	writeTimeout time.Duration
+	onEvict      func(conn *Connection)
+	evicted      atomic.Bool
+	done         chan struct{}
+	closeOnce    sync.Once
+
+	callTimeout time.Duration
+	pendingMu   sync.Mutex
+	pending     map[string]chan Message // Calls awaiting a reply by message ID
+}
+
+func NewConnection(conn net.Conn, opts ...ConnectionOption) *Connection {
+	c := &Connection{
+		ID:           uuid.New().String(),
+		Conn:         conn,
+		queue:        make(chan frame, 256),
+		writeTimeout: 10 * time.Second,
+		done:         make(chan struct{}),
+		callTimeout:  30 * time.Second,
+		pending:      make(map[string]chan Message),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	go c.writeLoop()
+	return c
+}
+
+// Send queues msg to be written to the connection as a JSON text frame
+func (c *Connection) Send(msg Message) error {
+	out, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return c.write(ws.OpText, out)
 }
 
-func NewConnection(conn net.Conn) *Connection {
-	return &Connection{
-		Conn: conn,
+// Close stops the writer and closes the underlying connection, frames still queued are discarded
+func (c *Connection) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.done)
+		err = c.Conn.Close()
+	})
+	return err
+}
+
+// Done is closed when the connection is closed
+func (c *Connection) Done() <-chan struct{} {
+	return c.done
+}
+
+// Evicted reports whether the connection was closed as a slow consumer
+func (c *Connection) Evicted() bool {
+	return c.evicted.Load()
+}
+
+// Backlog returns the number of queued frames not yet written
+func (c *Connection) Backlog() int {
+	return len(c.queue)
+}
+
+// write queues a frame without blocking, evicting the connection if its queue is full
+func (c *Connection) write(op ws.OpCode, data []byte) error {
+	select {
+	case <-c.done:
+		return ErrConnectionClosed
+	default:
+	}
+
+	select {
+	case c.queue <- frame{op: op, data: data}:
+		return nil
+	case <-c.done:
+		return ErrConnectionClosed
+	default:
+		c.evict()
+		return ErrSlowConsumer
+	}
+}
+
+// evict closes a connection that does not keep up with its outbound frames
+func (c *Connection) evict() {
+	if !c.evicted.CompareAndSwap(false, true) {
+		return
+	}
+
+	// Tell the client why unless the writer is stuck on the socket
+	if c.mu.TryLock() {
+		c.Conn.SetWriteDeadline(time.Now().Add(time.Second))
+		wsutil.WriteServerMessage(c.Conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusPolicyViolation, "slow consumer"))
+		c.mu.Unlock()
+	}
+	c.Close()
+
+	if c.onEvict != nil {
+		// The sender may hold the manager lock the callback needs
+		go c.onEvict(c)
+	}
+}
+
+// writeLoop writes queued frames until the connection is closed. Frames that
+// queued up while writing are coalesced into a single flush.
+func (c *Connection) writeLoop() {
+	bw := bufio.NewWriter(c.Conn)
+	for {
+		select {
+		case <-c.done:
+			return
+		case f := <-c.queue:
+			c.mu.Lock()
+			if c.writeTimeout > 0 {
+				c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+			}
+			err := wsutil.WriteServerMessage(bw, f.op, f.data)
+			for n := len(c.queue); err == nil && n > 0; n-- {
+				f = <-c.queue
+				err = wsutil.WriteServerMessage(bw, f.op, f.data)
+			}
+			if err == nil {
+				err = bw.Flush()
+			}
+			c.mu.Unlock()
+
+			if err != nil {
+				c.Close()
+				return
+			}
+		}
 	}
 }
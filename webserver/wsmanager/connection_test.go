@@ -0,0 +1,57 @@
+package wsmanager
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestConnectionWritesInOrder tests that queued frames reach the client in order.
+func TestConnectionWritesInOrder(t *testing.T) {
+	server, client := net.Pipe()
+	conn := NewConnection(server)
+	defer conn.Close()
+
+	for _, text := range []string{"a", "b", "c"} {
+		require.NoError(t, conn.write(ws.OpText, []byte(text)))
+	}
+
+	client.SetReadDeadline(time.Now().Add(time.Second))
+	for _, want := range []string{"a", "b", "c"} {
+		data, err := wsutil.ReadServerText(client)
+		require.NoError(t, err)
+		assert.Equal(t, want, string(data))
+	}
+}
+
+// TestConnectionEvictsSlowConsumer tests that a client that stops reading is evicted once its backlog is full.
+func TestConnectionEvictsSlowConsumer(t *testing.T) {
+	server, client := net.Pipe()
+	defer client.Close()
+
+	evicted := make(chan *Connection, 1)
+	conn := NewConnection(server, WithMaxBacklog(2), WithOnEvict(func(c *Connection) {
+		evicted <- c
+	}))
+
+	var err error
+	for i := 0; i < 10 && err == nil; i++ {
+		err = conn.write(ws.OpText, []byte("update"))
+	}
+
+	assert.ErrorIs(t, err, ErrSlowConsumer)
+	assert.True(t, conn.Evicted())
+	select {
+	case c := <-evicted:
+		assert.Same(t, conn, c)
+	case <-time.After(time.Second):
+		t.Fatal("eviction callback was not called")
+	}
+	<-conn.Done()
+	assert.ErrorIs(t, conn.write(ws.OpText, []byte("late")), ErrConnectionClosed)
+}
@@ -0,0 +1,370 @@
+package wsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
+	"github.com/labstack/echo/v4"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+type (
+	// AuthFunc authenticates the upgrade request and returns the user the connection belongs to
+	AuthFunc func(c echo.Context) (userID any, err error)
+
+	// TopicHandler handles a message a client sent for a topic
+	TopicHandler func(ctx context.Context, conn *Connection, msg Message) error
+
+	// HandlerOption customizes a Handler
+	HandlerOption func(h *Handler)
+)
+
+// WithAuth authenticates connections with fn before upgrading, failed requests are rejected with 401
+func WithAuth(fn AuthFunc) HandlerOption {
+	return func(h *Handler) {
+		h.auth = fn
+	}
+}
+
+// WithPingInterval sets how often the server pings idle clients
+func WithPingInterval(interval time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.pingInterval = interval
+	}
+}
+
+// WithReadTimeout sets how long a client may stay silent, pongs included, before it is disconnected
+func WithReadTimeout(timeout time.Duration) HandlerOption {
+	return func(h *Handler) {
+		h.readTimeout = timeout
+	}
+}
+
+// WithOnConnect calls fn after a connection is registered with the manager
+func WithOnConnect(fn func(conn *Connection)) HandlerOption {
+	return func(h *Handler) {
+		h.onConnect = fn
+	}
+}
+
+// WithOnDisconnect calls fn before a connection is removed from the manager
+func WithOnDisconnect(fn func(conn *Connection)) HandlerOption {
+	return func(h *Handler) {
+		h.onDisconnect = fn
+	}
+}
+
+// WithConnectionOptions applies opts to every connection the handler accepts
+func WithConnectionOptions(opts ...ConnectionOption) HandlerOption {
+	return func(h *Handler) {
+		h.connOpts = append(h.connOpts, opts...)
+	}
+}
+
+// Handler upgrades HTTP requests to WebSocket connections of a ConnectionManager
+// and routes the messages of clients to the handlers registered for their topic
+type Handler struct {
+	manager      *ConnectionManager
+	auth         AuthFunc
+	pingInterval time.Duration
+	readTimeout  time.Duration
+	onConnect    func(conn *Connection)
+	onDisconnect func(conn *Connection)
+	connOpts     []ConnectionOption
+
+	mu       sync.RWMutex
+	handlers map[string]route
+}
+
+// route is the handler of a topic
+type route struct {
+	fn TopicHandler
+	// concurrent messages are handled in their own goroutine rather than in order
+	concurrent bool
+}
+
+// queueSize is the number of messages of a connection waiting to be handled
+// before reading from the client pauses
+const queueSize = 64
+
+// NewHandler creates a handler registering its connections with manager
+func NewHandler(manager *ConnectionManager, opts ...HandlerOption) *Handler {
+	h := &Handler{
+		manager:      manager,
+		pingInterval: 30 * time.Second,
+		readTimeout:  60 * time.Second,
+		handlers:     make(map[string]route),
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
+// Handle registers fn for the messages clients send on topic. The messages
+// of a connection are handled one at a time in the order they were sent.
+func (h *Handler) Handle(topic string, fn TopicHandler) {
+	h.handle(topic, route{fn: fn})
+}
+
+// HandleConcurrent registers fn for the messages clients send on topic, each
+// handled in its own goroutine so slow messages don't hold up the others of
+// the connection. Use it for messages that are independent of their order.
+func (h *Handler) HandleConcurrent(topic string, fn TopicHandler) {
+	h.handle(topic, route{fn: fn, concurrent: true})
+}
+
+func (h *Handler) handle(topic string, r route) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.handlers[topic] = r
+}
+
+// Serve upgrades the request and serves the connection until the client disconnects
+func (h *Handler) Serve(c echo.Context) error {
+	var userID any
+	if h.auth != nil {
+		id, err := h.auth(c)
+		if err != nil {
+			return echo.ErrUnauthorized.WithInternal(err)
+		}
+		userID = id
+	}
+
+	netConn, _, _, err := ws.UpgradeHTTP(c.Request(), c.Response())
+	if err != nil {
+		return err
+	}
+
+	conn := NewConnection(netConn, h.connOpts...)
+	conn.UserID = userID
+	h.manager.AddClient(conn, userID)
+	defer h.manager.RemoveClient(conn, userID)
+
+	ctx, cancel := context.WithCancel(c.Request().Context())
+	var wg sync.WaitGroup
+	defer func() {
+		// Let running handlers finish before the connection is removed, closing
+		// the socket first so none of them blocks writing to a gone client
+		cancel()
+		conn.Close()
+		wg.Wait()
+		if h.onDisconnect != nil {
+			h.onDisconnect(conn)
+		}
+	}()
+
+	if h.onConnect != nil {
+		h.onConnect(conn)
+	}
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.ping(ctx, conn)
+	}()
+
+	queue := make(chan Message, queueSize)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.work(ctx, conn, queue)
+	}()
+
+	err = h.read(ctx, conn, queue, &wg)
+	var closed wsutil.ClosedError
+	if err != nil && !errors.As(err, &closed) && !errors.Is(err, io.EOF) && ctx.Err() == nil {
+		h.manager.debugLog("Connection read failed", logx.Field("connection", conn), logx.Field("error", err))
+	}
+	return nil
+}
+
+// read reads frames until the connection fails or is closed, answering control
+// frames and dispatching data messages. Messages are queued for the worker of
+// the connection unless their topic is handled concurrently.
+func (h *Handler) read(ctx context.Context, conn *Connection, queue chan<- Message, wg *sync.WaitGroup) error {
+	defer close(queue)
+
+	control := wsutil.ControlFrameHandler(connWriter{conn}, ws.StateServerSide)
+	rd := &wsutil.Reader{
+		Source:         conn.Conn,
+		State:          ws.StateServerSide,
+		CheckUTF8:      true,
+		OnIntermediate: control,
+	}
+
+	for {
+		if h.readTimeout > 0 {
+			if err := conn.Conn.SetReadDeadline(time.Now().Add(h.readTimeout)); err != nil {
+				return err
+			}
+		}
+
+		hdr, err := rd.NextFrame()
+		if err != nil {
+			return err
+		}
+		if hdr.OpCode.IsControl() {
+			if err := control(hdr, rd); err != nil {
+				return err
+			}
+			continue
+		}
+
+		data, err := io.ReadAll(rd)
+		if err != nil {
+			return err
+		}
+		if hdr.OpCode != ws.OpText {
+			continue
+		}
+
+		// Clients without access to ping frames, such as browsers, send a text ping
+		if string(data) == "ping" {
+			if err := conn.write(ws.OpText, []byte("pong")); err != nil {
+				return err
+			}
+			continue
+		}
+
+		var msg Message
+		if err := json.Unmarshal(data, &msg); err != nil {
+			h.reply(conn, Message{}, fmt.Errorf("invalid message: %w", err))
+			continue
+		}
+		if msg.Topic == TopicReply {
+			// Replies answer calls the server made, they are not dispatched
+			if !conn.resolve(msg) {
+				h.manager.debugLog("Reply without pending call", logx.Field("connection", conn), logx.Field("id", msg.ID))
+			}
+			continue
+		}
+		if msg.ID == "" {
+			msg.ID = uuid.New().String()
+		}
+		msg.Sender = conn
+
+		if h.concurrent(msg.Topic) {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				h.handleMessage(ctx, conn, msg)
+			}()
+			continue
+		}
+		select {
+		case queue <- msg:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// work handles the queued messages of a connection in order until the queue
+// is closed, skipping those left when the connection is gone
+func (h *Handler) work(ctx context.Context, conn *Connection, queue <-chan Message) {
+	for msg := range queue {
+		if ctx.Err() != nil {
+			continue
+		}
+		h.handleMessage(ctx, conn, msg)
+	}
+}
+
+// handleMessage dispatches msg, replying the error to the client
+func (h *Handler) handleMessage(ctx context.Context, conn *Connection, msg Message) {
+	if err := h.dispatch(ctx, conn, msg); err != nil {
+		h.reply(conn, msg, err)
+	}
+}
+
+// concurrent reports whether the messages of topic are handled concurrently
+func (h *Handler) concurrent(topic string) bool {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return h.handlers[topic].concurrent
+}
+
+// dispatch routes msg to the built-in topics or the handler registered for its topic
+func (h *Handler) dispatch(ctx context.Context, conn *Connection, msg Message) error {
+	switch msg.Topic {
+	case TopicSubscribe, TopicUnsubscribe:
+		var topicMsg struct {
+			Topic string `json:"topic"`
+		}
+		if err := json.Unmarshal(msg.Payload, &topicMsg); err != nil || topicMsg.Topic == "" {
+			return fmt.Errorf("%s requires a topic", msg.Topic)
+		}
+		if msg.Topic == TopicSubscribe {
+			h.manager.Subscribe(conn, topicMsg.Topic)
+		} else {
+			h.manager.Unsubscribe(conn, topicMsg.Topic)
+		}
+		return nil
+	case TopicBroadcast:
+		h.manager.Broadcast(msg, conn)
+		return nil
+	}
+
+	h.mu.RLock()
+	r, ok := h.handlers[msg.Topic]
+	h.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown topic %q", msg.Topic)
+	}
+	return r.fn(ctx, conn, msg)
+}
+
+// reply tells the client that msg could not be handled
+func (h *Handler) reply(conn *Connection, msg Message, cause error) {
+	payload, err := json.Marshal(ErrorPayload{Topic: msg.Topic, Error: cause.Error()})
+	if err != nil {
+		return
+	}
+	if err := conn.Send(Message{ID: msg.ID, Topic: TopicError, Payload: payload}); err != nil {
+		h.manager.debugLog("Error reply failed", logx.Field("connection", conn), logx.Field("error", err))
+	}
+}
+
+// ping pings the client every ping interval until ctx is done
+func (h *Handler) ping(ctx context.Context, conn *Connection) {
+	if h.pingInterval <= 0 {
+		return
+	}
+	ticker := time.NewTicker(h.pingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := conn.write(ws.OpPing, nil); err != nil {
+				// The connection is closed, which also ends the read loop
+				return
+			}
+		}
+	}
+}
+
+// connWriter serializes the control frame replies with the other writes of a connection
+type connWriter struct {
+	conn *Connection
+}
+
+func (w connWriter) Write(p []byte) (int, error) {
+	w.conn.mu.Lock()
+	defer w.conn.mu.Unlock()
+	if w.conn.writeTimeout > 0 {
+		w.conn.Conn.SetWriteDeadline(time.Now().Add(w.conn.writeTimeout))
+	}
+	return w.conn.Conn.Write(p)
+}
@@ -0,0 +1,147 @@
+package wsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws"
+	"github.com/gobwas/ws/wsutil"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func dial(t *testing.T, h *Handler, query string) net.Conn {
+	t.Helper()
+
+	e := echo.New()
+	e.GET("/ws", h.Serve)
+	srv := httptest.NewServer(e)
+	t.Cleanup(srv.Close)
+
+	conn, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws"+query)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	require.NoError(t, conn.SetDeadline(time.Now().Add(2*time.Second)))
+	return conn
+}
+
+func readMessage(t *testing.T, conn net.Conn) Message {
+	t.Helper()
+
+	data, err := wsutil.ReadServerText(conn)
+	require.NoError(t, err)
+	var msg Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	return msg
+}
+
+// TestHandlerRoutesTopics tests that client messages reach their handler and errors are replied.
+func TestHandlerRoutesTopics(t *testing.T) {
+	h := NewHandler(NewConnectionManager(), WithAuth(func(c echo.Context) (any, error) {
+		if c.QueryParam("user") == "" {
+			return nil, errors.New("no user")
+		}
+		return c.QueryParam("user"), nil
+	}))
+	h.Handle("echo", func(ctx context.Context, conn *Connection, msg Message) error {
+		msg.Payload = json.RawMessage(`{"user":"` + conn.UserID.(string) + `"}`)
+		return conn.Send(msg)
+	})
+	conn := dial(t, h, "?user=7")
+
+	require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"1","topic":"echo","payload":{}}`)))
+	msg := readMessage(t, conn)
+	assert.Equal(t, "1", msg.ID)
+	assert.JSONEq(t, `{"user":"7"}`, string(msg.Payload))
+
+	require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"2","topic":"missing"}`)))
+	msg = readMessage(t, conn)
+	assert.Equal(t, "2", msg.ID)
+	assert.Equal(t, TopicError, msg.Topic)
+	assert.JSONEq(t, `{"topic":"missing","error":"unknown topic \"missing\""}`, string(msg.Payload))
+
+	require.NoError(t, wsutil.WriteClientText(conn, []byte("ping")))
+	data, err := wsutil.ReadServerText(conn)
+	require.NoError(t, err)
+	assert.Equal(t, "pong", string(data))
+}
+
+// TestHandlerOrdersMessages tests that the messages of a connection are handled
+// in order, while concurrent topics don't wait for them.
+func TestHandlerOrdersMessages(t *testing.T) {
+	h := NewHandler(NewConnectionManager())
+	release := make(chan struct{})
+	h.Handle("append", func(ctx context.Context, conn *Connection, msg Message) error {
+		if msg.ID == "1" {
+			<-release
+		}
+		return conn.Send(msg)
+	})
+	h.HandleConcurrent("status", func(ctx context.Context, conn *Connection, msg Message) error {
+		defer close(release)
+		return conn.Send(msg)
+	})
+	conn := dial(t, h, "")
+
+	for _, id := range []string{"1", "2", "3"} {
+		require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"`+id+`","topic":"append"}`)))
+	}
+	require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"4","topic":"status"}`)))
+
+	var ids []string
+	for i := 0; i < 4; i++ {
+		ids = append(ids, readMessage(t, conn).ID)
+	}
+	assert.Equal(t, []string{"4", "1", "2", "3"}, ids)
+}
+
+// TestHandlerRejectsUnauthenticated tests that the upgrade fails when authentication fails.
+func TestHandlerRejectsUnauthenticated(t *testing.T) {
+	h := NewHandler(NewConnectionManager(), WithAuth(func(c echo.Context) (any, error) {
+		return nil, errors.New("no user")
+	}))
+
+	e := echo.New()
+	e.GET("/ws", h.Serve)
+	srv := httptest.NewServer(e)
+	defer srv.Close()
+
+	_, _, _, err := ws.Dial(context.Background(), "ws"+strings.TrimPrefix(srv.URL, "http")+"/ws")
+	var status ws.StatusError
+	require.ErrorAs(t, err, &status)
+	assert.Equal(t, 401, int(status))
+}
+
+// TestHandlerAnswersPing tests that ping frames are answered and disconnects are cleaned up.
+func TestHandlerAnswersPing(t *testing.T) {
+	disconnected := make(chan *Connection, 1)
+	manager := NewConnectionManager()
+	h := NewHandler(manager, WithOnDisconnect(func(conn *Connection) {
+		disconnected <- conn
+	}))
+	conn := dial(t, h, "")
+
+	require.NoError(t, wsutil.WriteClientMessage(conn, ws.OpPing, []byte("hi")))
+	msgs, err := wsutil.ReadServerMessage(conn, nil)
+	require.NoError(t, err)
+	require.Len(t, msgs, 1)
+	assert.Equal(t, ws.OpPong, msgs[0].OpCode)
+	assert.Equal(t, "hi", string(msgs[0].Payload))
+
+	require.NoError(t, wsutil.WriteClientMessage(conn, ws.OpClose, ws.NewCloseFrameBody(ws.StatusNormalClosure, "")))
+	select {
+	case c := <-disconnected:
+		assert.Eventually(t, func() bool {
+			return len(manager.GetConnectionsForUser(c.UserID)) == 0
+		}, time.Second, 10*time.Millisecond)
+	case <-time.After(time.Second):
+		t.Fatal("connection was not cleaned up")
+	}
+}
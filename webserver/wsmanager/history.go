@@ -0,0 +1,52 @@
+package wsmanager
+
+import (
+	"context"
+	"sync"
+)
+
+// HistoryStore keeps the messages of rooms for late joiners
+type HistoryStore interface {
+	// Append stores msg as the newest message of room
+	Append(ctx context.Context, room string, msg RoomMessage) error
+	// Recent returns up to n of the newest messages of room, oldest first
+	Recent(ctx context.Context, room string, n int) ([]RoomMessage, error)
+}
+
+// MemoryHistory keeps the last messages of every room in memory
+type MemoryHistory struct {
+	mu    sync.Mutex
+	size  int
+	rooms map[string][]RoomMessage
+}
+
+// NewMemoryHistory creates a history keeping the last size messages per room
+func NewMemoryHistory(size int) *MemoryHistory {
+	return &MemoryHistory{
+		size:  size,
+		rooms: make(map[string][]RoomMessage),
+	}
+}
+
+func (h *MemoryHistory) Append(ctx context.Context, room string, msg RoomMessage) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	msgs := append(h.rooms[room], msg)
+	if len(msgs) > h.size {
+		msgs = append([]RoomMessage(nil), msgs[len(msgs)-h.size:]...)
+	}
+	h.rooms[room] = msgs
+	return nil
+}
+
+func (h *MemoryHistory) Recent(ctx context.Context, room string, n int) ([]RoomMessage, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	msgs := h.rooms[room]
+	if len(msgs) > n {
+		msgs = msgs[len(msgs)-n:]
+	}
+	return append([]RoomMessage(nil), msgs...), nil
+}
@@ -6,8 +6,6 @@ import (
 	"log"
 	"sync"
 
-	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
 	"github.com/google/uuid"
 )
 
@@ -28,14 +26,22 @@ func GetManager(name string) *ConnectionManager {
 	return managers[name]
 }
 
-// RemoveManager removes a manager from the global managers map.
+// RemoveManager closes a manager and its connections and removes it from the global managers map.
 func RemoveManager(name string) {
 	mu.Lock()
 	defer mu.Unlock()
 	if manager, exists := managers[name]; exists {
+		manager.mu.Lock()
+		clients := make(map[*Connection]any, len(manager.clients))
 		for conn := range manager.clients {
-			manager.RemoveClient(conn, nil)
+			clients[conn] = conn.UserID
 		}
+		manager.mu.Unlock()
+
+		for conn, userID := range clients {
+			manager.RemoveClient(conn, userID)
+		}
+		manager.Close()
 		delete(managers, name)
 	}
 }
@@ -46,12 +52,25 @@ func SendEvent(managerName string, topic string, payload interface{}) error {
 	if manager == nil {
 		return fmt.Errorf("manager not found")
 	}
+	return manager.SendEvent(topic, payload)
+}
+
+// SendEventToUser sends an event with the given topic and payload to a specific user.
+func SendEventToUser(managerName string, userID any, topic string, payload interface{}) error {
+	manager := GetManager(managerName)
+	if manager == nil {
+		return fmt.Errorf("manager not found")
+	}
+	return manager.SendEventToUser(userID, topic, payload)
+}
 
+// encodeEvent wraps payload in a Message for topic
+func encodeEvent(topic string, payload interface{}) ([]byte, error) {
 	// Marshal the payload to JSON
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
 		log.Printf("Error marshalling payload for topic %s: %v", topic, err)
-		return err
+		return nil, err
 	}
 
 	// Create a message struct
@@ -65,78 +84,24 @@ func SendEvent(managerName string, topic string, payload interface{}) error {
 	out, err := json.Marshal(msg)
 	if err != nil {
 		log.Printf("Error marshalling message for topic %s: %v", topic, err)
-		return err
-	}
-
-	// Broadcast the event to all subscribed clients
-	for conn := range manager.GetSubscribers(topic) {
-		err := wsutil.WriteServerMessage(conn.Conn, ws.OpText, out)
-		if err != nil {
-			log.Printf("Failed to send event to user %s: %v", conn.Conn, err)
-			// Optionally, handle the error (e.g., remove the connection)
-		}
+		return nil, err
 	}
-
-	return nil
+	return out, nil
 }
 
-// SendEventToUser sends an event with the given topic and payload to a specific user.
-func SendEventToUser(managerName string, userID any, topic string, payload interface{}) error {
-	manager := GetManager(managerName)
-	if manager == nil {
-		return fmt.Errorf("manager not found")
-	}
-
-	// Retrieve the user's connections
-	connections := manager.GetConnectionsForUser(userID)
-	if len(connections) == 0 {
-		log.Printf("No active connections for user %s", userID)
-		return nil // Optionally, return an error if needed
-	}
-
-	// Marshal the payload to JSON
+// encodeUserEvent encodes an event for a user. Payloads that marshal to a
+// plain string, such as HTML, are sent as-is, structured data is wrapped in a Message.
+func encodeUserEvent(topic string, payload interface{}) ([]byte, error) {
 	payloadBytes, err := json.Marshal(payload)
 	if err != nil {
-		log.Printf("Error marshalling payload for user %s: %v", userID, err)
-		return err
+		log.Printf("Error marshalling payload for topic %s: %v", topic, err)
+		return nil, err
 	}
 
-	var out []byte
-
 	// Try to unmarshal as a plain string first
 	var stringMsg string
 	if err := json.Unmarshal(payloadBytes, &stringMsg); err == nil {
-		// It's a plain string/HTML, send it directly
-		out = []byte(stringMsg)
-		// Send the event to each connection of the user
-		for _, conn := range connections {
-			err := wsutil.WriteServerMessage(conn.Conn, ws.OpText, out)
-			if err != nil {
-				log.Printf("Failed to send event to user %s: %v", userID, err)
-				// Optionally, handle the error (e.g., remove the connection)
-			}
-		}
-	} else {
-		// It's structured data, wrap in Message
-		msg := Message{
-			Topic:   topic,
-			Payload: json.RawMessage(payloadBytes),
-			ID:      uuid.New().String(),
-		}
-		out, err = json.Marshal(msg)
-		if err != nil {
-			log.Printf("Error marshalling message for user %s: %v", userID, err)
-			return err
-		}
-		// Send the event to each connection of the user
-		for _, conn := range connections {
-			err := wsutil.WriteServerMessage(conn.Conn, ws.OpText, out)
-			if err != nil {
-				log.Printf("Failed to send event to user %s: %v", userID, err)
-				// Optionally, handle the error (e.g., remove the connection)
-			}
-		}
+		return []byte(stringMsg), nil
 	}
-
-	return nil
+	return encodeEvent(topic, payload)
 }
@@ -2,9 +2,31 @@ package wsmanager
 
 import "encoding/json"
 
+// Topics handled by the Handler itself
+const (
+	// TopicSubscribe subscribes the connection to the topic in the payload, e.g. {"topic": "news"}
+	TopicSubscribe = "subscribe"
+	// TopicUnsubscribe unsubscribes the connection from the topic in the payload
+	TopicUnsubscribe = "unsubscribe"
+	// TopicBroadcast relays the payload to the other subscribers of its topic
+	TopicBroadcast = "broadcast"
+	// TopicError is the topic of the reply sent when a message could not be handled
+	TopicError = "error"
+	// TopicReply is the topic of RPC replies, they carry the ID of the call they answer
+	TopicReply = "reply"
+)
+
 type Message struct {
 	ID      string          `json:"id"`
 	Topic   string          `json:"topic"`
 	Payload json.RawMessage `json:"payload"`
-	Sender  *Connection     `json:"-"`
+	// Error is set on RPC replies whose call failed
+	Error  *RPCError   `json:"error,omitempty"`
+	Sender *Connection `json:"-"`
+}
+
+// ErrorPayload is the payload of a TopicError reply
+type ErrorPayload struct {
+	Topic string `json:"topic"`
+	Error string `json:"error"`
 }
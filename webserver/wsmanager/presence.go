@@ -0,0 +1,76 @@
+package wsmanager
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Presence tracks the connections of users across instances. Entries expire
+// unless they are added again within the refresh interval, so the
+// connections of a crashed instance disappear on their own.
+type Presence interface {
+	// Add adds or refreshes a connection of a user
+	Add(ctx context.Context, userID, connID string) error
+	// Remove removes a connection of a user
+	Remove(ctx context.Context, userID, connID string) error
+	// Count returns the number of live connections of a user
+	Count(ctx context.Context, userID string) (int, error)
+	// RefreshInterval returns how often live connections must be added again
+	RefreshInterval() time.Duration
+}
+
+// RedisPresence stores presence in a Redis sorted set per user, scored by expiry
+type RedisPresence struct {
+	client *redis.Client
+	prefix string
+	ttl    time.Duration
+}
+
+// NewRedisPresence creates a presence store on client. Keys start with
+// prefix and connections expire ttl after they were last added.
+func NewRedisPresence(client *redis.Client, prefix string, ttl time.Duration) *RedisPresence {
+	return &RedisPresence{
+		client: client,
+		prefix: prefix,
+		ttl:    ttl,
+	}
+}
+
+func (p *RedisPresence) key(userID string) string {
+	return p.prefix + "presence:" + userID
+}
+
+func (p *RedisPresence) Add(ctx context.Context, userID, connID string) error {
+	key := p.key(userID)
+	expires := time.Now().Add(p.ttl).UnixMilli()
+
+	pipe := p.client.TxPipeline()
+	pipe.ZAdd(ctx, key, redis.Z{Score: float64(expires), Member: connID})
+	pipe.PExpire(ctx, key, p.ttl)
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+func (p *RedisPresence) Remove(ctx context.Context, userID, connID string) error {
+	return p.client.ZRem(ctx, p.key(userID), connID).Err()
+}
+
+func (p *RedisPresence) Count(ctx context.Context, userID string) (int, error) {
+	key := p.key(userID)
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+
+	pipe := p.client.TxPipeline()
+	pipe.ZRemRangeByScore(ctx, key, "-inf", "("+now)
+	count := pipe.ZCard(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+	return int(count.Val()), nil
+}
+
+func (p *RedisPresence) RefreshInterval() time.Duration {
+	return p.ttl / 3
+}
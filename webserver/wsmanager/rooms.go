@@ -0,0 +1,458 @@
+package wsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// Topics of the rooms protocol
+const (
+	// TopicRoomJoin joins the room in the payload, e.g. {"room": "lobby"}
+	TopicRoomJoin = "room:join"
+	// TopicRoomLeave leaves the room in the payload
+	TopicRoomLeave = "room:leave"
+	// TopicRoomMessage carries a RoomMessage, clients send {"room": "lobby", "data": ...}
+	TopicRoomMessage = "room:message"
+	// TopicRoomPresence carries a PresenceDiff, clients send {"room": "lobby", "state": "typing"}
+	TopicRoomPresence = "room:presence"
+	// TopicRoomMembers carries the members of a room, sent after joining
+	TopicRoomMembers = "room:members"
+	// TopicRoomHistory carries the recent messages of a room, sent after joining
+	TopicRoomHistory = "room:history"
+)
+
+// ErrNotMember is returned when a connection acts on a room it has not joined
+var ErrNotMember = errors.New("wsmanager: not a member of the room")
+
+type (
+	// Member is a user in a room
+	Member struct {
+		UserID string `json:"userId"`
+		// State is the presence state of the user, e.g. "typing"
+		State string `json:"state,omitempty"`
+	}
+
+	// PresenceDiff is pushed to the members of a room when its presence changes
+	PresenceDiff struct {
+		Room    string   `json:"room"`
+		Joins   []Member `json:"joins,omitempty"`
+		Leaves  []Member `json:"leaves,omitempty"`
+		Updates []Member `json:"updates,omitempty"`
+	}
+
+	// RoomMessage is a message sent to a room
+	RoomMessage struct {
+		ID     string          `json:"id"`
+		Room   string          `json:"room"`
+		UserID string          `json:"userId"`
+		Data   json.RawMessage `json:"data"`
+		Time   time.Time       `json:"time"`
+	}
+
+	// RoomMembers is the payload of TopicRoomMembers
+	RoomMembers struct {
+		Room    string   `json:"room"`
+		Members []Member `json:"members"`
+	}
+
+	// RoomHistory is the payload of TopicRoomHistory
+	RoomHistory struct {
+		Room     string        `json:"room"`
+		Messages []RoomMessage `json:"messages"`
+	}
+
+	// AuthorizeFunc decides whether conn may join room
+	AuthorizeFunc func(ctx context.Context, conn *Connection, room string) error
+
+	// RoomOption customizes Rooms
+	RoomOption func(r *Rooms)
+)
+
+// WithAuthorize checks every join with fn, joins it rejects fail with its error
+func WithAuthorize(fn AuthorizeFunc) RoomOption {
+	return func(r *Rooms) {
+		r.authorize = fn
+	}
+}
+
+// WithOnJoin calls fn after a connection joined a room
+func WithOnJoin(fn func(conn *Connection, room string)) RoomOption {
+	return func(r *Rooms) {
+		r.onJoin = fn
+	}
+}
+
+// WithOnLeave calls fn after a connection left a room, including on disconnect
+func WithOnLeave(fn func(conn *Connection, room string)) RoomOption {
+	return func(r *Rooms) {
+		r.onLeave = fn
+	}
+}
+
+// WithHistory keeps the messages of rooms in store and sends the last n to joining connections
+func WithHistory(store HistoryStore, n int) RoomOption {
+	return func(r *Rooms) {
+		r.history = store
+		r.historySize = n
+	}
+}
+
+// room is the membership of a room. A user with several connections is one member.
+type room struct {
+	conns   map[*Connection]string // connection to member key
+	members map[string]*roomMember
+}
+
+type roomMember struct {
+	Member
+	conns int
+}
+
+// Rooms manages room membership, presence and history for connections
+type Rooms struct {
+	authorize   AuthorizeFunc
+	onJoin      func(conn *Connection, room string)
+	onLeave     func(conn *Connection, room string)
+	history     HistoryStore
+	historySize int
+
+	mu     sync.Mutex
+	rooms  map[string]*room
+	byConn map[*Connection]map[string]bool
+	// watched holds the connections whose close is watched, independent of
+	// their membership so joining and leaving starts one watcher per connection
+	watched map[*Connection]bool
+}
+
+// NewRooms creates an empty set of rooms
+func NewRooms(opts ...RoomOption) *Rooms {
+	r := &Rooms{
+		rooms:   make(map[string]*room),
+		byConn:  make(map[*Connection]map[string]bool),
+		watched: make(map[*Connection]bool),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Register handles the rooms protocol topics on h
+func (r *Rooms) Register(h *Handler) {
+	h.Handle(TopicRoomJoin, func(ctx context.Context, conn *Connection, msg Message) error {
+		var req struct {
+			Room string `json:"room"`
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || req.Room == "" {
+			return fmt.Errorf("%s requires a room", msg.Topic)
+		}
+		return r.Join(ctx, conn, req.Room)
+	})
+	h.Handle(TopicRoomLeave, func(ctx context.Context, conn *Connection, msg Message) error {
+		var req struct {
+			Room string `json:"room"`
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || req.Room == "" {
+			return fmt.Errorf("%s requires a room", msg.Topic)
+		}
+		r.Leave(conn, req.Room)
+		return nil
+	})
+	h.Handle(TopicRoomMessage, func(ctx context.Context, conn *Connection, msg Message) error {
+		var req struct {
+			Room string          `json:"room"`
+			Data json.RawMessage `json:"data"`
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || req.Room == "" {
+			return fmt.Errorf("%s requires a room", msg.Topic)
+		}
+		return r.Publish(ctx, conn, req.Room, req.Data)
+	})
+	h.Handle(TopicRoomPresence, func(ctx context.Context, conn *Connection, msg Message) error {
+		var req struct {
+			Room  string `json:"room"`
+			State string `json:"state"`
+		}
+		if err := json.Unmarshal(msg.Payload, &req); err != nil || req.Room == "" {
+			return fmt.Errorf("%s requires a room", msg.Topic)
+		}
+		return r.SetState(conn, req.Room, req.State)
+	})
+}
+
+// memberOf returns the key and the user ID of the member a connection counts
+// as, anonymous connections are their own member
+func memberOf(conn *Connection) (string, string) {
+	if key, ok := userKey(conn.UserID); ok {
+		return key, fmt.Sprint(conn.UserID)
+	}
+	return conn.ID, conn.ID
+}
+
+// Join adds conn to name after authorizing it. The connection receives the
+// members and recent history of the room, the other members a presence diff
+// if the user was not in the room yet.
+func (r *Rooms) Join(ctx context.Context, conn *Connection, name string) error {
+	if r.authorize != nil {
+		if err := r.authorize(ctx, conn, name); err != nil {
+			return err
+		}
+	}
+
+	id, userID := memberOf(conn)
+	r.mu.Lock()
+	rm, ok := r.rooms[name]
+	if !ok {
+		rm = &room{
+			conns:   make(map[*Connection]string),
+			members: make(map[string]*roomMember),
+		}
+		r.rooms[name] = rm
+	}
+	if _, joined := rm.conns[conn]; joined {
+		r.mu.Unlock()
+		return nil
+	}
+	rm.conns[conn] = id
+
+	var diff *PresenceDiff
+	member, ok := rm.members[id]
+	if !ok {
+		member = &roomMember{Member: Member{UserID: userID}}
+		rm.members[id] = member
+		diff = &PresenceDiff{Room: name, Joins: []Member{member.Member}}
+	}
+	member.conns++
+
+	if _, ok := r.byConn[conn]; !ok {
+		r.byConn[conn] = make(map[string]bool)
+	}
+	r.byConn[conn][name] = true
+	if !r.watched[conn] {
+		r.watched[conn] = true
+		go r.watch(conn)
+	}
+
+	members := rm.snapshot()
+	others := rm.connections(conn)
+	r.mu.Unlock()
+
+	if diff != nil {
+		r.send(others, TopicRoomPresence, diff)
+	}
+	r.send([]*Connection{conn}, TopicRoomMembers, RoomMembers{Room: name, Members: members})
+
+	if r.history != nil && r.historySize > 0 {
+		msgs, err := r.history.Recent(ctx, name, r.historySize)
+		if err != nil {
+			logx.Error("Error loading room history", logx.Field("room", name), logx.Field("error", err))
+		} else {
+			r.send([]*Connection{conn}, TopicRoomHistory, RoomHistory{Room: name, Messages: msgs})
+		}
+	}
+
+	if r.onJoin != nil {
+		r.onJoin(conn, name)
+	}
+	return nil
+}
+
+// Leave removes conn from name, the other members receive a presence diff
+// once the last connection of the user left
+func (r *Rooms) Leave(conn *Connection, name string) {
+	r.mu.Lock()
+	left, diff, others := r.leave(conn, name)
+	r.mu.Unlock()
+
+	if !left {
+		return
+	}
+	if diff != nil {
+		r.send(others, TopicRoomPresence, diff)
+	}
+	if r.onLeave != nil {
+		r.onLeave(conn, name)
+	}
+}
+
+// LeaveAll removes conn from every room it joined
+func (r *Rooms) LeaveAll(conn *Connection) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.byConn[conn]))
+	for name := range r.byConn[conn] {
+		names = append(names, name)
+	}
+	r.mu.Unlock()
+
+	for _, name := range names {
+		r.Leave(conn, name)
+	}
+}
+
+// watch leaves all rooms once conn closes. The connection is unwatched
+// first, so a join racing the close starts a new watcher.
+func (r *Rooms) watch(conn *Connection) {
+	<-conn.Done()
+
+	r.mu.Lock()
+	delete(r.watched, conn)
+	r.mu.Unlock()
+	r.LeaveAll(conn)
+}
+
+// leave removes conn from name. r.mu must be held.
+func (r *Rooms) leave(conn *Connection, name string) (bool, *PresenceDiff, []*Connection) {
+	rm, ok := r.rooms[name]
+	if !ok {
+		return false, nil, nil
+	}
+	id, ok := rm.conns[conn]
+	if !ok {
+		return false, nil, nil
+	}
+	delete(rm.conns, conn)
+
+	if rooms := r.byConn[conn]; rooms != nil {
+		delete(rooms, name)
+		if len(rooms) == 0 {
+			delete(r.byConn, conn)
+		}
+	}
+
+	var diff *PresenceDiff
+	member := rm.members[id]
+	member.conns--
+	if member.conns == 0 {
+		delete(rm.members, id)
+		diff = &PresenceDiff{Room: name, Leaves: []Member{{UserID: member.UserID}}}
+	}
+	if len(rm.conns) == 0 {
+		delete(r.rooms, name)
+	}
+	return true, diff, rm.connections(nil)
+}
+
+// Members returns the members of a room ordered by user ID
+func (r *Rooms) Members(name string) []Member {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	rm, ok := r.rooms[name]
+	if !ok {
+		return nil
+	}
+	return rm.snapshot()
+}
+
+// SetState sets the presence state of the user of conn in name, e.g. "typing",
+// and pushes the change to the other members
+func (r *Rooms) SetState(conn *Connection, name string, state string) error {
+	r.mu.Lock()
+	rm, ok := r.rooms[name]
+	if !ok {
+		r.mu.Unlock()
+		return ErrNotMember
+	}
+	id, ok := rm.conns[conn]
+	if !ok {
+		r.mu.Unlock()
+		return ErrNotMember
+	}
+	member := rm.members[id]
+	if member.State == state {
+		r.mu.Unlock()
+		return nil
+	}
+	member.State = state
+	diff := PresenceDiff{Room: name, Updates: []Member{member.Member}}
+	others := rm.connections(conn)
+	r.mu.Unlock()
+
+	r.send(others, TopicRoomPresence, diff)
+	return nil
+}
+
+// Publish sends data to every connection in name and keeps it in the
+// history. A nil sender publishes as the server.
+func (r *Rooms) Publish(ctx context.Context, sender *Connection, name string, data json.RawMessage) error {
+	msg := RoomMessage{
+		ID:   uuid.New().String(),
+		Room: name,
+		Data: data,
+		Time: time.Now(),
+	}
+
+	r.mu.Lock()
+	rm, ok := r.rooms[name]
+	if sender != nil {
+		if !ok {
+			r.mu.Unlock()
+			return ErrNotMember
+		}
+		id, ok := rm.conns[sender]
+		if !ok {
+			r.mu.Unlock()
+			return ErrNotMember
+		}
+		msg.UserID = rm.members[id].UserID
+	}
+	var conns []*Connection
+	if ok {
+		conns = rm.connections(nil)
+	}
+	r.mu.Unlock()
+
+	if r.history != nil {
+		if err := r.history.Append(ctx, name, msg); err != nil {
+			return fmt.Errorf("failed to store room message: %w", err)
+		}
+	}
+	r.send(conns, TopicRoomMessage, msg)
+	return nil
+}
+
+// send queues payload for conns
+func (r *Rooms) send(conns []*Connection, topic string, payload interface{}) {
+	if len(conns) == 0 {
+		return
+	}
+	data, err := json.Marshal(payload)
+	if err != nil {
+		logx.Error("Error marshalling room event", logx.Field("topic", topic), logx.Field("error", err))
+		return
+	}
+	msg := Message{ID: uuid.New().String(), Topic: topic, Payload: data}
+	for _, conn := range conns {
+		if err := conn.Send(msg); err != nil && !errors.Is(err, ErrConnectionClosed) {
+			logx.Error("Error sending room event", logx.Field("topic", topic), logx.Field("error", err))
+		}
+	}
+}
+
+// snapshot returns the members ordered by user ID
+func (rm *room) snapshot() []Member {
+	members := make([]Member, 0, len(rm.members))
+	for _, m := range rm.members {
+		members = append(members, m.Member)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].UserID < members[j].UserID })
+	return members
+}
+
+// connections returns the connections in the room except skip
+func (rm *room) connections(skip *Connection) []*Connection {
+	conns := make([]*Connection, 0, len(rm.conns))
+	for conn := range rm.conns {
+		if conn != skip {
+			conns = append(conns, conn)
+		}
+	}
+	return conns
+}
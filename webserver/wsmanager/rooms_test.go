@@ -0,0 +1,140 @@
+package wsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// roomClient is the client end of a connection joined to rooms
+type roomClient struct {
+	conn   *Connection
+	client net.Conn
+}
+
+func newRoomClient(t *testing.T, userID any) *roomClient {
+	t.Helper()
+	server, client := net.Pipe()
+	conn := NewConnection(server)
+	conn.UserID = userID
+	t.Cleanup(func() {
+		conn.Close()
+		client.Close()
+	})
+	return &roomClient{conn: conn, client: client}
+}
+
+// next reads the next message and decodes its payload into v
+func (c *roomClient) next(t *testing.T, topic string, v interface{}) {
+	t.Helper()
+	c.client.SetReadDeadline(time.Now().Add(time.Second))
+	data, err := wsutil.ReadServerText(c.client)
+	require.NoError(t, err)
+
+	var msg Message
+	require.NoError(t, json.Unmarshal(data, &msg))
+	require.Equal(t, topic, msg.Topic)
+	require.NoError(t, json.Unmarshal(msg.Payload, v))
+}
+
+// TestRoomsPresenceAndHistory tests join diffs, presence updates and history for late joiners.
+func TestRoomsPresenceAndHistory(t *testing.T) {
+	ctx := context.Background()
+	rooms := NewRooms(WithHistory(NewMemoryHistory(10), 2))
+	alice := newRoomClient(t, "alice")
+	bob := newRoomClient(t, "bob")
+
+	require.NoError(t, rooms.Join(ctx, alice.conn, "lobby"))
+	var members RoomMembers
+	alice.next(t, TopicRoomMembers, &members)
+	assert.Equal(t, []Member{{UserID: "alice"}}, members.Members)
+	var history RoomHistory
+	alice.next(t, TopicRoomHistory, &history)
+	assert.Empty(t, history.Messages)
+
+	go rooms.Join(ctx, bob.conn, "lobby")
+	var diff PresenceDiff
+	alice.next(t, TopicRoomPresence, &diff)
+	assert.Equal(t, []Member{{UserID: "bob"}}, diff.Joins)
+	bob.next(t, TopicRoomMembers, &members)
+	assert.Equal(t, []Member{{UserID: "alice"}, {UserID: "bob"}}, members.Members)
+	bob.next(t, TopicRoomHistory, &history)
+
+	go rooms.SetState(bob.conn, "lobby", "typing")
+	diff = PresenceDiff{}
+	alice.next(t, TopicRoomPresence, &diff)
+	assert.Equal(t, []Member{{UserID: "bob", State: "typing"}}, diff.Updates)
+
+	for _, text := range []string{`"one"`, `"two"`, `"three"`} {
+		go rooms.Publish(ctx, alice.conn, "lobby", json.RawMessage(text))
+		var msg RoomMessage
+		alice.next(t, TopicRoomMessage, &msg)
+		bob.next(t, TopicRoomMessage, &msg)
+		assert.Equal(t, "alice", msg.UserID)
+		assert.JSONEq(t, text, string(msg.Data))
+	}
+
+	carol := newRoomClient(t, "carol")
+	go rooms.Join(ctx, carol.conn, "lobby")
+	carol.next(t, TopicRoomMembers, &members)
+	carol.next(t, TopicRoomHistory, &history)
+	require.Len(t, history.Messages, 2)
+	assert.JSONEq(t, `"two"`, string(history.Messages[0].Data))
+	assert.JSONEq(t, `"three"`, string(history.Messages[1].Data))
+	diff = PresenceDiff{}
+	alice.next(t, TopicRoomPresence, &diff)
+	assert.Equal(t, []Member{{UserID: "carol"}}, diff.Joins)
+
+	bob.conn.Close()
+	diff = PresenceDiff{}
+	alice.next(t, TopicRoomPresence, &diff)
+	assert.Equal(t, []Member{{UserID: "bob"}}, diff.Leaves)
+}
+
+// TestRoomsAuthorize tests that joins are checked by the authorize callback.
+func TestRoomsAuthorize(t *testing.T) {
+	denied := errors.New("members only")
+	rooms := NewRooms(WithAuthorize(func(ctx context.Context, conn *Connection, room string) error {
+		if room == "staff" {
+			return denied
+		}
+		return nil
+	}))
+	alice := newRoomClient(t, "alice")
+
+	assert.ErrorIs(t, rooms.Join(context.Background(), alice.conn, "staff"), denied)
+	assert.Empty(t, rooms.Members("staff"))
+	assert.ErrorIs(t, rooms.Publish(context.Background(), alice.conn, "staff", json.RawMessage(`{}`)), ErrNotMember)
+}
+
+// TestRoomsWatchConnectionOnce tests that rejoining starts no further close
+// watchers and closing the connection leaves its rooms.
+func TestRoomsWatchConnectionOnce(t *testing.T) {
+	rooms := NewRooms()
+	alice := newRoomClient(t, "alice")
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, rooms.Join(ctx, alice.conn, "lobby"))
+		rooms.Leave(alice.conn, "lobby")
+	}
+	require.NoError(t, rooms.Join(ctx, alice.conn, "lobby"))
+	rooms.mu.Lock()
+	assert.Len(t, rooms.watched, 1)
+	rooms.mu.Unlock()
+
+	alice.conn.Close()
+	assert.Eventually(t, func() bool {
+		rooms.mu.Lock()
+		defer rooms.mu.Unlock()
+		return len(rooms.watched) == 0 && len(rooms.byConn) == 0
+	}, time.Second, 5*time.Millisecond)
+	assert.Empty(t, rooms.Members("lobby"))
+}
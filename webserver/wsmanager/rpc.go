@@ -0,0 +1,135 @@
+package wsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// RPC error codes
+const (
+	CodeBadRequest = "bad_request"
+	CodeNotFound   = "not_found"
+	CodeInternal   = "internal"
+)
+
+// RPCError is the structured error of a failed RPC
+type RPCError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+// NewRPCError creates an RPC error with code and message
+func NewRPCError(code, message string) *RPCError {
+	return &RPCError{Code: code, Message: message}
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// HandleRPC registers fn for calls on topic. The payload is decoded into Req
+// and the reply carries the ID of the call with either the result or the
+// error. Return an *RPCError to control the code the client receives. Calls
+// are answered by ID, so they are handled concurrently like HandleConcurrent.
+func HandleRPC[Req, Resp any](h *Handler, topic string, fn func(ctx context.Context, conn *Connection, req Req) (Resp, error)) {
+	h.HandleConcurrent(topic, func(ctx context.Context, conn *Connection, msg Message) error {
+		reply := Message{ID: msg.ID, Topic: TopicReply}
+
+		var req Req
+		if len(msg.Payload) > 0 {
+			if err := json.Unmarshal(msg.Payload, &req); err != nil {
+				reply.Error = NewRPCError(CodeBadRequest, fmt.Sprintf("invalid payload: %v", err))
+				return conn.Send(reply)
+			}
+		}
+
+		resp, err := fn(ctx, conn, req)
+		if err != nil {
+			var rpcErr *RPCError
+			if !errors.As(err, &rpcErr) {
+				rpcErr = NewRPCError(CodeInternal, err.Error())
+			}
+			reply.Error = rpcErr
+			return conn.Send(reply)
+		}
+
+		payload, err := json.Marshal(resp)
+		if err != nil {
+			reply.Error = NewRPCError(CodeInternal, fmt.Sprintf("failed to marshal result: %v", err))
+			return conn.Send(reply)
+		}
+		reply.Payload = payload
+		return conn.Send(reply)
+	})
+}
+
+// Call sends payload to the client on topic and waits for its reply, which
+// is decoded into result unless result is nil. A failed call returns the
+// *RPCError of the client. Calls time out with ctx or after the call timeout
+// of the connection, whichever comes first.
+func (c *Connection) Call(ctx context.Context, topic string, payload interface{}, result interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	if c.callTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.callTimeout)
+		defer cancel()
+	}
+
+	id := uuid.New().String()
+	ch := make(chan Message, 1)
+	c.pendingMu.Lock()
+	c.pending[id] = ch
+	c.pendingMu.Unlock()
+	defer func() {
+		c.pendingMu.Lock()
+		delete(c.pending, id)
+		c.pendingMu.Unlock()
+	}()
+
+	if err := c.Send(Message{ID: id, Topic: topic, Payload: data}); err != nil {
+		return err
+	}
+
+	select {
+	case reply := <-ch:
+		if reply.Error != nil {
+			return reply.Error
+		}
+		if result == nil || len(reply.Payload) == 0 {
+			return nil
+		}
+		if err := json.Unmarshal(reply.Payload, result); err != nil {
+			return fmt.Errorf("failed to unmarshal reply of %s: %w", topic, err)
+		}
+		return nil
+	case <-c.done:
+		return ErrConnectionClosed
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return fmt.Errorf("call to %s timed out: %w", topic, ctx.Err())
+		}
+		return ctx.Err()
+	}
+}
+
+// resolve hands a reply to the call awaiting it, reporting whether one did
+func (c *Connection) resolve(reply Message) bool {
+	c.pendingMu.Lock()
+	ch, ok := c.pending[reply.ID]
+	delete(c.pending, reply.ID)
+	c.pendingMu.Unlock()
+
+	if ok {
+		ch <- reply
+	}
+	return ok
+}
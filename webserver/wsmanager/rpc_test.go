@@ -0,0 +1,80 @@
+package wsmanager
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sumRequest struct {
+	A int `json:"a"`
+	B int `json:"b"`
+}
+
+type sumResponse struct {
+	Sum int `json:"sum"`
+}
+
+// TestHandleRPC tests that replies carry the call ID with either the result or a structured error.
+func TestHandleRPC(t *testing.T) {
+	h := NewHandler(NewConnectionManager())
+	HandleRPC(h, "sum", func(ctx context.Context, conn *Connection, req sumRequest) (sumResponse, error) {
+		if req.A < 0 {
+			return sumResponse{}, NewRPCError(CodeBadRequest, "a must not be negative")
+		}
+		return sumResponse{Sum: req.A + req.B}, nil
+	})
+	conn := dial(t, h, "")
+
+	require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"c1","topic":"sum","payload":{"a":1,"b":2}}`)))
+	msg := readMessage(t, conn)
+	assert.Equal(t, "c1", msg.ID)
+	assert.Equal(t, TopicReply, msg.Topic)
+	assert.Nil(t, msg.Error)
+	assert.JSONEq(t, `{"sum":3}`, string(msg.Payload))
+
+	require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"c2","topic":"sum","payload":{"a":-1}}`)))
+	msg = readMessage(t, conn)
+	assert.Equal(t, "c2", msg.ID)
+	assert.Equal(t, &RPCError{Code: CodeBadRequest, Message: "a must not be negative"}, msg.Error)
+
+	require.NoError(t, wsutil.WriteClientText(conn, []byte(`{"id":"c3","topic":"sum","payload":"oops"}`)))
+	msg = readMessage(t, conn)
+	require.NotNil(t, msg.Error)
+	assert.Equal(t, CodeBadRequest, msg.Error.Code)
+}
+
+// TestConnectionCall tests calls from the server to a client, including their timeout.
+func TestConnectionCall(t *testing.T) {
+	connected := make(chan *Connection, 1)
+	h := NewHandler(NewConnectionManager(),
+		WithOnConnect(func(conn *Connection) { connected <- conn }),
+		WithConnectionOptions(WithCallTimeout(100*time.Millisecond)),
+	)
+	client := dial(t, h, "")
+	server := <-connected
+
+	result := make(chan error, 1)
+	var resp sumResponse
+	go func() {
+		result <- server.Call(context.Background(), "sum", sumRequest{A: 2, B: 3}, &resp)
+	}()
+
+	call := readMessage(t, client)
+	assert.Equal(t, "sum", call.Topic)
+	var req sumRequest
+	require.NoError(t, json.Unmarshal(call.Payload, &req))
+	reply, _ := json.Marshal(Message{ID: call.ID, Topic: TopicReply, Payload: json.RawMessage(`{"sum":5}`)})
+	require.NoError(t, wsutil.WriteClientText(client, reply))
+
+	require.NoError(t, <-result)
+	assert.Equal(t, 5, resp.Sum)
+
+	err := server.Call(context.Background(), "sum", sumRequest{}, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
@@ -3,38 +3,115 @@ package wsmanager
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"sync"
+	"time"
 
 	"github.com/gobwas/ws"
-	"github.com/gobwas/ws/wsutil"
+	"github.com/google/uuid"
+	"github.com/templwind/soul/pubsub"
 	"github.com/zeromicro/go-zero/core/logx"
 )
 
+// ManagerOption customizes a ConnectionManager
+type ManagerOption func(cm *ConnectionManager)
+
+// WithBroker fans events out through broker on subject, so SendEvent and
+// SendEventToUser reach clients connected to other instances. Call Start to
+// receive the events of other instances.
+func WithBroker(broker pubsub.Broker, subject string) ManagerOption {
+	return func(cm *ConnectionManager) {
+		cm.broker = broker
+		cm.subject = subject
+	}
+}
+
+// WithPresence tracks connections in presence, so connection counts cover all instances
+func WithPresence(presence Presence) ManagerOption {
+	return func(cm *ConnectionManager) {
+		cm.presence = presence
+	}
+}
+
 type ConnectionManager struct {
 	mu            sync.Mutex
 	clients       map[*Connection]bool
 	subscriptions map[any]map[*Connection]bool
 	broadcast     chan Message
-	userConnMap   map[any][]*Connection // Mapping from user ID to connections
-	debug         bool                  // Add debug flag
+	userConnMap   map[string][]*Connection // Mapping from user ID to connections
+	debug         bool                     // Add debug flag
+	done          chan struct{}
+	closeOnce     sync.Once
+
+	id       string
+	broker   pubsub.Broker
+	subject  string
+	presence Presence
 }
 
-var instance *ConnectionManager
-var once sync.Once
+// fanout is an event as published to the other instances
+type fanout struct {
+	Origin string `json:"origin"`
+	Topic  string `json:"topic,omitempty"`
+	User   string `json:"user,omitempty"`
+	Data   []byte `json:"data"`
+}
+
+// NewConnectionManager creates an independent manager
+func NewConnectionManager(opts ...ManagerOption) *ConnectionManager {
+	cm := &ConnectionManager{
+		clients:       make(map[*Connection]bool),
+		subscriptions: make(map[any]map[*Connection]bool),
+		broadcast:     make(chan Message),
+		userConnMap:   make(map[string][]*Connection),
+		debug:         false, // Default to false
+		done:          make(chan struct{}),
+		id:            uuid.New().String(),
+	}
+	for _, opt := range opts {
+		opt(cm)
+	}
+	go cm.handleBroadcasts()
+	return cm
+}
 
-func NewConnectionManager() *ConnectionManager {
-	once.Do(func() {
-		instance = &ConnectionManager{
-			clients:       make(map[*Connection]bool),
-			subscriptions: make(map[any]map[*Connection]bool),
-			broadcast:     make(chan Message),
-			userConnMap:   make(map[any][]*Connection),
-			debug:         false, // Default to false
+// Start receives the events of other instances from the broker and keeps
+// the presence of local connections alive until ctx is done
+func (cm *ConnectionManager) Start(ctx context.Context) error {
+	if cm.broker != nil {
+		// An empty group delivers every event to every instance
+		_, err := cm.broker.Subscribe(ctx, cm.subject, "", func(ctx context.Context, msg *pubsub.Message) error {
+			var f fanout
+			if err := json.Unmarshal(msg.Data, &f); err != nil {
+				return fmt.Errorf("failed to unmarshal event: %w", err)
+			}
+			if f.Origin == cm.id {
+				// Already delivered locally
+				return nil
+			}
+			if f.User != "" {
+				cm.deliverToUser(f.User, f.Data)
+			} else {
+				cm.deliverToTopic(f.Topic, f.Data)
+			}
+			return nil
+		})
+		if err != nil {
+			return fmt.Errorf("failed to subscribe to %s: %w", cm.subject, err)
 		}
-		// Optionally start handling broadcasts
-		go instance.handleBroadcasts()
+	}
+
+	if cm.presence != nil {
+		go cm.refreshPresence(ctx)
+	}
+	return nil
+}
+
+// Close stops the broadcast loop
+func (cm *ConnectionManager) Close() {
+	cm.closeOnce.Do(func() {
+		close(cm.done)
 	})
-	return instance
 }
 
 // SetDebug enables or disables debug logging
@@ -51,30 +128,48 @@ func (cm *ConnectionManager) debugLog(msg string, fields ...logx.LogField) {
 	}
 }
 
+// userKey returns the key of userID in the user index and the presence store.
+// The key includes the type, so the user IDs 1 and "1" stay apart.
+func userKey(userID any) (string, bool) {
+	if userID == nil || userID == "" {
+		return "", false
+	}
+	return fmt.Sprintf("%T:%v", userID, userID), true
+}
+
 func (cm *ConnectionManager) AddClient(conn *Connection, userID any) {
 	cm.mu.Lock()
-	defer cm.mu.Unlock()
 	cm.clients[conn] = true
-	if userID != "" {
-		cm.userConnMap[userID] = append(cm.userConnMap[userID], conn)
+	key, ok := userKey(userID)
+	if ok {
+		cm.userConnMap[key] = append(cm.userConnMap[key], conn)
 	}
 	cm.debugLog("Client added", logx.Field("connection", conn), logx.Field("userID", userID))
+	cm.mu.Unlock()
+
+	if ok && cm.presence != nil {
+		if err := cm.presence.Add(context.Background(), key, conn.ID); err != nil {
+			logx.Error("Error adding presence", logx.Field("userID", key), logx.Field("error", err))
+		}
+	}
 }
 
+// RemoveClient removes conn from the manager and closes it
 func (cm *ConnectionManager) RemoveClient(conn *Connection, userID any) {
 	cm.mu.Lock()
-	defer cm.mu.Unlock()
+	conn.Close()
 	delete(cm.clients, conn)
-	if userID != "" {
-		conns := cm.userConnMap[userID]
+	key, ok := userKey(userID)
+	if ok {
+		conns := cm.userConnMap[key]
 		for i, c := range conns {
 			if c == conn {
-				cm.userConnMap[userID] = append(conns[:i], conns[i+1:]...)
+				cm.userConnMap[key] = append(conns[:i], conns[i+1:]...)
 				break
 			}
 		}
-		if len(cm.userConnMap[userID]) == 0 {
-			delete(cm.userConnMap, userID)
+		if len(cm.userConnMap[key]) == 0 {
+			delete(cm.userConnMap, key)
 		}
 	}
 	for topic := range cm.subscriptions {
@@ -84,6 +179,13 @@ func (cm *ConnectionManager) RemoveClient(conn *Connection, userID any) {
 		}
 	}
 	cm.debugLog("Client removed", logx.Field("connection", conn))
+	cm.mu.Unlock()
+
+	if ok && cm.presence != nil {
+		if err := cm.presence.Remove(context.Background(), key, conn.ID); err != nil {
+			logx.Error("Error removing presence", logx.Field("userID", key), logx.Field("error", err))
+		}
+	}
 }
 
 func (cm *ConnectionManager) Subscribe(conn *Connection, topic string) {
@@ -111,24 +213,31 @@ func (cm *ConnectionManager) Broadcast(msg Message, sender *Connection) {
 	cm.debugLog("Broadcasting message",
 		logx.Field("message", msg),
 		logx.Field("sender", sender))
-	cm.broadcast <- msg
+	select {
+	case cm.broadcast <- msg:
+	case <-cm.done:
+	}
 }
 
 func (cm *ConnectionManager) handleBroadcasts() {
 	for {
-		msg := <-cm.broadcast
-		cm.mu.Lock()
+		var msg Message
+		select {
+		case msg = <-cm.broadcast:
+		case <-cm.done:
+			return
+		}
+
 		topicPayload := struct {
 			Topic   string          `json:"topic"`
 			Payload json.RawMessage `json:"payload"`
 		}{}
 		if err := json.Unmarshal(msg.Payload, &topicPayload); err != nil {
 			logx.Error("Error unmarshaling broadcast payload", logx.Field("error", err))
-			cm.mu.Unlock()
 			continue
 		}
-		subscribers, exists := cm.subscriptions[topicPayload.Topic]
-		if !exists {
+		subscribers := cm.GetSubscribers(topicPayload.Topic)
+		if len(subscribers) == 0 {
 			cm.debugLog("No subscribers found", logx.Field("topic", topicPayload.Topic))
 		} else {
 			cm.debugLog("Found subscribers",
@@ -139,36 +248,159 @@ func (cm *ConnectionManager) handleBroadcasts() {
 			if client == msg.Sender {
 				continue // Skip the sender
 			}
-			go func(client *Connection) {
-				client.mu.Lock()
-				defer client.mu.Unlock()
-				err := wsutil.WriteServerMessage(client.Conn, ws.OpText, topicPayload.Payload)
-				if err != nil {
-					logx.Error("Error sending message to client", logx.Field("error", err))
-					client.Conn.Close()
-					// Depending on your policy, you might want to retry, log, or handle the error differently
-				} else {
-					logx.Info("Message sent to client", logx.Field("client", client))
-				}
-			}(client)
+			// Queueing never blocks, a slow client is evicted instead of stalling the broadcast
+			if err := client.write(ws.OpText, topicPayload.Payload); err != nil {
+				logx.Error("Error sending message to client", logx.Field("error", err))
+			}
 		}
-		cm.mu.Unlock()
 	}
 }
 
-// GetConnectionsForUser retrieves all active connections for a given user ID.
+// GetConnectionsForUser retrieves a snapshot of the active connections of a user on this instance.
 func (cm *ConnectionManager) GetConnectionsForUser(userID any) []*Connection {
+	key, ok := userKey(userID)
+	if !ok {
+		return nil
+	}
+	return cm.connectionsForKey(key)
+}
+
+// connectionsForKey retrieves a snapshot of the connections of the user with key
+func (cm *ConnectionManager) connectionsForKey(key string) []*Connection {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	return cm.userConnMap[userID]
+	return append([]*Connection(nil), cm.userConnMap[key]...)
 }
 
-// GetSubscribers retrieves all connections subscribed to a given topic.
+// GetSubscribers retrieves a snapshot of the connections subscribed to a given topic.
 func (cm *ConnectionManager) GetSubscribers(topic any) map[*Connection]bool {
 	cm.mu.Lock()
 	defer cm.mu.Unlock()
-	if subs, exists := cm.subscriptions[topic]; exists {
-		return subs
+	subs, exists := cm.subscriptions[topic]
+	if !exists {
+		return nil
+	}
+	snapshot := make(map[*Connection]bool, len(subs))
+	for conn := range subs {
+		snapshot[conn] = true
+	}
+	return snapshot
+}
+
+// ConnectionCount returns the number of connections of a user on this instance
+func (cm *ConnectionManager) ConnectionCount(userID any) int {
+	key, ok := userKey(userID)
+	if !ok {
+		return 0
+	}
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	return len(cm.userConnMap[key])
+}
+
+// UserConnections returns the number of connections of a user across all
+// instances, or on this instance if the manager has no presence store
+func (cm *ConnectionManager) UserConnections(ctx context.Context, userID any) (int, error) {
+	key, ok := userKey(userID)
+	if !ok {
+		return 0, nil
+	}
+	if cm.presence == nil {
+		return cm.ConnectionCount(userID), nil
+	}
+	return cm.presence.Count(ctx, key)
+}
+
+// SendEvent sends an event with the given topic and payload to all clients subscribed to that topic.
+func (cm *ConnectionManager) SendEvent(topic string, payload interface{}) error {
+	out, err := encodeEvent(topic, payload)
+	if err != nil {
+		return err
+	}
+
+	cm.deliverToTopic(topic, out)
+	return cm.fanOut(fanout{Topic: topic, Data: out})
+}
+
+// SendEventToUser sends an event with the given topic and payload to every connection of a user.
+func (cm *ConnectionManager) SendEventToUser(userID any, topic string, payload interface{}) error {
+	key, ok := userKey(userID)
+	if !ok {
+		return fmt.Errorf("invalid user ID %v", userID)
+	}
+
+	out, err := encodeUserEvent(topic, payload)
+	if err != nil {
+		return err
+	}
+
+	cm.deliverToUser(key, out)
+	return cm.fanOut(fanout{User: key, Data: out})
+}
+
+// deliverToTopic queues out for the local subscribers of topic
+func (cm *ConnectionManager) deliverToTopic(topic string, out []byte) {
+	// Queue the event for all subscribed clients, a slow client is evicted instead of stalling the others
+	for conn := range cm.GetSubscribers(topic) {
+		if err := conn.write(ws.OpText, out); err != nil {
+			logx.Error("Error sending event to client", logx.Field("topic", topic), logx.Field("error", err))
+		}
+	}
+}
+
+// deliverToUser queues out for the local connections of the user with key
+func (cm *ConnectionManager) deliverToUser(key string, out []byte) {
+	for _, conn := range cm.connectionsForKey(key) {
+		if err := conn.write(ws.OpText, out); err != nil {
+			logx.Error("Error sending event to user", logx.Field("userID", key), logx.Field("error", err))
+		}
+	}
+}
+
+// fanOut publishes an event to the other instances
+func (cm *ConnectionManager) fanOut(f fanout) error {
+	if cm.broker == nil {
+		return nil
+	}
+	f.Origin = cm.id
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	if err := cm.broker.Publish(context.Background(), cm.subject, data); err != nil {
+		return fmt.Errorf("failed to fan out event: %w", err)
 	}
 	return nil
 }
+
+// refreshPresence keeps the presence of local connections from expiring until ctx is done
+func (cm *ConnectionManager) refreshPresence(ctx context.Context) {
+	ticker := time.NewTicker(cm.presence.RefreshInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-cm.done:
+			return
+		case <-ticker.C:
+			cm.mu.Lock()
+			conns := make(map[string][]string, len(cm.userConnMap))
+			for key, userConns := range cm.userConnMap {
+				for _, conn := range userConns {
+					conns[key] = append(conns[key], conn.ID)
+				}
+			}
+			cm.mu.Unlock()
+
+			for key, ids := range conns {
+				for _, id := range ids {
+					if err := cm.presence.Add(ctx, key, id); err != nil {
+						logx.Error("Error refreshing presence", logx.Field("userID", key), logx.Field("error", err))
+					}
+				}
+			}
+		}
+	}
+}
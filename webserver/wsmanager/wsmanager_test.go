@@ -0,0 +1,92 @@
+package wsmanager
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/gobwas/ws/wsutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/templwind/soul/pubsub"
+)
+
+// TestManagersAreIndependent tests that every manager keeps its own connections.
+func TestManagersAreIndependent(t *testing.T) {
+	m1 := NewConnectionManager()
+	m2 := NewConnectionManager()
+	defer m1.Close()
+	defer m2.Close()
+
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConnection(server)
+	m1.AddClient(conn, int64(7))
+	m1.Subscribe(conn, "news")
+
+	assert.Equal(t, 1, m1.ConnectionCount(int64(7)))
+	assert.Equal(t, 0, m2.ConnectionCount(int64(7)))
+
+	subs := m1.GetSubscribers("news")
+	m1.Unsubscribe(conn, "news")
+	assert.Len(t, subs, 1, "snapshot must not change")
+	assert.Empty(t, m1.GetSubscribers("news"))
+}
+
+// TestUserIDTypesDiffer tests that user IDs of different types are different users.
+func TestUserIDTypesDiffer(t *testing.T) {
+	m := NewConnectionManager()
+	defer m.Close()
+
+	server, client := net.Pipe()
+	defer client.Close()
+	conn := NewConnection(server)
+	m.AddClient(conn, 1)
+
+	assert.Equal(t, []*Connection{conn}, m.GetConnectionsForUser(1))
+	assert.Empty(t, m.GetConnectionsForUser("1"))
+	assert.Empty(t, m.GetConnectionsForUser(int64(1)))
+	n, err := m.UserConnections(context.Background(), "1")
+	require.NoError(t, err)
+	assert.Zero(t, n)
+}
+
+// TestSendEventToUserAcrossInstances tests that events reach users connected to the other instance in both directions.
+func TestSendEventToUserAcrossInstances(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	broker := pubsub.NewMemoryBroker()
+	defer broker.Close()
+
+	m1 := NewConnectionManager(WithBroker(broker, "ws"))
+	m2 := NewConnectionManager(WithBroker(broker, "ws"))
+	defer m1.Close()
+	defer m2.Close()
+
+	// Events sent before an instance starts are not replayed to it
+	require.NoError(t, m1.SendEventToUser(int64(7), "notice", "<p>old</p>"))
+	require.NoError(t, m1.Start(ctx))
+	require.NoError(t, m2.Start(ctx))
+
+	server1, client1 := net.Pipe()
+	defer client1.Close()
+	m1.AddClient(NewConnection(server1), int64(8))
+	server2, client2 := net.Pipe()
+	defer client2.Close()
+	m2.AddClient(NewConnection(server2), int64(7))
+
+	require.NoError(t, m1.SendEventToUser(int64(7), "notice", "<p>to 7</p>"))
+	require.NoError(t, m2.SendEventToUser(int64(8), "notice", "<p>to 8</p>"))
+
+	client2.SetReadDeadline(time.Now().Add(time.Second))
+	data, err := wsutil.ReadServerText(client2)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>to 7</p>", string(data))
+
+	client1.SetReadDeadline(time.Now().Add(time.Second))
+	data, err = wsutil.ReadServerText(client1)
+	require.NoError(t, err)
+	assert.Equal(t, "<p>to 8</p>", string(data))
+}